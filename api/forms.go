@@ -0,0 +1,261 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/csvfile"
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/service"
+	"github.com/LaulauChau/go-directory/internal/vcard"
+	"github.com/LaulauChau/go-directory/web/templates"
+)
+
+const (
+	defaultPageSize = 25
+	maxPageSize     = 500
+)
+
+// contactFromForm applies the fields present in form onto contact, so a form
+// only carrying "phone" updates the primary phone and leaves the rest alone.
+func contactFromForm(form url.Values, contact domain.Contact) domain.Contact {
+	contact = contact.Clone()
+
+	setString := func(key string, field *string) {
+		if form.Has(key) {
+			*field = strings.TrimSpace(form.Get(key))
+		}
+	}
+
+	setString("name", &contact.Name)
+	setString("firstName", &contact.FirstName)
+	setString("lastName", &contact.LastName)
+	setString("organization", &contact.Organization)
+	setString("jobTitle", &contact.JobTitle)
+	setString("birthday", &contact.Birthday)
+	setString("notes", &contact.Notes)
+
+	if form.Has("tags") {
+		contact.Tags = strings.Split(form.Get("tags"), ",")
+	}
+	if favorite, err := strconv.ParseBool(form.Get("favorite")); err == nil {
+		contact.Favorite = favorite
+	}
+
+	if !form.Has("name") && (form.Has("firstName") || form.Has("lastName")) {
+		contact.Name = domain.JoinName(contact.FirstName, contact.LastName)
+	}
+
+	if phone := strings.TrimSpace(form.Get("phone")); phone != "" {
+		contact.SetPrimaryPhone(phone)
+	}
+	if form.Has("phoneWork") {
+		contact.Phones = setExtraPhone(contact.Phones, domain.PhoneWork, form.Get("phoneWork"))
+	}
+	if form.Has("phoneHome") {
+		contact.Phones = setExtraPhone(contact.Phones, domain.PhoneHome, form.Get("phoneHome"))
+	}
+
+	if email := strings.TrimSpace(form.Get("email")); email != "" {
+		if len(contact.Emails) == 0 {
+			contact.Emails = []domain.Email{{Address: email}}
+		} else {
+			contact.Emails[0].Address = email
+		}
+	}
+	if form.Has("emailWork") {
+		contact.Emails = setExtraEmail(contact.Emails, "work", form.Get("emailWork"))
+	}
+
+	if form.Has("street") || form.Has("city") || form.Has("postalCode") || form.Has("country") {
+		var address domain.Address
+		if len(contact.Addresses) > 0 {
+			address = contact.Addresses[0]
+		}
+		setString("street", &address.Street)
+		setString("city", &address.City)
+		setString("postalCode", &address.PostalCode)
+		setString("country", &address.Country)
+
+		if len(contact.Addresses) > 0 {
+			contact.Addresses[0] = address
+		} else {
+			address.Type = "home"
+			contact.Addresses = []domain.Address{address}
+		}
+	}
+
+	return contact
+}
+
+// setExtraPhone sets the number of the first non-primary phone of the given
+// type, adding or removing it as needed.
+func setExtraPhone(phones []domain.Phone, phoneType domain.PhoneType, number string) []domain.Phone {
+	number = strings.TrimSpace(number)
+
+	for i := 1; i < len(phones); i++ {
+		if phones[i].Type != phoneType {
+			continue
+		}
+		if number == "" {
+			return append(phones[:i], phones[i+1:]...)
+		}
+		phones[i].Number = number
+		return phones
+	}
+
+	if number == "" {
+		return phones
+	}
+	return append(phones, domain.Phone{Type: phoneType, Number: number})
+}
+
+func setExtraEmail(emails []domain.Email, emailType, address string) []domain.Email {
+	address = strings.TrimSpace(address)
+
+	for i := 1; i < len(emails); i++ {
+		if emails[i].Type != emailType {
+			continue
+		}
+		if address == "" {
+			return append(emails[:i], emails[i+1:]...)
+		}
+		emails[i].Address = address
+		return emails
+	}
+
+	if address == "" {
+		return emails
+	}
+	return append(emails, domain.Email{Type: emailType, Address: address})
+}
+
+// listQuery reads the paging, sorting and filter parameters of a contact list
+// request. Sort and cursor values are checked by the directory.
+func listQuery(values url.Values) (service.ListQuery, error) {
+	query := service.ListQuery{
+		Sort:   service.SortField(values.Get("sort")),
+		Cursor: values.Get("cursor"),
+		Limit:  defaultPageSize,
+		Filter: service.ListFilter{
+			Name:         strings.TrimSpace(values.Get("name")),
+			Phone:        strings.TrimSpace(values.Get("phone")),
+			Email:        strings.TrimSpace(values.Get("email")),
+			Organization: strings.TrimSpace(values.Get("organization")),
+			Tag:          strings.TrimSpace(values.Get("tag")),
+			Group:        strings.TrimSpace(values.Get("group")),
+		},
+	}
+	if query.Sort == "" {
+		query.Sort = service.SortName
+	}
+
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return query, fmt.Errorf("order must be asc or desc")
+	}
+
+	if values.Get("limit") != "" {
+		limit, err := strconv.Atoi(values.Get("limit"))
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return query, fmt.Errorf("limit must be a number between 1 and %d", maxPageSize)
+		}
+		query.Limit = limit
+	}
+	if values.Get("offset") != "" {
+		offset, err := strconv.Atoi(values.Get("offset"))
+		if err != nil || offset < 0 {
+			return query, fmt.Errorf("offset must be a positive number")
+		}
+		query.Offset = offset
+	}
+
+	var err error
+	if values.Get("favorite") != "" {
+		if query.Filter.Favorite, err = strconv.ParseBool(values.Get("favorite")); err != nil {
+			return query, fmt.Errorf("favorite must be true or false")
+		}
+	}
+	if query.Filter.CreatedAfter, err = parseTime(values.Get("createdAfter")); err != nil {
+		return query, fmt.Errorf("createdAfter: %w", err)
+	}
+	if query.Filter.CreatedBefore, err = parseTime(values.Get("createdBefore")); err != nil {
+		return query, fmt.Errorf("createdBefore: %w", err)
+	}
+
+	return query, nil
+}
+
+// parseTime accepts an RFC 3339 timestamp or a YYYY-MM-DD date, read as
+// midnight UTC.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is not a date or an RFC 3339 timestamp", value)
+	}
+	return t, nil
+}
+
+// csvOptions reads the delimiter, mapping and fields parameters of a CSV
+// import or export.
+func csvOptions(values url.Values) (csvfile.Options, error) {
+	var (
+		opts csvfile.Options
+		err  error
+	)
+	if opts.Comma, err = csvfile.ParseDelimiter(values.Get("delimiter")); err != nil {
+		return opts, err
+	}
+	if opts.Mapping, err = csvfile.ParseMapping(values.Get("mapping")); err != nil {
+		return opts, err
+	}
+	if opts.Fields, err = csvfile.ParseFields(values.Get("fields")); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// decodeVCardImport reads an uploaded vCard file, numbering its cards.
+func decodeVCardImport(r io.Reader) (templates.ImportReport, error) {
+	contacts, err := vcard.Decode(r)
+	if err != nil {
+		return templates.ImportReport{}, err
+	}
+	report := templates.ImportReport{Format: "vCard", Contacts: contacts}
+	for i := range contacts {
+		report.Origins = append(report.Origins, fmt.Sprintf("card %d", i+1))
+	}
+	return report, nil
+}
+
+// decodeCSVImport reads an uploaded CSV file, keeping the line of each row
+// and the rows that cannot be read.
+func decodeCSVImport(r io.Reader, opts csvfile.Options) (templates.ImportReport, error) {
+	file, err := csvfile.Decode(r, opts)
+	if err != nil {
+		return templates.ImportReport{}, err
+	}
+	report := templates.ImportReport{Format: "CSV, " + file.Encoding}
+	for _, row := range file.Rows {
+		if row.Err != nil {
+			report.Unreadable = append(report.Unreadable, fmt.Sprintf("line %d: %v", row.Line, row.Err))
+			continue
+		}
+		report.Contacts = append(report.Contacts, row.Contact)
+		report.Origins = append(report.Origins, fmt.Sprintf("line %d", row.Line))
+	}
+	return report, nil
+}
@@ -1,14 +1,43 @@
 package api
 
 import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"path"
+	"strconv"
 	"strings"
 
+	"github.com/LaulauChau/go-directory/internal/csvfile"
+	"github.com/LaulauChau/go-directory/internal/domain"
 	"github.com/LaulauChau/go-directory/internal/service"
+	"github.com/LaulauChau/go-directory/internal/vcard"
 	"github.com/LaulauChau/go-directory/web/templates"
 )
 
+// recentLimit is the number of contacts shown in the Recent section.
+const recentLimit = 8
+
+// contactsChanged is the htmx event sent when contacts changed or were looked
+// up, so the Favorites and Recent sections reload.
+const contactsChanged = "contactsChanged"
+
+// contactsImported is the htmx event sent after an import, so the contact
+// list reloads in place.
+const contactsImported = "contactsImported"
+
+// maxImportSize bounds an uploaded import file.
+const maxImportSize = 10 << 20
+
+// Import and export formats.
+const (
+	formatVCard = "vcf"
+	formatCSV   = "csv"
+)
+
 type Handlers struct {
 	directory *service.Directory
 }
@@ -20,40 +49,84 @@ func NewHandlers(directory *service.Directory) *Handlers {
 }
 
 func (h *Handlers) Index(w http.ResponseWriter, r *http.Request) {
-	contacts := h.directory.ListContacts()
-	component := templates.Index(contacts)
+	query, err := listQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.directory.QueryContacts(query)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	component := templates.Index(page, query, h.directory.ListGroups(), h.directory.ListTags(),
+		h.directory.Favorites(), h.directory.RecentContacts(recentLimit))
 	if err := component.Render(r.Context(), w); err != nil {
 		http.Error(w, "Failed to render template", http.StatusInternalServerError)
 	}
 }
 
-func (h *Handlers) AddContact(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
+// Shortcuts renders the Favorites and Recent sections of the index page.
+func (h *Handlers) Shortcuts(w http.ResponseWriter, r *http.Request) {
+	component := templates.Shortcuts(h.directory.Favorites(), h.directory.RecentContacts(recentLimit))
+	if err := component.Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
 	}
+}
 
-	name := strings.TrimSpace(r.FormValue("name"))
-	phone := strings.TrimSpace(r.FormValue("phone"))
-
-	if name == "" || phone == "" {
-		http.Error(w, "Name and phone are required", http.StatusBadRequest)
+// ListContacts renders one page of the contact list. Requests not made by
+// htmx, such as reloading a pushed page URL, get the whole page instead.
+func (h *Handlers) ListContacts(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") == "" {
+		h.Index(w, r)
 		return
 	}
 
-	err := h.directory.AddContact(name, phone)
+	query, err := listQuery(r.URL.Query())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	contacts := h.directory.ListContacts()
-	component := templates.ContactList(contacts)
+	page, err := h.directory.QueryContacts(query)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	component := templates.ContactList(page, query)
 	if err := component.Render(r.Context(), w); err != nil {
 		http.Error(w, "Failed to render template", http.StatusInternalServerError)
 	}
 }
 
+func (h *Handlers) AddContact(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	contact := contactFromForm(r.PostForm, domain.Contact{})
+	if contact.Name == "" && contact.FirstName == "" && contact.LastName == "" {
+		h.formError(w, r, http.StatusUnprocessableEntity, "Name is required")
+		return
+	}
+	if len(contact.Phones) == 0 && len(contact.Emails) == 0 {
+		h.formError(w, r, http.StatusUnprocessableEntity, "A phone or an email is required")
+		return
+	}
+
+	_, err := h.directory.CreateContact(contact)
+	if err != nil {
+		h.serviceError(w, r, err)
+		return
+	}
+
+	h.renderContactList(w, r)
+}
+
 func (h *Handlers) UpdateContact(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Failed to parse form", http.StatusBadRequest)
@@ -61,48 +134,70 @@ func (h *Handlers) UpdateContact(w http.ResponseWriter, r *http.Request) {
 	}
 
 	path := strings.TrimPrefix(r.URL.Path, "/contacts/")
-	name, err := url.QueryUnescape(path)
+	id, err := url.QueryUnescape(path)
 	if err != nil {
-		http.Error(w, "Invalid contact name", http.StatusBadRequest)
+		http.Error(w, "Invalid contact id", http.StatusBadRequest)
 		return
 	}
 
-	phone := strings.TrimSpace(r.FormValue("phone"))
-	if phone == "" {
-		http.Error(w, "Phone is required", http.StatusBadRequest)
+	contact, err := h.directory.GetContact(id)
+	if err != nil {
+		h.serviceError(w, r, err)
 		return
 	}
 
-	err = h.directory.EditContact(name, phone)
+	err = h.directory.UpdateContact(contactFromForm(r.PostForm, *contact))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.serviceError(w, r, err)
 		return
 	}
 
-	contacts := h.directory.ListContacts()
-	component := templates.ContactList(contacts)
-	if err := component.Render(r.Context(), w); err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
-	}
+	h.renderContactList(w, r)
 }
 
+// DeleteContact moves the contact to the trash and offers to undo it.
 func (h *Handlers) DeleteContact(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/contacts/")
-	name, err := url.QueryUnescape(path)
+	id, err := url.QueryUnescape(path)
 	if err != nil {
-		http.Error(w, "Invalid contact name", http.StatusBadRequest)
+		http.Error(w, "Invalid contact id", http.StatusBadRequest)
 		return
 	}
 
-	err = h.directory.DeleteContact(name)
+	contact, err := h.directory.GetContact(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.serviceError(w, r, err)
 		return
 	}
 
-	contacts := h.directory.ListContacts()
-	component := templates.ContactList(contacts)
-	if err := component.Render(r.Context(), w); err != nil {
+	err = h.directory.DeleteContact(id)
+	if err != nil {
+		h.serviceError(w, r, err)
+		return
+	}
+
+	if h.renderContactList(w, r) {
+		h.renderNotice(w, r, contact)
+	}
+}
+
+// ContactHistory renders the changes recorded for a contact. A contact
+// without recorded changes gets an empty history.
+func (h *Handlers) ContactHistory(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/contacts/"), "/history")
+	id, err := url.QueryUnescape(path)
+	if err != nil {
+		http.Error(w, "Invalid contact id", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.directory.History(id)
+	if err != nil && !errors.Is(err, service.ErrNotFound) {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	if err := templates.ContactHistory(entries).Render(r.Context(), w); err != nil {
 		http.Error(w, "Failed to render template", http.StatusInternalServerError)
 	}
 }
@@ -114,9 +209,428 @@ func (h *Handlers) SearchContact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	matches := h.directory.SearchContacts(query)
+	matches, err := h.directory.Search(query)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		if renderErr := templates.SearchResult(nil, err).Render(r.Context(), w); renderErr != nil {
+			http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if len(matches) == 1 {
+		// A single match was recorded as looked up.
+		w.Header().Set("HX-Trigger", contactsChanged)
+	}
 	component := templates.SearchResults(matches, query)
 	if renderErr := component.Render(r.Context(), w); renderErr != nil {
 		http.Error(w, "Failed to render template", http.StatusInternalServerError)
 	}
 }
+
+// renderContactList renders the list page the user is looking at, read from
+// the page URL htmx sends along, after one of its contacts changed. It
+// reports whether the list was rendered.
+func (h *Handlers) renderContactList(w http.ResponseWriter, r *http.Request) bool {
+	query := service.ListQuery{Sort: service.SortName, Limit: defaultPageSize}
+	if current, err := url.Parse(r.Header.Get("HX-Current-URL")); err == nil {
+		if q, err := listQuery(current.Query()); err == nil && q.Cursor == "" {
+			query = q
+		}
+	}
+
+	page, err := h.directory.QueryContacts(query)
+	if err == nil && len(page.Contacts) == 0 && query.Offset > 0 {
+		// The last contact of the page was deleted, show the one before.
+		query.Offset = max(page.Total-1, 0) / query.Limit * query.Limit
+		page, err = h.directory.QueryContacts(query)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return false
+	}
+
+	w.Header().Set("HX-Trigger", contactsChanged)
+	component := templates.ContactList(page, query)
+	if err := component.Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// renderNotice follows the contact list with the notice offering to undo the
+// deletion of contact, swapped in out of band. A nil contact clears it.
+func (h *Handlers) renderNotice(w http.ResponseWriter, r *http.Request, contact *domain.Contact) {
+	if err := templates.UndoNotice(contact).Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// Export downloads every contact as a vCard or CSV file.
+func (h *Handlers) Export(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	format := cmp.Or(params.Get("format"), formatVCard)
+
+	var (
+		buf         bytes.Buffer
+		contentType string
+	)
+	switch format {
+	case formatVCard:
+		version, err := vcard.ParseVersion(params.Get("version"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := vcard.Encode(&buf, h.directory.ListContacts(), version); err != nil {
+			http.Error(w, "Failed to export contacts", http.StatusInternalServerError)
+			return
+		}
+		contentType = "text/vcard; charset=utf-8"
+	case formatCSV:
+		opts, err := csvOptions(params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := csvfile.Encode(&buf, h.directory.ListContacts(), opts); err != nil {
+			http.Error(w, "Failed to export contacts", http.StatusInternalServerError)
+			return
+		}
+		contentType = "text/csv; charset=utf-8"
+	default:
+		http.Error(w, fmt.Sprintf("unknown format '%s', expected vcf or csv", format), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="contacts.%s"`, format))
+	w.Write(buf.Bytes())
+}
+
+// Import adds the contacts of an uploaded vCard or CSV file and reports what
+// happened to each row. The format comes from the format field, or from the
+// file name. With dryRun set, nothing is stored.
+func (h *Handlers) Import(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Import file is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "An import file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	format := strings.ToLower(r.FormValue("format"))
+	if format == "" {
+		switch strings.ToLower(path.Ext(header.Filename)) {
+		case ".vcf", ".vcard":
+			format = formatVCard
+		case ".csv", ".tsv", ".txt":
+			format = formatCSV
+		}
+	}
+	dryRun, _ := strconv.ParseBool(r.FormValue("dryRun"))
+
+	var report templates.ImportReport
+	switch format {
+	case formatVCard:
+		report, err = decodeVCardImport(file)
+	case formatCSV:
+		var opts csvfile.Options
+		if opts, err = csvOptions(r.MultipartForm.Value); err == nil {
+			report, err = decodeCSVImport(file, opts)
+		}
+	default:
+		err = fmt.Errorf("cannot import '%s', expected a vCard (.vcf) or CSV (.csv) file", header.Filename)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		if renderErr := templates.ImportResult(templates.ImportReport{}, err).Render(r.Context(), w); renderErr != nil {
+			http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	opts := service.ImportOptions{
+		Strategy: service.ConflictStrategy(r.FormValue("onConflict")),
+		DryRun:   dryRun,
+	}
+	if report.Result, err = h.directory.ImportContacts(report.Contacts, opts); err != nil {
+		w.WriteHeader(errorStatus(err))
+		if renderErr := templates.ImportResult(templates.ImportReport{}, err).Render(r.Context(), w); renderErr != nil {
+			http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+	if !dryRun {
+		w.Header().Set("HX-Trigger", contactsChanged+", "+contactsImported)
+	}
+	if err := templates.ImportResult(report, nil).Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// Duplicates renders the page reviewing the likely duplicates.
+func (h *Handlers) Duplicates(w http.ResponseWriter, r *http.Request) {
+	minScore, err := minScoreParam(r.URL.Query().Get("minScore"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	duplicates, err := h.directory.FindDuplicates(minScore)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	component := templates.DuplicatesPage(duplicates, cmp.Or(minScore, service.DefaultDuplicateScore))
+	if err := component.Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// MergeDuplicates merges the contact other into keep, taking the conflicting
+// fields set to "other" from it, and renders the duplicates left.
+func (h *Handlers) MergeDuplicates(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+	minScore, err := minScoreParam(r.PostForm.Get("minScore"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var opts service.MergeOptions
+	for _, field := range service.MergeFields {
+		if r.PostForm.Get(field) == "other" {
+			opts.TakeOther = append(opts.TakeOther, field)
+		}
+	}
+	if _, err := h.directory.MergeContacts(r.PostForm.Get("keep"), r.PostForm.Get("other"), opts); err != nil {
+		h.serviceError(w, r, err)
+		return
+	}
+
+	duplicates, err := h.directory.FindDuplicates(minScore)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("HX-Trigger", contactsChanged)
+	component := templates.DuplicateList(duplicates, cmp.Or(minScore, service.DefaultDuplicateScore))
+	if err := component.Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// Trash renders the page listing the deleted contacts.
+func (h *Handlers) Trash(w http.ResponseWriter, r *http.Request) {
+	component := templates.TrashPage(h.directory.Trash(), h.directory.TrashRetention())
+	if err := component.Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// RestoreContact takes a contact out of the trash. Undoing a deletion from
+// the contact list renders the list, otherwise the trash is rendered.
+func (h *Handlers) RestoreContact(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/trash/"), "/restore")
+	id, err := url.QueryUnescape(path)
+	if err != nil {
+		http.Error(w, "Invalid contact id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.directory.RestoreContact(id); err != nil {
+		h.serviceError(w, r, err)
+		return
+	}
+
+	if r.Header.Get("HX-Target") == "contact-list" {
+		if h.renderContactList(w, r) {
+			h.renderNotice(w, r, nil)
+		}
+		return
+	}
+	w.Header().Set("HX-Trigger", contactsChanged)
+	h.renderTrash(w, r)
+}
+
+// PurgeContact deletes a contact in the trash for good.
+func (h *Handlers) PurgeContact(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/trash/")
+	id, err := url.QueryUnescape(path)
+	if err != nil {
+		http.Error(w, "Invalid contact id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.directory.PurgeContact(id); err != nil {
+		h.serviceError(w, r, err)
+		return
+	}
+
+	h.renderTrash(w, r)
+}
+
+// EmptyTrash deletes every contact in the trash for good.
+func (h *Handlers) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.directory.EmptyTrash(); err != nil {
+		h.serviceError(w, r, err)
+		return
+	}
+
+	h.renderTrash(w, r)
+}
+
+func (h *Handlers) renderTrash(w http.ResponseWriter, r *http.Request) {
+	component := templates.TrashList(h.directory.Trash(), h.directory.TrashRetention())
+	if err := component.Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// Snapshots renders the page listing the snapshots.
+func (h *Handlers) Snapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := h.directory.Snapshots()
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	if err := templates.SnapshotsPage(snapshots).Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// CreateSnapshot takes a snapshot named by the form, or after the current
+// time, and renders the snapshots.
+func (h *Handlers) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.directory.CreateSnapshot(r.PostForm.Get("name")); err != nil {
+		h.serviceError(w, r, err)
+		return
+	}
+
+	h.renderSnapshots(w, r)
+}
+
+// DiffSnapshot renders how the contacts changed since the snapshot.
+func (h *Handlers) DiffSnapshot(w http.ResponseWriter, r *http.Request) {
+	name, err := snapshotName(r.URL.Path, "/diff")
+	if err != nil {
+		http.Error(w, "Invalid snapshot name", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := h.directory.DiffSnapshot(name)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	if err := templates.SnapshotDiff(diff).Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// RestoreSnapshot puts the contacts back as in the snapshot and renders the
+// snapshots, the one saved before the restore included.
+func (h *Handlers) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	name, err := snapshotName(r.URL.Path, "/restore")
+	if err != nil {
+		http.Error(w, "Invalid snapshot name", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.directory.RestoreSnapshot(name); err != nil {
+		h.serviceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", contactsChanged)
+	h.renderSnapshots(w, r)
+}
+
+func (h *Handlers) renderSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := h.directory.Snapshots()
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	if err := templates.SnapshotList(snapshots).Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// snapshotName reads the snapshot name out of a /snapshots/{name}/... path.
+func snapshotName(path, suffix string) (string, error) {
+	return url.QueryUnescape(strings.TrimSuffix(strings.TrimPrefix(path, "/snapshots/"), suffix))
+}
+
+// minScoreParam reads the lowest duplicate score to show, 0 when value is
+// empty.
+func minScoreParam(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	minScore, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid minScore '%s', expected a number from 0 to 1", value)
+	}
+	return minScore, nil
+}
+
+// serviceError reports err with the status matching its kind. Validation
+// errors and conflicts are shown next to the contact form.
+func (h *Handlers) serviceError(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(err)
+	if status == http.StatusUnprocessableEntity || status == http.StatusConflict {
+		h.formError(w, r, status, err.Error())
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// formError renders message in the form's error area rather than in place of
+// the contact list.
+func (h *Handlers) formError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Set("HX-Retarget", "#form-error")
+	w.Header().Set("HX-Reswap", "innerHTML")
+	w.WriteHeader(status)
+	if renderErr := templates.FormError(message).Render(r.Context(), w); renderErr != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrAlreadyExists), errors.Is(err, service.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,615 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/service"
+	"github.com/LaulauChau/go-directory/internal/storage"
+)
+
+type memoryStorage struct {
+	mu        sync.Mutex
+	contacts  []domain.Contact
+	groups    []domain.Group
+	audit     []domain.AuditEntry
+	snapshots []domain.Snapshot
+}
+
+func (m *memoryStorage) Load() ([]domain.Contact, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.contacts, nil
+}
+
+func (m *memoryStorage) Save(contacts []domain.Contact) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.contacts = contacts
+	return nil
+}
+
+func (m *memoryStorage) LoadGroups() ([]domain.Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.groups, nil
+}
+
+func (m *memoryStorage) SaveGroups(groups []domain.Group) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.groups = groups
+	return nil
+}
+
+func (m *memoryStorage) AppendAudit(entries []domain.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = append(m.audit, entries...)
+	return nil
+}
+
+func (m *memoryStorage) LoadAudit() ([]domain.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.audit, nil
+}
+
+func (m *memoryStorage) SaveSnapshot(snapshot domain.Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.snapshots {
+		if existing.Name == snapshot.Name {
+			return storage.ErrSnapshotExists
+		}
+	}
+	m.snapshots = append(m.snapshots, snapshot)
+	return nil
+}
+
+func (m *memoryStorage) LoadSnapshot(name string) (domain.Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, snapshot := range m.snapshots {
+		if snapshot.Name == name {
+			return snapshot, nil
+		}
+	}
+	return domain.Snapshot{}, storage.ErrSnapshotNotFound
+}
+
+func (m *memoryStorage) ListSnapshots() ([]domain.Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.snapshots), nil
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *service.Directory) {
+	t.Helper()
+
+	dir, err := service.NewDirectory(&memoryStorage{})
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	server := httptest.NewServer(NewServer(dir, "0").Routes())
+	t.Cleanup(server.Close)
+	return server, dir
+}
+
+func TestAddContactHandler(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	resp, err := http.PostForm(server.URL+"/contacts", url.Values{"name": {"John Doe"}, "phone": {"1234567890"}})
+	if err != nil {
+		t.Fatalf("Failed to post contact: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	if len(dir.ListContacts()) != 1 {
+		t.Errorf("Expected 1 contact, got %d", len(dir.ListContacts()))
+	}
+}
+
+func TestAddContactHandler_InvalidPhone(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	resp, err := http.PostForm(server.URL+"/contacts", url.Values{"name": {"John Doe"}, "phone": {"abc"}})
+	if err != nil {
+		t.Fatalf("Failed to post contact: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", resp.StatusCode)
+	}
+
+	if target := resp.Header.Get("HX-Retarget"); target != "#form-error" {
+		t.Errorf("Expected error to target '#form-error', got '%s'", target)
+	}
+
+	if len(dir.ListContacts()) != 0 {
+		t.Errorf("Expected no contacts, got %d", len(dir.ListContacts()))
+	}
+}
+
+func TestHandlers_ErrorStatus(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	if err := dir.AddContact("John Doe", "1234567890"); err != nil {
+		t.Fatalf("Failed to add contact: %v", err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/contacts", "name=John+Doe&phone=1234567890", http.StatusConflict},
+		{http.MethodPost, "/contacts", "name=Jane+Doe&phone=1234567890&birthday=soon", http.StatusUnprocessableEntity},
+		{http.MethodPut, "/contacts/missing", "phone=1234567890", http.StatusNotFound},
+		{http.MethodDelete, "/contacts/missing", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send %s %s: %v", tt.method, tt.path, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("Expected status %d for %s %s, got %d", tt.status, tt.method, tt.path, resp.StatusCode)
+		}
+	}
+}
+
+func TestUpdateContactHandler_KeepsFieldsNotInForm(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	created, err := dir.CreateContact(domain.Contact{
+		Name:         "John Doe",
+		Organization: "Acme",
+		Phones:       []domain.Phone{{Type: domain.PhoneMobile, Number: "1234567890"}},
+		Emails:       []domain.Email{{Address: "john@example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+
+	form := url.Values{"phone": {"0987654321"}, "phoneWork": {"5550001111"}}
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/contacts/"+created.ID, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to update contact: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	contact, err := dir.GetContact(created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get contact: %v", err)
+	}
+
+	expected := []domain.Phone{
+		{Type: domain.PhoneMobile, Number: "+10987654321", Display: "+1 098 765 4321"},
+		{Type: domain.PhoneWork, Number: "+15550001111", Display: "+1 555 000 1111"},
+	}
+	if !reflect.DeepEqual(contact.Phones, expected) {
+		t.Errorf("Expected phones %v, got %v", expected, contact.Phones)
+	}
+	if contact.Organization != "Acme" || contact.PrimaryEmail() != "john@example.com" {
+		t.Errorf("Expected organization and email to be kept, got %+v", contact)
+	}
+}
+
+func TestConcurrentHandlers(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	const workers = 20
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("Contact %d", i)
+
+			resp, err := http.PostForm(server.URL+"/contacts", url.Values{"name": {name}, "phone": {"1234567890"}})
+			if err != nil {
+				t.Errorf("Failed to post contact: %v", err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Expected status 200 adding %s, got %d", name, resp.StatusCode)
+				return
+			}
+
+			contact, err := dir.FindByName(name)
+			if err != nil {
+				t.Errorf("Failed to find %s: %v", name, err)
+				return
+			}
+
+			req, _ := http.NewRequest(http.MethodPut, server.URL+"/contacts/"+contact.ID, strings.NewReader("phone=0987654321"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			resp, err = http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("Failed to update contact: %v", err)
+				return
+			}
+			resp.Body.Close()
+
+			resp, err = http.Get(server.URL + "/search?q=" + url.QueryEscape("Contact"))
+			if err != nil {
+				t.Errorf("Failed to search contacts: %v", err)
+				return
+			}
+			resp.Body.Close()
+
+			if i%2 == 0 {
+				req, _ = http.NewRequest(http.MethodDelete, server.URL+"/contacts/"+contact.ID, nil)
+				resp, err = http.DefaultClient.Do(req)
+				if err != nil {
+					t.Errorf("Failed to delete contact: %v", err)
+					return
+				}
+				resp.Body.Close()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	contacts := dir.ListContacts()
+	if len(contacts) != workers/2 {
+		t.Errorf("Expected %d contacts, got %d", workers/2, len(contacts))
+	}
+
+	for _, contact := range contacts {
+		if contact.PrimaryPhone() != "+10987654321" {
+			t.Errorf("Expected phone '+10987654321' for %s, got '%s'", contact.Name, contact.PrimaryPhone())
+		}
+	}
+}
+
+func TestDeleteContactHandler_KeepsListPage(t *testing.T) {
+	server, dir := newTestServer(t)
+	var last domain.Contact
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		last, _ = dir.CreateContact(domain.NewContact(name, "+33612345678"))
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/contacts/"+last.ID, nil)
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("HX-Current-URL", server.URL+"/contacts?sort=name&limit=1&offset=2")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to delete contact: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	// The page the user was on is now empty, so the one before is shown.
+	if !strings.Contains(string(body), "Bob") || strings.Contains(string(body), "Alice") {
+		t.Errorf("Expected the list page holding Bob, got %s", body)
+	}
+	if !strings.Contains(string(body), "2–2 of 2") {
+		t.Errorf("Expected the page range '2–2 of 2', got %s", body)
+	}
+}
+
+func TestContactHistoryHandler(t *testing.T) {
+	server, dir := newTestServer(t)
+	dir.SetActor("tester")
+
+	created, _ := dir.CreateContact(domain.NewContact("John Doe", "+12015550123"))
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/contacts/"+created.ID, strings.NewReader("organization=Acme"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to update contact: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/contacts/" + created.ID + "/history")
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(string(body), "Acme") || !strings.Contains(string(body), "tester") {
+		t.Errorf("Expected the edit and who made it, got %s", body)
+	}
+}
+
+func TestTrashHandlers(t *testing.T) {
+	server, dir := newTestServer(t)
+	john, _ := dir.CreateContact(domain.NewContact("John Doe", "+12015550123"))
+	dir.CreateContact(domain.NewContact("Jane Roe", "+12015550124"))
+
+	send := func(method, path, target string) string {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		req.Header.Set("HX-Request", "true")
+		if target != "" {
+			req.Header.Set("HX-Target", target)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send %s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s: expected status 200, got %d: %s", method, path, resp.StatusCode, body)
+		}
+		return string(body)
+	}
+
+	body := send(http.MethodDelete, "/contacts/"+john.ID, "contact-list")
+	if !strings.Contains(body, "undo-notice") || !strings.Contains(body, "/trash/"+john.ID+"/restore") {
+		t.Errorf("Expected an offer to undo the deletion, got %s", body)
+	}
+	if body := send(http.MethodGet, "/trash", ""); !strings.Contains(body, "John Doe") || strings.Contains(body, "Jane Roe") {
+		t.Errorf("Expected John Doe alone in the trash, got %s", body)
+	}
+
+	// Undoing from the contact list renders the list, John Doe back in it.
+	body = send(http.MethodPost, "/trash/"+john.ID+"/restore", "contact-list")
+	if !strings.Contains(body, "John Doe") || !strings.Contains(body, "Jane Roe") {
+		t.Errorf("Expected the contact list with John Doe restored, got %s", body)
+	}
+	if _, err := dir.GetContact(john.ID); err != nil {
+		t.Errorf("Expected John Doe restored, got %v", err)
+	}
+
+	send(http.MethodDelete, "/contacts/"+john.ID, "contact-list")
+	if body := send(http.MethodDelete, "/trash/"+john.ID, "trash-list"); strings.Contains(body, "John Doe") {
+		t.Errorf("Expected John Doe deleted for good, got %s", body)
+	}
+	if len(dir.Trash()) != 0 {
+		t.Errorf("Expected an empty trash, got %d contacts", len(dir.Trash()))
+	}
+}
+
+func TestSnapshotHandlers(t *testing.T) {
+	server, dir := newTestServer(t)
+	john, _ := dir.CreateContact(domain.NewContact("John Doe", "+12015550123"))
+
+	send := func(method, path, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send %s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	if status, body := send(http.MethodPost, "/snapshots", "name=before-import"); status != http.StatusOK || !strings.Contains(body, "before-import") {
+		t.Fatalf("Expected the new snapshot listed, got %d: %s", status, body)
+	}
+	if status, _ := send(http.MethodPost, "/snapshots", "name=before-import"); status != http.StatusConflict {
+		t.Errorf("Expected status 409 for a taken name, got %d", status)
+	}
+
+	dir.DeleteContact(john.ID)
+	dir.CreateContact(domain.NewContact("Jane Roe", "+12015550124"))
+
+	status, body := send(http.MethodGet, "/snapshots/before-import/diff", "")
+	if status != http.StatusOK || !strings.Contains(body, "Jane Roe") || !strings.Contains(body, "John Doe") {
+		t.Errorf("Expected Jane Roe added and John Doe removed, got %d: %s", status, body)
+	}
+
+	status, body = send(http.MethodPost, "/snapshots/before-import/restore", "")
+	if status != http.StatusOK || !strings.Contains(body, "before-restore-") {
+		t.Errorf("Expected the snapshot taken before the restore listed, got %d: %s", status, body)
+	}
+	if contacts := dir.ListContacts(); len(contacts) != 1 || contacts[0].ID != john.ID {
+		t.Errorf("Expected John Doe back alone, got %+v", contacts)
+	}
+	if status, _ := send(http.MethodGet, "/snapshots/before-import", ""); status != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", status)
+	}
+}
+
+func TestFavoriteHandlers(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	created, err := dir.CreateContact(domain.NewContact("John Doe", "+12015550123"))
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+
+	form := url.Values{"favorite": {"true"}}
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/contacts/"+created.ID, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to update contact: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.Header.Get("HX-Trigger") != contactsChanged {
+		t.Errorf("Expected the %s event to be triggered, got '%s'", contactsChanged, resp.Header.Get("HX-Trigger"))
+	}
+	if favorites := dir.Favorites(); len(favorites) != 1 || favorites[0].ID != created.ID {
+		t.Errorf("Expected John to be a favorite, got %v", favorites)
+	}
+
+	resp, body := doJSON(t, http.MethodGet, server.URL+"/api/v1/contacts/"+created.ID, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if recent := dir.RecentContacts(0); len(recent) != 1 || recent[0].ID != created.ID {
+		t.Errorf("Expected getting a contact to record the lookup, got %v", recent)
+	}
+
+	resp, err = http.Get(server.URL + "/shortcuts")
+	if err != nil {
+		t.Fatalf("Failed to get shortcuts: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(data), "John Doe") {
+		t.Errorf("Expected the shortcuts to list John, got %s", data)
+	}
+}
+
+func TestImportExportHandlers(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	if _, err := dir.CreateContact(domain.NewContact("John Doe", "+12015550123")); err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+
+	resp, err := http.Get(server.URL + "/export?format=vcf&version=4.0")
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	exported, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(exported), "VERSION:4.0\r\n") || !strings.Contains(string(exported), "FN:John Doe\r\n") {
+		t.Fatalf("Expected a vCard 4.0 file with John, got %d: %s", resp.StatusCode, exported)
+	}
+	if disposition := resp.Header.Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment") {
+		t.Errorf("Expected an attachment, got '%s'", disposition)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "contacts.vcf")
+	part.Write(exported)
+	part.Write([]byte("BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Jane Roe\r\nTEL;TYPE=WORK:+1 201 555 0199\r\nEND:VCARD\r\n"))
+	writer.Close()
+
+	resp, err = http.Post(server.URL+"/import", writer.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	summary, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, summary)
+	}
+	if trigger := resp.Header.Get("HX-Trigger"); !strings.Contains(trigger, contactsChanged) || !strings.Contains(trigger, contactsImported) {
+		t.Errorf("Expected the %s and %s events, got '%s'", contactsChanged, contactsImported, trigger)
+	}
+
+	jane, err := dir.FindByName("Jane Roe")
+	if err != nil {
+		t.Fatalf("Expected Jane to be imported, got %v", err)
+	}
+	if len(jane.Phones) != 1 || jane.Phones[0].Type != domain.PhoneWork {
+		t.Errorf("Expected Jane's work phone, got %v", jane.Phones)
+	}
+	if contacts := dir.ListContacts(); len(contacts) != 2 {
+		t.Errorf("Expected John to be skipped as a duplicate, got %d contacts", len(contacts))
+	}
+}
+
+func TestImportHandler_CSVDryRun(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	upload := func(dryRun string) (*http.Response, string) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		writer.WriteField("dryRun", dryRun)
+		writer.WriteField("mapping", "Nom=name,Portable=phone")
+		part, _ := writer.CreateFormFile("file", "people.csv")
+		part.Write([]byte("Nom;Portable;Favori\nZo\xeb;+33612345678;oui\nBob;not a phone;\n"))
+		writer.Close()
+
+		resp, err := http.Post(server.URL+"/import", writer.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatalf("Failed to import: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	resp, report := upload("true")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("HX-Trigger") != "" {
+		t.Errorf("Expected status 200 without events, got %d and '%s'", resp.StatusCode, resp.Header.Get("HX-Trigger"))
+	}
+	if !strings.Contains(report, "line 3") || !strings.Contains(report, "Latin-1") {
+		t.Errorf("Expected the report to name the encoding and the rejected line, got %s", report)
+	}
+	if contacts := dir.ListContacts(); len(contacts) != 0 {
+		t.Errorf("Expected a dry run to store nothing, got %v", contacts)
+	}
+
+	resp, _ = upload("false")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("HX-Trigger") == "" {
+		t.Errorf("Expected status 200 with events, got %d and '%s'", resp.StatusCode, resp.Header.Get("HX-Trigger"))
+	}
+	if contact, err := dir.FindByName("Zoë"); err != nil || contact.PrimaryPhone() != "+33612345678" {
+		t.Errorf("Expected Zoë to be imported from Latin-1, got %v, %v", contact, err)
+	}
+}
+
+func TestMergeDuplicatesHandler(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	keep, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	other := domain.NewContact("Jon Doe", "+1 202 555 0100")
+	other.Organization = "Acme"
+	other, _ = dir.CreateContact(other)
+
+	form := url.Values{"keep": {keep.ID}, "other": {other.ID}, "name": {"other"}}
+	resp, err := http.PostForm(server.URL+"/duplicates/merge", form)
+	if err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("HX-Trigger") != "contactsChanged" {
+		t.Errorf("Expected status 200 with a contactsChanged event, got %d and '%s'", resp.StatusCode, resp.Header.Get("HX-Trigger"))
+	}
+
+	contacts := dir.ListContacts()
+	if len(contacts) != 1 || contacts[0].ID != keep.ID || contacts[0].Name != "Jon Doe" || contacts[0].Organization != "Acme" {
+		t.Errorf("Expected one contact keeping its id with the other's name and organization, got %+v", contacts)
+	}
+
+	resp, err = http.PostForm(server.URL+"/duplicates/merge", form)
+	if err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 once merged, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec documents every route registered by Server.Routes. The contract
+// tests in openapi_test.go fail when the handlers and the document disagree.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func (h *Handlers) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	w.Header().Set("Content-Type", jsonContentType)
+	_, _ = w.Write(openAPISpec)
+}
@@ -0,0 +1,520 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+type openAPIOperation struct {
+	path      string
+	method    string
+	responses map[string]any
+}
+
+// contractCase is one request made against the server. Its path is concrete,
+// the documented operation is found by matching it against the spec.
+type contractCase struct {
+	method      string
+	path        string
+	contentType string
+	accept      string
+	body        string
+	status      int
+}
+
+func loadOpenAPI(t *testing.T) map[string]any {
+	t.Helper()
+
+	var spec map[string]any
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("Failed to parse openapi.json: %v", err)
+	}
+	return spec
+}
+
+func specOperations(spec map[string]any) map[string]openAPIOperation {
+	operations := make(map[string]openAPIOperation)
+	for path, item := range spec["paths"].(map[string]any) {
+		for method, op := range item.(map[string]any) {
+			if method == "parameters" {
+				continue
+			}
+			operation := op.(map[string]any)
+			operations[strings.ToUpper(method)+" "+path] = openAPIOperation{
+				path:      path,
+				method:    strings.ToUpper(method),
+				responses: operation["responses"].(map[string]any),
+			}
+		}
+	}
+	return operations
+}
+
+// matchPath reports whether a concrete path matches a template such as
+// "/contacts/{id}".
+func matchPath(template, path string) bool {
+	templateParts := strings.Split(template, "/")
+	pathParts := strings.Split(path, "/")
+	if len(templateParts) != len(pathParts) {
+		return false
+	}
+	for i, part := range templateParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if pathParts[i] == "" {
+				return false
+			}
+			continue
+		}
+		if part != pathParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOpenAPI_Served(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/api/openapi.json")
+	if err != nil {
+		t.Fatalf("Failed to get openapi.json: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(body, openAPISpec) {
+		t.Errorf("Expected the embedded document with status 200, got %d", resp.StatusCode)
+	}
+
+	spec := loadOpenAPI(t)
+	if version, _ := spec["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got version '%s'", version)
+	}
+}
+
+// TestOpenAPI_DocumentsEveryRoute checks that every route of the server has a
+// path in the document: the exact path, or one below a pattern ending in a
+// slash.
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	spec := loadOpenAPI(t)
+	paths := spec["paths"].(map[string]any)
+
+	for _, route := range NewServer(nil, "0").routes() {
+		documented := false
+		for path := range paths {
+			if path == route.pattern || (strings.HasSuffix(route.pattern, "/") && route.pattern != "/" && strings.HasPrefix(path, route.pattern)) {
+				documented = true
+			}
+		}
+		if !documented {
+			t.Errorf("Route %s is not documented in openapi.json", route.pattern)
+		}
+	}
+}
+
+func TestOpenAPI_Contract(t *testing.T) {
+	server, dir := newTestServer(t)
+	spec := loadOpenAPI(t)
+	operations := specOperations(spec)
+
+	kept, err := dir.CreateContact(domain.NewContact("Ada Lovelace", "+44 7911 123456"))
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	deleted, err := dir.CreateContact(domain.NewContact("Charles Babbage", "+44 7911 654321"))
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	webDeleted, err := dir.CreateContact(domain.NewContact("Mary Somerville", "+44 7911 111111"))
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	twin, err := dir.CreateContact(domain.NewContact("Ada Lovelase", "+44 7911 123456"))
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	trashed, err := dir.CreateContact(domain.NewContact("Alan Turing", "+44 7911 222222"))
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	recreated, err := dir.CreateContact(domain.NewContact("Joan Clarke", "+44 7911 333333"))
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	if err := dir.DeleteContacts(trashed.ID, recreated.ID); err != nil {
+		t.Fatalf("Failed to delete contacts: %v", err)
+	}
+	if _, err := dir.CreateContact(domain.NewContact("Joan Clarke", "+44 7911 333333")); err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	family, err := dir.CreateGroup("Family")
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	if err := dir.AddToGroup(family.ID, kept.ID); err != nil {
+		t.Fatalf("Failed to add contact to group: %v", err)
+	}
+
+	const form = "application/x-www-form-urlencoded"
+	const jsonType = "application/json"
+	id := "/" + kept.ID
+
+	const multipart = "multipart/form-data; boundary=X"
+	// upload builds a multipart body holding a file and name=value fields.
+	upload := func(filename, content string, fields ...string) string {
+		var body strings.Builder
+		for _, field := range fields {
+			name, value, _ := strings.Cut(field, "=")
+			body.WriteString("--X\r\nContent-Disposition: form-data; name=\"" + name + "\"\r\n\r\n" + value + "\r\n")
+		}
+		body.WriteString("--X\r\nContent-Disposition: form-data; name=\"file\"; filename=\"" + filename + "\"\r\n\r\n" + content + "\r\n--X--\r\n")
+		return body.String()
+	}
+	card := "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Katherine Johnson\r\nTEL:+1 757 555 0100\r\nEND:VCARD\r\n"
+
+	cases := []contractCase{
+		{method: "GET", path: "/", status: 200},
+		{method: "GET", path: "/?sort=created&order=desc&limit=10", status: 200},
+		{method: "GET", path: "/?group=Family&tag=vip", status: 200},
+		{method: "GET", path: "/?favorite=true&sort=accessed", status: 200},
+		{method: "GET", path: "/?limit=many", status: 400},
+		{method: "GET", path: "/?sort=age", status: 422},
+		{method: "GET", path: "/api/openapi.json", status: 200},
+
+		{method: "GET", path: "/contacts?name=ada&offset=0", status: 200},
+		{method: "GET", path: "/contacts?group=" + url.QueryEscape(family.ID) + "&offset=0", status: 200},
+		{method: "GET", path: "/contacts?order=up", status: 400},
+		{method: "GET", path: "/contacts?favorite=maybe", status: 400},
+		{method: "GET", path: "/contacts?sort=age", status: 422},
+		{method: "POST", path: "/contacts", contentType: form, body: "name=Grace+Hopper&phone=2015550123", status: 200},
+		{method: "POST", path: "/contacts", contentType: form, body: "name=Grace+Hopper&phone=2015550123", status: 409},
+		{method: "POST", path: "/contacts", contentType: form, body: "name=Alan+Turing&phone=abc", status: 422},
+		{method: "PUT", path: "/contacts" + id, contentType: form, body: "jobTitle=Programmer&tags=vip%2C+pioneer", status: 200},
+		{method: "PUT", path: "/contacts" + id, contentType: form, body: "favorite=true", status: 200},
+		{method: "PUT", path: "/contacts" + id, contentType: form, body: "birthday=yesterday", status: 422},
+		{method: "PUT", path: "/contacts/missing", contentType: form, body: "phone=2015550123", status: 404},
+		{method: "DELETE", path: "/contacts/" + webDeleted.ID, status: 200},
+		{method: "DELETE", path: "/contacts/missing", status: 404},
+		{method: "GET", path: "/contacts" + id + "/history", status: 200},
+		{method: "GET", path: "/contacts/missing/history", status: 200},
+		{method: "GET", path: "/contacts/%25zz/history", status: 400},
+		{method: "GET", path: "/search?q=ada", status: 200},
+		{method: "GET", path: "/search", status: 200},
+		{method: "GET", path: "/search?q=" + url.QueryEscape("org:acme -name:ada"), status: 200},
+		{method: "GET", path: "/search?q=nmae%3Aada", status: 422},
+		{method: "GET", path: "/shortcuts", status: 200},
+		{method: "POST", path: "/import", contentType: multipart, body: upload("contacts.vcf", card), status: 200},
+		{method: "POST", path: "/import", contentType: multipart, body: upload("contacts.vcf", "FN:Nobody\r\n"), status: 422},
+		{method: "POST", path: "/import", contentType: multipart, body: upload("contacts.csv", "Full Name;Mobile\r\nDorothy Vaughan;+1 757 555 0101\r\n"), status: 200},
+		{method: "POST", path: "/import", contentType: multipart, body: upload("people.txt", "Mary Jackson|757 555 0102\r\n", "format=csv", "delimiter=|", "mapping=1=name,2=phone", "dryRun=true"), status: 200},
+		{method: "POST", path: "/import", contentType: multipart, body: upload("people.csv", "Mary Jackson\r\n", "mapping=1=nickname"), status: 422},
+		{method: "POST", path: "/import", contentType: multipart, body: upload("contacts.csv", "phone\r\n+1 757 555 0101\r\n"), status: 422},
+		{method: "POST", path: "/import", contentType: multipart, body: upload("contacts.pdf", card), status: 422},
+		{method: "POST", path: "/import", contentType: multipart, body: upload("contacts.vcf", card, "onConflict=merge"), status: 200},
+		{method: "POST", path: "/import", contentType: multipart, body: upload("contacts.vcf", card, "onConflict=replace"), status: 422},
+		{method: "POST", path: "/import", contentType: multipart, body: "--X--\r\n", status: 400},
+		{method: "GET", path: "/export", status: 200},
+		{method: "GET", path: "/export?format=vcf&version=4.0", status: 200},
+		{method: "GET", path: "/export?version=2.1", status: 400},
+		{method: "GET", path: "/export?format=csv&delimiter=%3B&fields=name,phone", status: 200},
+		{method: "GET", path: "/export?format=csv&fields=nickname", status: 400},
+		{method: "GET", path: "/export?format=xml", status: 400},
+		{method: "GET", path: "/duplicates", status: 200},
+		{method: "GET", path: "/duplicates?minScore=0.5", status: 200},
+		{method: "GET", path: "/duplicates?minScore=high", status: 400},
+		{method: "GET", path: "/duplicates?minScore=2", status: 422},
+		{method: "POST", path: "/duplicates/merge", contentType: form, body: "keep=" + kept.ID + "&other=" + twin.ID + "&name=keep", status: 200},
+		{method: "POST", path: "/duplicates/merge", contentType: form, body: "keep=" + kept.ID + "&other=" + twin.ID, status: 404},
+		{method: "POST", path: "/duplicates/merge", contentType: form, body: "keep=" + kept.ID + "&other=" + kept.ID, status: 422},
+		{method: "POST", path: "/duplicates/merge", contentType: form, body: "keep=%zz", status: 400},
+		{method: "GET", path: "/trash", status: 200},
+		{method: "POST", path: "/trash/" + webDeleted.ID + "/restore", status: 200},
+		{method: "POST", path: "/trash/" + recreated.ID + "/restore", status: 409},
+		{method: "POST", path: "/trash/missing/restore", status: 404},
+		{method: "POST", path: "/trash/%25zz/restore", status: 400},
+		{method: "DELETE", path: "/trash/" + trashed.ID, status: 200},
+		{method: "DELETE", path: "/trash/" + trashed.ID, status: 404},
+		{method: "DELETE", path: "/trash/%25zz", status: 400},
+		{method: "DELETE", path: "/trash", status: 200},
+		{method: "GET", path: "/snapshots", status: 200},
+		{method: "POST", path: "/snapshots", contentType: form, body: "name=before-import", status: 200},
+		{method: "POST", path: "/snapshots", contentType: form, body: "name=before-import", status: 409},
+		{method: "POST", path: "/snapshots", contentType: form, body: "name=..%2Fcontacts", status: 422},
+		{method: "POST", path: "/snapshots", contentType: form, body: "name=%zz", status: 400},
+		{method: "GET", path: "/snapshots/before-import/diff", status: 200},
+		{method: "GET", path: "/snapshots/missing/diff", status: 404},
+		{method: "GET", path: "/snapshots/%25zz/diff", status: 400},
+		{method: "POST", path: "/snapshots/before-import/restore", status: 200},
+		{method: "POST", path: "/snapshots/missing/restore", status: 404},
+		{method: "POST", path: "/snapshots/%25zz/restore", status: 400},
+
+		{method: "GET", path: "/api/v1/contacts", status: 200},
+		{method: "GET", path: "/api/v1/contacts?sort=created&order=desc&limit=1&phone=%2B44&createdAfter=2020-01-01", status: 200},
+		{method: "GET", path: "/api/v1/contacts?group=family&tag=VIP", status: 200},
+		{method: "GET", path: "/api/v1/contacts?favorite=true&sort=accessed&order=desc", status: 200},
+		{method: "GET", path: "/api/v1/contacts?limit=1000", status: 400},
+		{method: "GET", path: "/api/v1/contacts?cursor=bad", status: 422},
+		{method: "GET", path: "/api/v1/contacts?phone=abc", status: 422},
+		{method: "GET", path: "/api/v1/contacts", accept: "text/html", status: 406},
+		{method: "POST", path: "/api/v1/contacts", contentType: jsonType, body: `{"name": "Ada King", "phones": [{"type": "work", "number": "+33 1 44 55 66 77"}]}`, status: 201},
+		{method: "POST", path: "/api/v1/contacts", contentType: jsonType, body: `{"name": "Ada King", "phone": "+33 1 44 55 66 77"}`, status: 409},
+		{method: "POST", path: "/api/v1/contacts", contentType: jsonType, body: `{"phones": [{"number": "abc"}]}`, status: 422},
+		{method: "POST", path: "/api/v1/contacts", contentType: jsonType, body: `{`, status: 400},
+		{method: "POST", path: "/api/v1/contacts", contentType: form, body: "name=Ada", status: 415},
+		{method: "GET", path: "/api/v1/contacts" + id, status: 200},
+		{method: "GET", path: "/api/v1/contacts/missing", status: 404},
+		{method: "PUT", path: "/api/v1/contacts" + id, contentType: jsonType, body: `{"name": "Ada Lovelace", "phones": [{"type": "mobile", "number": "+44 7911 123456"}]}`, status: 200},
+		{method: "PUT", path: "/api/v1/contacts" + id, contentType: jsonType, body: `{"id": "other", "name": "Ada"}`, status: 400},
+		{method: "PUT", path: "/api/v1/contacts/missing", contentType: jsonType, body: `{"name": "Nobody"}`, status: 404},
+		{method: "PATCH", path: "/api/v1/contacts" + id, contentType: mergePatchType, body: `{"notes": "First programmer"}`, status: 200},
+		{method: "PATCH", path: "/api/v1/contacts" + id, contentType: mergePatchType, body: `{"groups": ["missing"]}`, status: 422},
+		{method: "PATCH", path: "/api/v1/contacts" + id, contentType: jsonType, body: `{"birthday": "yesterday"}`, status: 422},
+		{method: "DELETE", path: "/api/v1/contacts/" + deleted.ID, status: 204},
+		{method: "DELETE", path: "/api/v1/contacts/" + deleted.ID, status: 404},
+		{method: "GET", path: "/api/v1/search?q=ada", status: 200},
+		{method: "GET", path: "/api/v1/search", status: 400},
+		{method: "GET", path: "/api/v1/search?q=" + url.QueryEscape("phone:+44* created:>2020-01-01"), status: 200},
+		{method: "GET", path: "/api/v1/search?q=" + url.QueryEscape("(name:ada"), status: 422},
+		{method: "GET", path: "/api/v1/search?q=" + url.QueryEscape("group:family tag:vip"), status: 200},
+		{method: "GET", path: "/api/v1/groups", status: 200},
+		{method: "GET", path: "/api/v1/groups", accept: "text/html", status: 406},
+	}
+
+	exercised := make(map[string]bool)
+	for _, tc := range cases {
+		name := tc.method + " " + tc.path
+
+		key := ""
+		for candidate, op := range operations {
+			if op.method == tc.method && matchPath(op.path, strings.Split(tc.path, "?")[0]) {
+				key = candidate
+			}
+		}
+		if key == "" {
+			t.Errorf("%s is not documented in openapi.json", name)
+			continue
+		}
+		exercised[key] = true
+
+		resp, body := sendContractRequest(t, server.URL, tc)
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: expected status %d, got %d: %s", name, tc.status, resp.StatusCode, body)
+			continue
+		}
+
+		response, ok := operations[key].responses[strconv.Itoa(resp.StatusCode)].(map[string]any)
+		if !ok {
+			t.Errorf("%s: status %d is not documented for %s", name, resp.StatusCode, key)
+			continue
+		}
+		response = resolveRef(spec, response)
+
+		if err := checkResponse(spec, response, resp, body); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	var missing []string
+	for key := range operations {
+		if !exercised[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		t.Errorf("Documented operation %s is not exercised by the contract test", key)
+	}
+}
+
+// TestOpenAPI_UndocumentedMethods checks that handlers reject every method the
+// document does not list, so new behaviour cannot be added without it.
+func TestOpenAPI_UndocumentedMethods(t *testing.T) {
+	server, _ := newTestServer(t)
+	spec := loadOpenAPI(t)
+
+	methods := []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	for path, item := range spec["paths"].(map[string]any) {
+		// The index also answers for unknown paths.
+		if path == "/" {
+			continue
+		}
+
+		documented := item.(map[string]any)
+		concrete := strings.ReplaceAll(path, "{id}", "some-id")
+		for _, method := range methods {
+			if _, ok := documented[strings.ToLower(method)]; ok {
+				continue
+			}
+
+			resp, _ := sendContractRequest(t, server.URL, contractCase{method: method, path: concrete})
+			if resp.StatusCode != http.StatusMethodNotAllowed {
+				t.Errorf("Expected %s %s to be rejected with 405, got %d", method, path, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func sendContractRequest(t *testing.T, baseURL string, tc contractCase) (*http.Response, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(tc.method, baseURL+tc.path, strings.NewReader(tc.body))
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	if tc.contentType != "" {
+		req.Header.Set("Content-Type", tc.contentType)
+	}
+	if tc.accept != "" {
+		req.Header.Set("Accept", tc.accept)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send %s %s: %v", tc.method, tc.path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return resp, body
+}
+
+func checkResponse(spec, response map[string]any, resp *http.Response, body []byte) error {
+	content, ok := response["content"].(map[string]any)
+	if !ok {
+		if len(body) != 0 {
+			return fmt.Errorf("expected no body, got %s", body)
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("invalid Content-Type '%s'", resp.Header.Get("Content-Type"))
+	}
+	media, ok := content[mediaType].(map[string]any)
+	if !ok {
+		return fmt.Errorf("Content-Type %s is not documented", mediaType)
+	}
+
+	if headers, ok := response["headers"].(map[string]any); ok {
+		for header := range headers {
+			if resp.Header.Get(header) == "" {
+				return fmt.Errorf("expected documented header %s", header)
+			}
+		}
+	}
+
+	if mediaType != jsonContentType {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("invalid JSON body: %v", err)
+	}
+	return validateSchema(spec, media["schema"].(map[string]any), value, "body")
+}
+
+func resolveRef(spec, node map[string]any) map[string]any {
+	ref, ok := node["$ref"].(string)
+	if !ok {
+		return node
+	}
+
+	var current any = spec
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		current = current.(map[string]any)[part]
+	}
+	return resolveRef(spec, current.(map[string]any))
+}
+
+// validateSchema checks value against the subset of JSON Schema used by
+// openapi.json. Properties missing from the schema are reported, so new
+// response fields have to be documented.
+func validateSchema(spec, schema map[string]any, value any, at string) error {
+	schema = resolveRef(spec, schema)
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %T", at, value)
+		}
+
+		for _, name := range asStrings(schema["required"]) {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s: missing required property '%s'", at, name)
+			}
+		}
+
+		properties, hasProperties := schema["properties"].(map[string]any)
+		for name, property := range object {
+			if !hasProperties {
+				break
+			}
+			propertySchema, ok := properties[name].(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s: undocumented property '%s'", at, name)
+			}
+			if err := validateSchema(spec, propertySchema, property, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an array, got %T", at, value)
+		}
+		for i, item := range items {
+			if err := validateSchema(spec, schema["items"].(map[string]any), item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string, got %T", at, value)
+		}
+		if enum := asStrings(schema["enum"]); enum != nil && !slices.Contains(enum, text) {
+			return fmt.Errorf("%s: '%s' is not one of %v", at, text, enum)
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return fmt.Errorf("%s: expected an integer, got %v", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %T", at, value)
+		}
+	}
+
+	return nil
+}
+
+func asStrings(value any) []string {
+	list, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	strs := make([]string, len(list))
+	for i, item := range list {
+		strs[i], _ = item.(string)
+	}
+	return strs
+}
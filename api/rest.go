@@ -0,0 +1,340 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/service"
+)
+
+const (
+	apiPrefix         = "/api/v1"
+	jsonContentType   = "application/json"
+	mergePatchType    = "application/merge-patch+json"
+	maxRequestBodyLen = 1 << 20
+)
+
+type contactsResponse struct {
+	Contacts []domain.Contact `json:"contacts"`
+}
+
+type contactPageResponse struct {
+	Contacts   []domain.Contact `json:"contacts"`
+	Total      int              `json:"total"`
+	Offset     int              `json:"offset"`
+	Limit      int              `json:"limit"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+type groupsResponse struct {
+	Groups []service.GroupSummary `json:"groups"`
+}
+
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Status  int                  `json:"status"`
+	Code    string               `json:"code"`
+	Message string               `json:"message"`
+	Fields  []service.FieldError `json:"fields,omitempty"`
+}
+
+func (h *Handlers) APIListContacts(w http.ResponseWriter, r *http.Request) {
+	query, err := listQuery(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := h.directory.QueryContacts(query)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, contactPageResponse{
+		Contacts:   nonNil(page.Contacts),
+		Total:      page.Total,
+		Offset:     page.Offset,
+		Limit:      page.Limit,
+		NextCursor: page.NextCursor,
+	})
+}
+
+func (h *Handlers) APISearchContacts(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeAPIError(w, http.StatusBadRequest, "query parameter 'q' is required")
+		return
+	}
+
+	matches, err := h.directory.Search(query)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, contactsResponse{Contacts: nonNil(matches)})
+}
+
+func (h *Handlers) APIListGroups(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, groupsResponse{Groups: h.directory.ListGroups()})
+}
+
+func (h *Handlers) APIGetContact(w http.ResponseWriter, r *http.Request, id string) {
+	contact, err := h.directory.ViewContact(id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, contact)
+}
+
+func (h *Handlers) APICreateContact(w http.ResponseWriter, r *http.Request) {
+	var contact domain.Contact
+	if !decodeJSON(w, r, &contact, jsonContentType) {
+		return
+	}
+
+	created, err := h.directory.CreateContact(contact)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", apiPrefix+"/contacts/"+url.PathEscape(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// APIReplaceContact replaces every field of the contact, so fields missing
+// from the body are cleared.
+func (h *Handlers) APIReplaceContact(w http.ResponseWriter, r *http.Request, id string) {
+	var contact domain.Contact
+	if !decodeJSON(w, r, &contact, jsonContentType) {
+		return
+	}
+	if contact.ID != "" && contact.ID != id {
+		writeAPIError(w, http.StatusBadRequest, "contact id in the body does not match the url")
+		return
+	}
+	contact.ID = id
+
+	h.saveContact(w, contact)
+}
+
+// APIPatchContact applies a JSON merge patch (RFC 7386) to the contact:
+// fields in the body are replaced, null removes them and others are kept.
+// The contact is read and written in one batch, so concurrent changes to
+// it are not lost.
+func (h *Handlers) APIPatchContact(w http.ResponseWriter, r *http.Request, id string) {
+	var patch map[string]any
+	if !decodeJSON(w, r, &patch, jsonContentType, mergePatchType) {
+		return
+	}
+
+	var (
+		updated  domain.Contact
+		badPatch error
+	)
+	err := h.directory.Batch(func(tx *service.Tx) error {
+		current, err := tx.Get(id)
+		if err != nil {
+			return err
+		}
+
+		var document map[string]any
+		data, _ := json.Marshal(current)
+		_ = json.Unmarshal(data, &document)
+
+		data, _ = json.Marshal(mergePatch(document, patch))
+		var contact domain.Contact
+		if err := json.Unmarshal(data, &contact); err != nil {
+			badPatch = err
+			return err
+		}
+		contact.ID = id
+
+		if err := tx.Update(contact); err != nil {
+			return err
+		}
+		updated, err = tx.Get(id)
+		return err
+	})
+	if badPatch != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid patch: %v", badPatch))
+		return
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handlers) APIDeleteContact(w http.ResponseWriter, r *http.Request, id string) {
+	if err := h.directory.DeleteContact(id); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) saveContact(w http.ResponseWriter, contact domain.Contact) {
+	if err := h.directory.UpdateContact(contact); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	updated, err := h.directory.GetContact(contact.ID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// mergePatch applies patch to document as described by RFC 7386.
+func mergePatch(document, patch map[string]any) map[string]any {
+	if document == nil {
+		document = make(map[string]any)
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(document, key)
+			continue
+		}
+
+		if object, ok := value.(map[string]any); ok {
+			existing, _ := document[key].(map[string]any)
+			document[key] = mergePatch(existing, object)
+			continue
+		}
+
+		document[key] = value
+	}
+
+	return document
+}
+
+// acceptsJSON reports whether the Accept header allows a JSON response.
+func acceptsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mediaType {
+		case jsonContentType, "application/*", "*/*":
+			return true
+		}
+	}
+	return false
+}
+
+// decodeJSON reads the request body into v, writing the error response and
+// returning false when the body is not acceptable JSON.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any, contentTypes ...string) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	supported := false
+	for _, contentType := range contentTypes {
+		supported = supported || (err == nil && mediaType == contentType)
+	}
+	if !supported {
+		writeAPIError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("content type must be %s", strings.Join(contentTypes, " or ")))
+		return false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyLen))
+	if err := decoder.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeAPIError(w, http.StatusRequestEntityTooLarge, "request body is too large")
+			return false
+		}
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON body: %v", err))
+		return false
+	}
+	if decoder.More() {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body: unexpected data after the first value")
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", jsonContentType+"; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: apiError{
+		Status:  status,
+		Code:    errorCode(status),
+		Message: message,
+	}})
+}
+
+func writeServiceError(w http.ResponseWriter, err error) {
+	status := errorStatus(err)
+	body := apiError{Status: status, Code: errorCode(status), Message: err.Error()}
+	if errors.Is(err, service.ErrAlreadyExists) {
+		body.Code = "already_exists"
+	}
+
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		body.Fields = validationErr.Fields
+	}
+
+	writeJSON(w, status, errorResponse{Error: body})
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusNotAcceptable:
+		return "not_acceptable"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusUnprocessableEntity:
+		return "validation_failed"
+	default:
+		return "internal_error"
+	}
+}
+
+func nonNil(contacts []domain.Contact) []domain.Contact {
+	if contacts == nil {
+		return []domain.Contact{}
+	}
+	return contacts
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/service"
+)
+
+func doJSON(t *testing.T, method, url, body string) (*http.Response, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send %s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return resp, data
+}
+
+func TestAPI_ContactLifecycle(t *testing.T) {
+	server, _ := newTestServer(t)
+	base := server.URL + "/api/v1/contacts"
+
+	resp, body := doJSON(t, http.MethodPost, base, `{"name": "Ada Lovelace", "organization": "Engines", "phones": [{"type": "work", "number": "+44 7911 123456"}]}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Expected a JSON response, got '%s'", ct)
+	}
+
+	var created domain.Contact
+	if err := json.Unmarshal(body, &created); err != nil {
+		t.Fatalf("Failed to decode contact: %v", err)
+	}
+	if created.ID == "" || created.PrimaryPhone() != "+447911123456" {
+		t.Errorf("Expected a stored contact with a normalized phone, got %+v", created)
+	}
+	if location := resp.Header.Get("Location"); location != "/api/v1/contacts/"+created.ID {
+		t.Errorf("Expected Location '/api/v1/contacts/%s', got '%s'", created.ID, location)
+	}
+
+	resp, body = doJSON(t, http.MethodGet, base+"/"+created.ID, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 getting contact, got %d: %s", resp.StatusCode, body)
+	}
+
+	resp, body = doJSON(t, http.MethodPatch, base+"/"+created.ID, `{"jobTitle": "Programmer", "organization": null}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 patching contact, got %d: %s", resp.StatusCode, body)
+	}
+	var patched domain.Contact
+	_ = json.Unmarshal(body, &patched)
+	if patched.JobTitle != "Programmer" || patched.Organization != "" || patched.PrimaryPhone() != "+447911123456" {
+		t.Errorf("Expected patch to set the title, clear the organization and keep the phone, got %+v", patched)
+	}
+
+	resp, body = doJSON(t, http.MethodPut, base+"/"+created.ID, `{"name": "Ada King", "phones": [{"type": "home", "number": "+33 1 44 55 66 77"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 replacing contact, got %d: %s", resp.StatusCode, body)
+	}
+	var replaced domain.Contact
+	_ = json.Unmarshal(body, &replaced)
+	if replaced.Name != "Ada King" || replaced.JobTitle != "" || replaced.PrimaryPhone() != "+33144556677" {
+		t.Errorf("Expected put to replace every field, got %+v", replaced)
+	}
+
+	resp, body = doJSON(t, http.MethodGet, server.URL+"/api/v1/search?q=king", "")
+	var results contactsResponse
+	_ = json.Unmarshal(body, &results)
+	if resp.StatusCode != http.StatusOK || len(results.Contacts) != 1 {
+		t.Errorf("Expected one search result, got %d: %s", resp.StatusCode, body)
+	}
+
+	resp, _ = doJSON(t, http.MethodDelete, base+"/"+created.ID, "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status 204 deleting contact, got %d", resp.StatusCode)
+	}
+
+	resp, body = doJSON(t, http.MethodGet, base, "")
+	var list contactsResponse
+	_ = json.Unmarshal(body, &list)
+	if resp.StatusCode != http.StatusOK || list.Contacts == nil || len(list.Contacts) != 0 {
+		t.Errorf("Expected an empty contact list, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+// slowStorage takes a while to save, as a disk does, so that concurrent
+// requests overlap.
+type slowStorage struct {
+	*memoryStorage
+}
+
+func (s slowStorage) Save(contacts []domain.Contact) error {
+	time.Sleep(10 * time.Millisecond)
+	return s.memoryStorage.Save(contacts)
+}
+
+func TestAPI_ConcurrentPatches(t *testing.T) {
+	dir, err := service.NewDirectory(slowStorage{&memoryStorage{}})
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	handlers := NewHandlers(dir)
+
+	contact, err := dir.CreateContact(domain.NewContact("Ada Lovelace", "+44 7911 123456"))
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+
+	fields := []string{"organization", "jobTitle", "notes"}
+	for round := range 20 {
+		value := fmt.Sprintf("round %d", round)
+
+		// Every patch starts at once, to read the contact before the
+		// others store theirs if nothing keeps them apart.
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for _, field := range fields {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := httptest.NewRequest(http.MethodPatch, "/api/v1/contacts/"+contact.ID, strings.NewReader(fmt.Sprintf(`{%q: %q}`, field, value)))
+				req.Header.Set("Content-Type", mergePatchType)
+				rec := httptest.NewRecorder()
+				<-start
+				handlers.APIPatchContact(rec, req, contact.ID)
+				if rec.Code != http.StatusOK {
+					t.Errorf("Expected status 200 patching %s, got %d: %s", field, rec.Code, rec.Body)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		stored, err := dir.GetContact(contact.ID)
+		if err != nil {
+			t.Fatalf("Failed to get contact: %v", err)
+		}
+		if stored.Organization != value || stored.JobTitle != value || stored.Notes != value {
+			t.Fatalf("Expected every field patched to '%s', got %+v", value, stored)
+		}
+	}
+}
+
+func TestAPI_Errors(t *testing.T) {
+	server, dir := newTestServer(t)
+	base := server.URL + "/api/v1/contacts"
+
+	if err := dir.AddContact("John Doe", "1234567890"); err != nil {
+		t.Fatalf("Failed to add contact: %v", err)
+	}
+
+	tests := []struct {
+		method string
+		url    string
+		body   string
+		status int
+		code   string
+	}{
+		{http.MethodGet, base + "/missing", "", http.StatusNotFound, "not_found"},
+		{http.MethodDelete, base + "/missing", "", http.StatusNotFound, "not_found"},
+		{http.MethodPost, base, `{"name": "John Doe", "phone": "123-456-7890"}`, http.StatusConflict, "already_exists"},
+		{http.MethodPost, base, `{"name": "Jane Doe", "phones": [{"number": "abc"}]}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{http.MethodPost, base, `{"name": `, http.StatusBadRequest, "bad_request"},
+		{http.MethodPut, base, "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{http.MethodGet, server.URL + "/api/v1/search", "", http.StatusBadRequest, "bad_request"},
+	}
+
+	for _, tt := range tests {
+		resp, body := doJSON(t, tt.method, tt.url, tt.body)
+
+		var decoded errorResponse
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			t.Errorf("Expected a JSON error body for %s %s, got %s", tt.method, tt.url, body)
+			continue
+		}
+		if resp.StatusCode != tt.status || decoded.Error.Status != tt.status || decoded.Error.Code != tt.code {
+			t.Errorf("Expected %d %s for %s %s, got %d %+v", tt.status, tt.code, tt.method, tt.url, resp.StatusCode, decoded.Error)
+		}
+	}
+}
+
+func TestAPI_ValidationFields(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	resp, body := doJSON(t, http.MethodPost, server.URL+"/api/v1/contacts", `{"phones": [{"number": "abc"}]}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422, got %d", resp.StatusCode)
+	}
+
+	var decoded struct {
+		Error struct {
+			Fields []struct {
+				Field string `json:"field"`
+			} `json:"fields"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &decoded)
+
+	if len(decoded.Error.Fields) != 2 || decoded.Error.Fields[0].Field != "name" || decoded.Error.Fields[1].Field != "phones[0].number" {
+		t.Errorf("Expected errors for name and phones[0].number, got %s", body)
+	}
+}
+
+func TestAPI_ContentNegotiation(t *testing.T) {
+	server, _ := newTestServer(t)
+	base := server.URL + "/api/v1/contacts"
+
+	req, _ := http.NewRequest(http.MethodGet, base, nil)
+	req.Header.Set("Accept", "text/html")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to list contacts: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotAcceptable {
+		t.Errorf("Expected status 406 for Accept: text/html, got %d", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, base, nil)
+	req.Header.Set("Accept", "text/html, application/json;q=0.9")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to list contacts: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 when JSON is accepted, got %d", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, base, strings.NewReader("name=John"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to post contact: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status 415 for a form body, got %d", resp.StatusCode)
+	}
+}
+
+func TestAPI_ListPagination(t *testing.T) {
+	server, dir := newTestServer(t)
+	for _, name := range []string{"Dave", "Carol", "Bob", "Alice", "Eve"} {
+		if _, err := dir.CreateContact(domain.NewContact(name, "+33612345678")); err != nil {
+			t.Fatalf("Failed to create contact: %v", err)
+		}
+	}
+
+	var names []string
+	url := server.URL + "/api/v1/contacts?limit=2"
+	for pages := 0; url != ""; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected 3 pages, still following cursors: %v", names)
+		}
+
+		resp, body := doJSON(t, http.MethodGet, url, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, body)
+		}
+
+		var page contactPageResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			t.Fatalf("Failed to decode page: %v", err)
+		}
+		if page.Total != 5 || page.Limit != 2 {
+			t.Errorf("Expected total 5 and limit 2, got %d and %d", page.Total, page.Limit)
+		}
+		for _, contact := range page.Contacts {
+			names = append(names, contact.Name)
+		}
+
+		url = ""
+		if page.NextCursor != "" {
+			url = server.URL + "/api/v1/contacts?limit=2&cursor=" + page.NextCursor
+		}
+	}
+
+	if got := strings.Join(names, ","); got != "Alice,Bob,Carol,Dave,Eve" {
+		t.Errorf("Expected every contact sorted by name, got %s", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -22,17 +23,50 @@ func NewServer(directory *service.Directory, port string) *Server {
 	}
 }
 
-func (s *Server) Start() error {
-	mux := http.NewServeMux()
+// route is a pattern of the server's mux with its handler.
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// routes lists every route Routes registers, each documented in
+// openapi.json.
+func (s *Server) routes() []route {
+	return []route{
+		{"/", html(s.handlers.Index)},
+		{"/contacts", html(s.handleContacts)},
+		{"/contacts/", html(s.handleContactsWithPath)},
+		{"/search", html(s.handleSearch)},
+		{"/shortcuts", html(s.handleShortcuts)},
+		{"/import", html(s.handleImport)},
+		{"/export", s.handleExport},
+		{"/duplicates", html(s.handleDuplicates)},
+		{"/duplicates/merge", html(s.handleMergeDuplicates)},
+		{"/trash", html(s.handleTrash)},
+		{"/trash/", html(s.handleTrashWithPath)},
+		{"/snapshots", html(s.handleSnapshots)},
+		{"/snapshots/", html(s.handleSnapshotsWithPath)},
 
-	mux.HandleFunc("/", s.handlers.Index)
-	mux.HandleFunc("/contacts", s.handleContacts)
-	mux.HandleFunc("/contacts/", s.handleContactsWithPath)
-	mux.HandleFunc("/search", s.handlers.SearchContact)
+		{"/api/openapi.json", s.handlers.OpenAPI},
+		{apiPrefix + "/contacts", s.api(s.handleAPIContacts)},
+		{apiPrefix + "/contacts/", s.api(s.handleAPIContact)},
+		{apiPrefix + "/search", s.api(s.handleAPISearch)},
+		{apiPrefix + "/groups", s.api(s.handleAPIGroups)},
+	}
+}
 
+func (s *Server) Routes() http.Handler {
+	mux := http.NewServeMux()
+	for _, r := range s.routes() {
+		mux.HandleFunc(r.pattern, r.handler)
+	}
+	return mux
+}
+
+func (s *Server) Start() error {
 	server := &http.Server{
 		Addr:         ":" + s.port,
-		Handler:      mux,
+		Handler:      s.Routes(),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -44,6 +78,8 @@ func (s *Server) Start() error {
 
 func (s *Server) handleContacts(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
+	case http.MethodGet:
+		s.handlers.ListContacts(w, r)
 	case http.MethodPost:
 		s.handlers.AddContact(w, r)
 	default:
@@ -51,6 +87,125 @@ func (s *Server) handleContacts(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handlers.SearchContact(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleShortcuts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handlers.Shortcuts(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.handlers.Import(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handlers.Export(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleDuplicates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handlers.Duplicates(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleMergeDuplicates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.handlers.MergeDuplicates(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handlers.Trash(w, r)
+	case http.MethodDelete:
+		s.handlers.EmptyTrash(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleTrashWithPath(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/restore") {
+		switch r.Method {
+		case http.MethodPost:
+			s.handlers.RestoreContact(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		s.handlers.PurgeContact(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handlers.Snapshots(w, r)
+	case http.MethodPost:
+		s.handlers.CreateSnapshot(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleSnapshotsWithPath(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/diff") {
+		switch r.Method {
+		case http.MethodGet:
+			s.handlers.DiffSnapshot(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/restore") {
+		switch r.Method {
+		case http.MethodPost:
+			s.handlers.RestoreSnapshot(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
 func (s *Server) handleContactsWithPath(w http.ResponseWriter, r *http.Request) {
 
 	if !strings.HasPrefix(r.URL.Path, "/contacts/") {
@@ -58,6 +213,16 @@ func (s *Server) handleContactsWithPath(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if strings.HasSuffix(r.URL.Path, "/history") {
+		switch r.Method {
+		case http.MethodGet:
+			s.handlers.ContactHistory(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
 	switch r.Method {
 	case http.MethodPut:
 		s.handlers.UpdateContact(w, r)
@@ -68,6 +233,82 @@ func (s *Server) handleContactsWithPath(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// html marks the responses of next as HTML. http.Error still switches error
+// messages to plain text.
+func html(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		next(w, r)
+	}
+}
+
+// api wraps a JSON API handler with content negotiation, so clients that
+// cannot read JSON get a 406 rather than a body they do not understand.
+func (s *Server) api(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsJSON(r) {
+			writeAPIError(w, http.StatusNotAcceptable, "this endpoint only serves "+jsonContentType)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleAPIContacts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handlers.APIListContacts(w, r)
+	case http.MethodPost:
+		s.handlers.APICreateContact(w, r)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (s *Server) handleAPIContact(w http.ResponseWriter, r *http.Request) {
+	id, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, apiPrefix+"/contacts/"))
+	if err != nil || id == "" || strings.Contains(id, "/") {
+		writeAPIError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handlers.APIGetContact(w, r, id)
+	case http.MethodPut:
+		s.handlers.APIReplaceContact(w, r, id)
+	case http.MethodPatch:
+		s.handlers.APIPatchContact(w, r, id)
+	case http.MethodDelete:
+		s.handlers.APIDeleteContact(w, r, id)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func (s *Server) handleAPISearch(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handlers.APISearchContacts(w, r)
+	default:
+		methodNotAllowed(w, http.MethodGet)
+	}
+}
+
+func (s *Server) handleAPIGroups(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handlers.APIListGroups(w, r)
+	default:
+		methodNotAllowed(w, http.MethodGet)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed, use "+strings.Join(allowed, ", "))
+}
+
 func (s *Server) StartWithGracefulShutdown() {
 	if err := s.Start(); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
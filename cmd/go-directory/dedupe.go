@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/service"
+)
+
+// handleDedupe lists the likely duplicates, or merges --merge into --id.
+func handleDedupe(directory *service.Directory, id string, f *dedupeFlags) int {
+	if id != "" || *f.merge != "" {
+		return handleMerge(directory, id, f)
+	}
+
+	duplicates, err := directory.FindDuplicates(*f.minScore)
+	if err != nil {
+		fmt.Printf("Error finding duplicates: %v\n", err)
+		return exitCode(err)
+	}
+	if len(duplicates) == 0 {
+		fmt.Println("No likely duplicates found")
+		return exitOK
+	}
+
+	fmt.Printf("Found %d likely duplicate(s), most likely first:\n", len(duplicates))
+	fmt.Println("-------------------")
+	for _, duplicate := range duplicates {
+		fmt.Printf("Score %.2f: %s\n", duplicate.Score, strings.Join(duplicate.Reasons, ", "))
+		fmt.Printf("  Keep:  %s\n", duplicateLine(duplicate.Keep))
+		fmt.Printf("  Merge: %s\n", duplicateLine(duplicate.Other))
+		for _, conflict := range duplicate.Conflicts {
+			fmt.Printf("  Conflict on %s: '%s' is kept over '%s'\n", conflict.Field, conflict.Keep, conflict.Other)
+		}
+
+		command := fmt.Sprintf("--action dedupe --id %s --merge %s", duplicate.Keep.ID, duplicate.Other.ID)
+		if len(duplicate.Conflicts) > 0 {
+			command += " [--take " + conflictFields(duplicate.Conflicts) + "]"
+		}
+		fmt.Printf("  To merge: %s\n", command)
+		fmt.Println("-------------------")
+	}
+
+	return exitOK
+}
+
+func handleMerge(directory *service.Directory, keepID string, f *dedupeFlags) int {
+	if keepID == "" || *f.merge == "" {
+		fmt.Println("Error: --id and --merge are required to merge contacts with dedupe action")
+		return exitUsage
+	}
+
+	var opts service.MergeOptions
+	for _, field := range strings.Split(*f.take, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			opts.TakeOther = append(opts.TakeOther, field)
+		}
+	}
+
+	merged, err := directory.MergeContacts(keepID, *f.merge, opts)
+	if err != nil {
+		fmt.Printf("Error merging contacts: %v\n", err)
+		return exitCode(err)
+	}
+
+	fmt.Printf("Contacts merged into '%s' (id: %s)\n", merged.Name, merged.ID)
+	fmt.Println("-------------------")
+	printContact(merged, groupNames(directory))
+	return exitOK
+}
+
+// duplicateLine sums contact up for telling duplicates apart.
+func duplicateLine(contact domain.Contact) string {
+	line := shortcutLine(contact)
+	if contact.Organization != "" {
+		line += ", " + contact.Organization
+	}
+	return fmt.Sprintf("%s (id: %s)", line, contact.ID)
+}
+
+func conflictFields(conflicts []service.MergeConflict) string {
+	fields := make([]string, len(conflicts))
+	for i, conflict := range conflicts {
+		fields[i] = conflict.Field
+	}
+	return strings.Join(fields, ",")
+}
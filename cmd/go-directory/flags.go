@@ -0,0 +1,276 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/service"
+)
+
+// listFlag collects every occurrence of a repeatable flag.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseArgs parses the command line and returns the arguments left among
+// the flags, e.g. the command and name of --action snapshot diff
+// before-import. Flags may follow the arguments.
+func parseArgs() []string {
+	flag.Parse()
+
+	var args []string
+	for flag.NArg() > 0 {
+		args = append(args, flag.Arg(0))
+		_ = flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	return args
+}
+
+// contactFlags holds the optional contact fields accepted by add and edit.
+type contactFlags struct {
+	first     *string
+	last      *string
+	org       *string
+	title     *string
+	birthday  *string
+	notes     *string
+	favorite  *bool
+	phones    listFlag
+	emails    listFlag
+	addresses listFlag
+	tags      listFlag
+}
+
+func registerContactFlags() *contactFlags {
+	f := &contactFlags{
+		first:    flag.String("first", "", "First name"),
+		last:     flag.String("last", "", "Last name"),
+		org:      flag.String("org", "", "Organization"),
+		title:    flag.String("title", "", "Job title"),
+		birthday: flag.String("birthday", "", "Birthday (YYYY-MM-DD)"),
+		notes:    flag.String("notes", "", "Free-form notes"),
+		favorite: flag.Bool("favorite", false, "Mark as a favorite, --favorite=false clears it"),
+	}
+	flag.Var(&f.phones, "phone", "Typed phone number as type:number (mobile, work, home, other), repeatable")
+	flag.Var(&f.emails, "email", "Email as [type:]address, repeatable")
+	flag.Var(&f.addresses, "address", "Address as [type:]street, city, postal code, country, repeatable")
+	flag.Var(&f.tags, "tag", "Tag, repeatable; with list, shows the contacts carrying it")
+	return f
+}
+
+// apply copies every flag set on the command line onto contact. Repeatable
+// flags replace the existing list.
+func (f *contactFlags) apply(contact *domain.Contact) error {
+	set := make(map[string]bool)
+	flag.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+
+	if set["first"] {
+		contact.FirstName = *f.first
+	}
+	if set["last"] {
+		contact.LastName = *f.last
+	}
+	if set["first"] || set["last"] {
+		contact.Name = domain.JoinName(contact.FirstName, contact.LastName)
+	}
+	if set["org"] {
+		contact.Organization = *f.org
+	}
+	if set["title"] {
+		contact.JobTitle = *f.title
+	}
+	if set["birthday"] {
+		contact.Birthday = *f.birthday
+	}
+	if set["notes"] {
+		contact.Notes = *f.notes
+	}
+	if set["favorite"] {
+		contact.Favorite = *f.favorite
+	}
+
+	if len(f.phones) > 0 {
+		contact.Phones = nil
+		for _, value := range f.phones {
+			phoneType, number, ok := strings.Cut(value, ":")
+			if !ok {
+				return fmt.Errorf("invalid --phone '%s', expected type:number", value)
+			}
+			contact.Phones = append(contact.Phones, domain.Phone{Type: domain.PhoneType(phoneType), Number: number})
+		}
+	}
+
+	if len(f.emails) > 0 {
+		contact.Emails = nil
+		for _, value := range f.emails {
+			emailType, address := splitType(value)
+			contact.Emails = append(contact.Emails, domain.Email{Type: emailType, Address: address})
+		}
+	}
+
+	if len(f.addresses) > 0 {
+		contact.Addresses = nil
+		for _, value := range f.addresses {
+			addressType, rest := splitType(value)
+			parts := strings.Split(rest, ",")
+			if len(parts) > 4 {
+				return fmt.Errorf("invalid --address '%s', expected street, city, postal code, country", value)
+			}
+			parts = append(parts, make([]string, 4-len(parts))...)
+			contact.Addresses = append(contact.Addresses, domain.Address{
+				Type:       addressType,
+				Street:     parts[0],
+				City:       parts[1],
+				PostalCode: parts[2],
+				Country:    parts[3],
+			})
+		}
+	}
+
+	if len(f.tags) > 0 {
+		contact.Tags = append([]string(nil), f.tags...)
+	}
+
+	return nil
+}
+
+// changed reports whether any contact field flag was given.
+func (f *contactFlags) changed() bool {
+	changed := false
+	flag.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "first", "last", "org", "title", "birthday", "notes", "phone", "email", "address", "tag", "favorite":
+			changed = true
+		}
+	})
+	return changed
+}
+
+// listFlags holds the paging and sorting options of the list action.
+type listFlags struct {
+	limit  *int
+	offset *int
+	sort   *string
+	order  *string
+}
+
+func registerListFlags() *listFlags {
+	return &listFlags{
+		limit:  flag.Int("limit", 0, "Number of contacts to list, 0 lists them all"),
+		offset: flag.Int("offset", 0, "Number of contacts to skip"),
+		sort:   flag.String("sort", "name", "Sort contacts by name, phone, created or accessed"),
+		order:  flag.String("order", "asc", "Sort order: asc or desc"),
+	}
+}
+
+// query builds the list query. --name, --tel, --org, --tag and --group filter
+// the contacts.
+func (f *listFlags) query(name, tel, group string, fields *contactFlags) (service.ListQuery, error) {
+	query := service.ListQuery{
+		Sort:   service.SortField(*f.sort),
+		Limit:  *f.limit,
+		Offset: *f.offset,
+		Filter: service.ListFilter{
+			Name:         name,
+			Phone:        tel,
+			Organization: *fields.org,
+			Group:        group,
+		},
+	}
+
+	switch len(fields.tags) {
+	case 0:
+	case 1:
+		query.Filter.Tag = fields.tags[0]
+	default:
+		return query, fmt.Errorf("list accepts a single --tag")
+	}
+
+	switch *f.order {
+	case "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return query, fmt.Errorf("invalid --order '%s', expected asc or desc", *f.order)
+	}
+
+	return query, nil
+}
+
+// splitType splits an optional "type:" prefix from value. A prefix containing
+// spaces, commas or "@" is treated as part of the value.
+func splitType(value string) (string, string) {
+	prefix, rest, ok := strings.Cut(value, ":")
+	if !ok || strings.ContainsAny(prefix, " @,") {
+		return "", value
+	}
+	return prefix, rest
+}
+
+// transferFlags holds the flags of the import and export actions.
+type transferFlags struct {
+	input        *string
+	output       *string
+	format       *string
+	vcardVersion *string
+	delimiter    *string
+	mapping      *string
+	fields       *string
+	onConflict   *string
+	dryRun       *bool
+}
+
+func registerTransferFlags() *transferFlags {
+	return &transferFlags{
+		input:        flag.String("input", "", "File to import contacts from, - for stdin"),
+		output:       flag.String("output", "", "File to export contacts to (default: stdout)"),
+		format:       flag.String("format", "", "Import or export format: vcf or csv (default: from the file extension)"),
+		vcardVersion: flag.String("vcard-version", "3.0", "vCard version to export: 3.0 or 4.0"),
+		delimiter:    flag.String("delimiter", "", "CSV delimiter, e.g. ; or tab (default: detected on import, comma on export)"),
+		mapping:      flag.String("map", "", "CSV columns to import as column=field pairs, e.g. 'Full Name=name,3=phone'"),
+		fields:       flag.String("fields", "", "CSV fields to export, comma-separated (default: all)"),
+		onConflict:   flag.String("on-conflict", "skip", "What import does with contacts already stored: skip, overwrite, merge or rename"),
+		dryRun:       flag.Bool("dry-run", false, "Report what import would do without storing anything"),
+	}
+}
+
+// dedupeFlags holds the flags of the dedupe action.
+type dedupeFlags struct {
+	merge    *string
+	take     *string
+	minScore *float64
+}
+
+func registerDedupeFlags() *dedupeFlags {
+	return &dedupeFlags{
+		merge:    flag.String("merge", "", "ID of the contact to merge into --id with dedupe"),
+		take:     flag.String("take", "", "Fields to take from the merged contact, e.g. name,organization"),
+		minScore: flag.Float64("min-score", service.DefaultDuplicateScore, "Lowest score, from 0 to 1, of the duplicates dedupe lists"),
+	}
+}
+
+// webhookFlags holds the flags configuring the webhooks sent on changes.
+type webhookFlags struct {
+	urls       listFlag
+	secret     *string
+	deadLetter *string
+}
+
+func registerWebhookFlags() *webhookFlags {
+	f := &webhookFlags{
+		secret:     flag.String("webhook-secret", os.Getenv(webhookSecretEnv), "Secret signing the webhook payloads (default: $"+webhookSecretEnv+")"),
+		deadLetter: flag.String("webhook-dead-letter", "webhook-dead-letters.jsonl", "File the webhooks that could not be delivered are appended to"),
+	}
+	flag.Var(&f.urls, "webhook", "URL to POST contact changes to, repeatable")
+	return f
+}
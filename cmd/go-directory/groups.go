@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/LaulauChau/go-directory/internal/service"
+)
+
+func handleGroups(directory *service.Directory) int {
+	groups := directory.ListGroups()
+	if len(groups) == 0 {
+		fmt.Println("No groups found")
+		return exitOK
+	}
+
+	fmt.Printf("Found %d group(s):\n", len(groups))
+	for _, group := range groups {
+		fmt.Printf("  %s (%d member(s), id: %s)\n", group.Name, group.Members, group.ID)
+	}
+
+	return exitOK
+}
+
+func handleGroupCreate(directory *service.Directory, name string) int {
+	if name == "" {
+		fmt.Println("Error: --group is required for group-create action")
+		return exitUsage
+	}
+
+	group, err := directory.CreateGroup(name)
+	if err != nil {
+		fmt.Printf("Error creating group: %v\n", err)
+		return exitCode(err)
+	}
+
+	fmt.Printf("Group '%s' created successfully (id: %s)\n", group.Name, group.ID)
+	return exitOK
+}
+
+func handleGroupRename(directory *service.Directory, ref, name string) int {
+	if ref == "" || name == "" {
+		fmt.Println("Error: --group and --new-name are required for group-rename action")
+		return exitUsage
+	}
+
+	group, err := directory.FindGroup(ref)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitCode(err)
+	}
+	if err := directory.RenameGroup(group.ID, name); err != nil {
+		fmt.Printf("Error renaming group: %v\n", err)
+		return exitCode(err)
+	}
+
+	fmt.Printf("Group '%s' renamed to '%s'\n", group.Name, name)
+	return exitOK
+}
+
+func handleGroupDelete(directory *service.Directory, ref string) int {
+	if ref == "" {
+		fmt.Println("Error: --group is required for group-delete action")
+		return exitUsage
+	}
+
+	group, err := directory.FindGroup(ref)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitCode(err)
+	}
+	if err := directory.DeleteGroup(group.ID); err != nil {
+		fmt.Printf("Error deleting group: %v\n", err)
+		return exitCode(err)
+	}
+
+	fmt.Printf("Group '%s' deleted successfully\n", group.Name)
+	return exitOK
+}
+
+// handleGroupMembership adds the contact to the group for group-add, and
+// removes it for group-remove.
+func handleGroupMembership(directory *service.Directory, action, ref, id, name string) int {
+	if ref == "" || (id == "" && name == "") {
+		fmt.Printf("Error: --group, and --id or --name are required for %s action\n", action)
+		return exitUsage
+	}
+
+	group, err := directory.FindGroup(ref)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitCode(err)
+	}
+	contact, err := resolveContact(directory, id, name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitCode(err)
+	}
+
+	if action == "group-add" {
+		err = directory.AddToGroup(group.ID, contact.ID)
+	} else {
+		err = directory.RemoveFromGroup(group.ID, contact.ID)
+	}
+	if err != nil {
+		fmt.Printf("Error updating group: %v\n", err)
+		return exitCode(err)
+	}
+
+	if action == "group-add" {
+		fmt.Printf("Contact '%s' added to group '%s'\n", contact.Name, group.Name)
+	} else {
+		fmt.Printf("Contact '%s' removed from group '%s'\n", contact.Name, group.Name)
+	}
+
+	return exitOK
+}
+
+// groupNames maps the ID of every group to its name.
+func groupNames(directory *service.Directory) map[string]string {
+	names := make(map[string]string)
+	for _, group := range directory.ListGroups() {
+		names[group.ID] = group.Name
+	}
+	return names
+}
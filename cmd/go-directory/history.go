@@ -0,0 +1,54 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/service"
+)
+
+// handleHistory prints the changes recorded for the contact with the given
+// ID, or for every contact that had the given name.
+func handleHistory(directory *service.Directory, id, name string) int {
+	if id == "" && name == "" {
+		fmt.Println("Error: --id or --name is required for history action")
+		return exitUsage
+	}
+
+	entries, err := directory.History(cmp.Or(id, name))
+	if err != nil {
+		fmt.Printf("Error reading history: %v\n", err)
+		return exitCode(err)
+	}
+
+	fmt.Printf("%d change(s), oldest first:\n", len(entries))
+	fmt.Println("-------------------")
+	for _, entry := range entries {
+		fmt.Printf("%s  %s '%s' (id: %s) by %s\n", entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.Action, entry.Name(), entry.ContactID, cmp.Or(entry.Actor, "unknown"))
+		for _, change := range entry.Changes() {
+			switch entry.Action {
+			case domain.AuditCreate, domain.AuditRestore:
+				fmt.Printf("  %s: %s\n", change.Field, change.After)
+			case domain.AuditDelete, domain.AuditPurge:
+				fmt.Printf("  %s: %s\n", change.Field, change.Before)
+			default:
+				fmt.Printf("  %s: %s -> %s\n", change.Field, cmp.Or(change.Before, "(none)"), cmp.Or(change.After, "(none)"))
+			}
+		}
+	}
+
+	return exitOK
+}
+
+// defaultActor returns the name of the user running the command, recorded
+// in the audit log.
+func defaultActor() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	return cmp.Or(os.Getenv("USER"), os.Getenv("USERNAME"), "cli")
+}
@@ -1,183 +1,572 @@
 package main
 
 import (
+	"cmp"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/LaulauChau/go-directory/api"
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/phone"
 	"github.com/LaulauChau/go-directory/internal/service"
 	"github.com/LaulauChau/go-directory/internal/storage"
 )
 
 const defaultDataFile = "contacts.json"
 
+// Exit codes, so scripts can tell failures apart.
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2
+	exitNotFound   = 3
+	exitExists     = 4
+	exitValidation = 5
+	exitConflict   = 6
+)
+
 func main() {
+	os.Exit(run())
+}
+
+// run performs the action the flags ask for and returns the exit code, once
+// the lookups it made are saved and its webhooks delivered.
+func run() int {
 	var (
-		action  = flag.String("action", "", "Action to perform: add, delete, edit, search, list")
+		action  = flag.String("action", "", "Action to perform: add, delete, edit, search, list, favorites, groups, group-create, group-rename, group-delete, group-add, group-remove, import, export, dedupe, history, trash, restore, purge, empty-trash, snapshot")
+		id      = flag.String("id", "", "Contact ID")
 		name    = flag.String("name", "", "Contact name (firstname lastname)")
 		tel     = flag.String("tel", "", "Phone number")
+		group   = flag.String("group", "", "Group name or ID")
+		newName = flag.String("new-name", "", "New group name for group-rename")
 		file    = flag.String("file", defaultDataFile, "JSON file to store contacts")
+		store   = flag.String("store", "", "Contact store, e.g. sqlite:///path/contacts.db (overrides --file)")
 		webMode = flag.Bool("web", false, "Run as web server")
 		port    = flag.String("port", "8080", "Port for web server")
+		region  = flag.String("region", phone.DefaultRegion, "Region for phone numbers without a country code, e.g. US or FR")
+		actor   = flag.String("actor", "", "Who changes are recorded as made by in the audit log (default: the current user, or web)")
+		retain  = flag.Int("trash-days", 30, "Days deleted contacts stay in the trash before being deleted for good, 0 keeps them")
+		snap    = flag.String("snapshot", "", "Snapshot name")
+		fields  = registerContactFlags()
+		paging  = registerListFlags()
+		files   = registerTransferFlags()
+		dedupe  = registerDedupeFlags()
+		hooks   = registerWebhookFlags()
 	)
-	flag.Parse()
+	args := parseArgs()
 
 	if *webMode {
-		startWebServer(*file, *port)
-		return
+		startWebServer(*store, *file, *region, cmp.Or(*actor, "web"), *retain, hooks, *port)
+		return exitOK
 	}
 
 	if *action == "" {
 		fmt.Println("Error: --action flag is required")
 		printUsage()
-		os.Exit(1)
-	}
-
-	dataFile, err := filepath.Abs(*file)
-	if err != nil {
-		fmt.Printf("Error: invalid file path: %v\n", err)
-		os.Exit(1)
+		return exitUsage
 	}
 
-	store := storage.NewJSONStorage(dataFile)
-	directory, err := service.NewDirectory(store)
-	if err != nil {
-		fmt.Printf("Error initializing directory: %v\n", err)
-		os.Exit(1)
-	}
+	directory := openDirectory(*store, *file, *region, cmp.Or(*actor, defaultActor()), *retain)
+	purgeExpiredTrash(directory)
+	startWebhooks(directory, hooks)
+	defer stopWebhooks()
+	defer directory.FlushAccess()
 
 	switch *action {
 	case "add":
-		handleAdd(directory, *name, *tel)
+		return handleAdd(directory, *name, *tel, fields)
 	case "delete":
-		handleDelete(directory, *name)
+		return handleDelete(directory, *id, *name)
 	case "edit":
-		handleEdit(directory, *name, *tel)
+		return handleEdit(directory, *id, *name, *tel, fields)
 	case "search":
-		handleSearch(directory, *name)
+		return handleSearch(directory, *name)
 	case "list":
-		handleList(directory)
+		query, err := paging.query(*name, *tel, *group, fields)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return exitUsage
+		}
+		return handleList(directory, query)
+	case "favorites":
+		return handleFavorites(directory, *paging.limit)
+	case "groups":
+		return handleGroups(directory)
+	case "group-create":
+		return handleGroupCreate(directory, *group)
+	case "group-rename":
+		return handleGroupRename(directory, *group, *newName)
+	case "group-delete":
+		return handleGroupDelete(directory, *group)
+	case "group-add", "group-remove":
+		return handleGroupMembership(directory, *action, *group, *id, *name)
+	case "import":
+		return handleImport(directory, files)
+	case "export":
+		return handleExport(directory, files)
+	case "dedupe":
+		return handleDedupe(directory, *id, dedupe)
+	case "history":
+		return handleHistory(directory, *id, *name)
+	case "trash":
+		return handleTrash(directory)
+	case "restore":
+		return handleRestore(directory, *id, *name)
+	case "purge":
+		return handlePurge(directory, *id, *name)
+	case "empty-trash":
+		return handleEmptyTrash(directory)
+	case "snapshot":
+		return handleSnapshot(directory, args, *snap)
 	default:
 		fmt.Printf("Error: unknown action '%s'\n", *action)
 		printUsage()
-		os.Exit(1)
+		return exitUsage
 	}
 }
 
-func handleAdd(directory *service.Directory, name, phone string) {
-	if name == "" || phone == "" {
-		fmt.Println("Error: both --name and --tel are required for add action")
-		os.Exit(1)
+func handleAdd(directory *service.Directory, name, phone string, fields *contactFlags) int {
+	contact := domain.NewContact(name, "")
+	if err := fields.apply(&contact); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitUsage
+	}
+	if phone != "" {
+		contact.Phones = append([]domain.Phone{{Type: domain.PhoneMobile, Number: phone}}, contact.Phones...)
+	}
+
+	if contact.Name == "" || (len(contact.Phones) == 0 && len(contact.Emails) == 0) {
+		fmt.Println("Error: --name (or --first/--last) and a --tel, --phone or --email are required for add action")
+		return exitUsage
 	}
 
-	err := directory.AddContact(name, phone)
+	created, err := directory.CreateContact(contact)
 	if err != nil {
 		fmt.Printf("Error adding contact: %v\n", err)
-		os.Exit(1)
+		printPhoneHint(err)
+		return exitCode(err)
 	}
 
-	fmt.Printf("Contact '%s' added successfully\n", name)
+	fmt.Printf("Contact '%s' added successfully (id: %s)\n", created.Name, created.ID)
+	return exitOK
 }
 
-func handleDelete(directory *service.Directory, name string) {
-	if name == "" {
-		fmt.Println("Error: --name is required for delete action")
-		os.Exit(1)
+func handleDelete(directory *service.Directory, id, name string) int {
+	if id == "" && name == "" {
+		fmt.Println("Error: --id or --name is required for delete action")
+		return exitUsage
 	}
 
-	err := directory.DeleteContact(name)
+	if ids := strings.Split(id, ","); len(ids) > 1 {
+		if err := directory.DeleteContacts(ids...); err != nil {
+			fmt.Printf("Error deleting contacts: %v\n", err)
+			return exitCode(err)
+		}
+		fmt.Printf("%d contacts moved to the trash\n", len(ids))
+		return exitOK
+	}
+
+	contact, err := resolveContact(directory, id, name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitCode(err)
+	}
+	err = directory.DeleteContact(contact.ID)
 	if err != nil {
 		fmt.Printf("Error deleting contact: %v\n", err)
-		os.Exit(1)
+		return exitCode(err)
 	}
 
-	fmt.Printf("Contact '%s' deleted successfully\n", name)
+	fmt.Printf("Contact '%s' moved to the trash, restore it with --action restore --id %s\n", contact.Name, contact.ID)
+	return exitOK
 }
 
-func handleEdit(directory *service.Directory, name, phone string) {
-	if name == "" || phone == "" {
-		fmt.Println("Error: both --name and --tel are required for edit action")
-		os.Exit(1)
+func handleEdit(directory *service.Directory, id, name, phone string, fields *contactFlags) int {
+	if (id == "" && name == "") || (phone == "" && !fields.changed()) {
+		fmt.Println("Error: --id or --name, and --tel or another contact field are required for edit action")
+		return exitUsage
+	}
+
+	contact, err := resolveContact(directory, id, name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitCode(err)
+	}
+	if err := fields.apply(contact); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitUsage
+	}
+	if phone != "" {
+		contact.SetPrimaryPhone(phone)
 	}
 
-	err := directory.EditContact(name, phone)
+	err = directory.UpdateContact(*contact)
 	if err != nil {
 		fmt.Printf("Error editing contact: %v\n", err)
-		os.Exit(1)
+		printPhoneHint(err)
+		return exitCode(err)
 	}
 
-	fmt.Printf("Contact '%s' updated successfully\n", name)
+	fmt.Printf("Contact '%s' updated successfully\n", contact.Name)
+	return exitOK
 }
 
-func handleSearch(directory *service.Directory, name string) {
+func resolveContact(directory *service.Directory, id, name string) (*domain.Contact, error) {
+	if id != "" {
+		return directory.GetContact(id)
+	}
+	return directory.FindByName(name)
+}
+
+func handleSearch(directory *service.Directory, name string) int {
 	if name == "" {
 		fmt.Println("Error: --name is required for search action")
-		os.Exit(1)
+		return exitUsage
 	}
 
-	contact, err := directory.SearchContact(name)
+	matches, err := directory.Search(name)
 	if err != nil {
 		fmt.Printf("Error searching contact: %v\n", err)
-		os.Exit(1)
+		printQueryError(err)
+		return exitCode(err)
+	}
+	if len(matches) == 0 {
+		fmt.Printf("Error searching contact: no contact matches '%s'\n", name)
+		return exitNotFound
+	}
+
+	fmt.Printf("Found %d contact(s), best match first:\n", len(matches))
+	groups := groupNames(directory)
+	fmt.Println("-------------------")
+	for _, contact := range matches {
+		printContact(contact, groups)
+		fmt.Println("-------------------")
 	}
 
-	fmt.Printf("Found contact:\nName: %s\nPhone: %s\n", contact.Name, contact.Phone)
+	return exitOK
 }
 
-func handleList(directory *service.Directory) {
-	contacts := directory.ListContacts()
-	if len(contacts) == 0 {
+func handleList(directory *service.Directory, query service.ListQuery) int {
+	page, err := directory.QueryContacts(query)
+	if err != nil {
+		fmt.Printf("Error listing contacts: %v\n", err)
+		return exitCode(err)
+	}
+
+	if page.Total == 0 {
 		fmt.Println("No contacts found")
-		return
+		return exitOK
+	}
+	if len(page.Contacts) == 0 {
+		fmt.Printf("No contacts past offset %d, found %d contact(s)\n", page.Offset, page.Total)
+		return exitOK
 	}
 
-	fmt.Printf("Found %d contact(s):\n", len(contacts))
+	if len(page.Contacts) == page.Total {
+		fmt.Printf("Found %d contact(s):\n", page.Total)
+	} else {
+		fmt.Printf("Showing %d-%d of %d contact(s):\n", page.Offset+1, page.Offset+len(page.Contacts), page.Total)
+	}
+	groups := groupNames(directory)
 	fmt.Println("-------------------")
-	for _, contact := range contacts {
-		fmt.Printf("Name: %s\nPhone: %s\n-------------------\n", contact.Name, contact.Phone)
+	for _, contact := range page.Contacts {
+		printContact(contact, groups)
+		fmt.Println("-------------------")
 	}
+
+	if page.NextCursor != "" {
+		fmt.Printf("More contacts follow, use --offset %d to see them\n", page.Offset+len(page.Contacts))
+	}
+
+	return exitOK
 }
 
-func startWebServer(file, port string) {
-	dataFile, err := filepath.Abs(file)
+// defaultRecentLimit is the number of recent contacts the favorites action
+// shows without --limit.
+const defaultRecentLimit = 10
+
+func handleFavorites(directory *service.Directory, limit int) int {
+	if limit <= 0 {
+		limit = defaultRecentLimit
+	}
+	favorites := directory.Favorites()
+	recent := directory.RecentContacts(limit)
+
+	fmt.Println("Favorites:")
+	if len(favorites) == 0 {
+		fmt.Println("  None, mark one with --action edit --favorite")
+	}
+	for _, contact := range favorites {
+		fmt.Printf("  %s\n", shortcutLine(contact))
+	}
+
+	fmt.Println("Recent:")
+	if len(recent) == 0 {
+		fmt.Println("  None, contacts found by search show here")
+	}
+	for _, contact := range recent {
+		fmt.Printf("  %s (%s)\n", shortcutLine(contact), contact.LastAccessedAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	return exitOK
+}
+
+// shortcutLine sums contact up with the way to reach it.
+func shortcutLine(contact domain.Contact) string {
+	reach := contact.PrimaryEmail()
+	if len(contact.Phones) > 0 {
+		reach = contact.Phones[0].String()
+	}
+	if reach == "" {
+		return contact.Name
+	}
+	return contact.Name + ": " + reach
+}
+
+// printContact prints every field of contact, naming its groups from groups,
+// a map of group IDs to names.
+func printContact(contact domain.Contact, groups map[string]string) {
+	fmt.Printf("ID: %s\nName: %s\n", contact.ID, contact.Name)
+	if contact.Organization != "" {
+		fmt.Printf("Organization: %s\n", contact.Organization)
+	}
+	if contact.JobTitle != "" {
+		fmt.Printf("Job title: %s\n", contact.JobTitle)
+	}
+	for _, phone := range contact.Phones {
+		fmt.Printf("Phone (%s): %s\n", phone.Type, phone)
+	}
+	for _, email := range contact.Emails {
+		fmt.Printf("Email%s: %s\n", typeSuffix(email.Type), email.Address)
+	}
+	for _, address := range contact.Addresses {
+		fmt.Printf("Address%s: %s\n", typeSuffix(address.Type), formatAddress(address))
+	}
+	if contact.Birthday != "" {
+		fmt.Printf("Birthday: %s\n", contact.Birthday)
+	}
+	if contact.Notes != "" {
+		fmt.Printf("Notes: %s\n", contact.Notes)
+	}
+	if len(contact.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(contact.Tags, ", "))
+	}
+	if len(contact.Groups) > 0 {
+		names := make([]string, 0, len(contact.Groups))
+		for _, id := range contact.Groups {
+			names = append(names, cmp.Or(groups[id], id))
+		}
+		fmt.Printf("Groups: %s\n", strings.Join(names, ", "))
+	}
+	if contact.Favorite {
+		fmt.Println("Favorite: yes")
+	}
+	if !contact.CreatedAt.IsZero() {
+		fmt.Printf("Created: %s\n", contact.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	if !contact.LastAccessedAt.IsZero() {
+		fmt.Printf("Last looked up: %s\n", contact.LastAccessedAt.Local().Format("2006-01-02 15:04"))
+	}
+	if !contact.DeletedAt.IsZero() {
+		fmt.Printf("Deleted: %s\n", contact.DeletedAt.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+func typeSuffix(kind string) string {
+	if kind == "" {
+		return ""
+	}
+	return " (" + kind + ")"
+}
+
+func formatAddress(address domain.Address) string {
+	var parts []string
+	for _, part := range []string{address.Street, address.PostalCode + " " + address.City, address.Region, address.Country} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, service.ErrAlreadyExists):
+		return exitExists
+	case errors.Is(err, service.ErrValidation):
+		return exitValidation
+	case errors.Is(err, service.ErrConflict):
+		return exitConflict
+	default:
+		return exitError
+	}
+}
+
+// printPhoneHint explains how national numbers are read when a phone was
+// rejected for its length, which usually means the wrong region was assumed.
+func printPhoneHint(err error) {
+	if errors.Is(err, phone.ErrTooShort) || errors.Is(err, phone.ErrTooLong) {
+		fmt.Println("Numbers without a leading +<country code> are read in the --region region, e.g. --region FR for 06 12 34 56 78")
+	}
+}
+
+// printQueryError points at the part of a malformed search query that was
+// rejected.
+func printQueryError(err error) {
+	var queryErr *service.QueryError
+	if errors.As(err, &queryErr) {
+		fmt.Printf("  %s\n  %s^\n", queryErr.Query, strings.Repeat(" ", queryErr.Pos))
+	}
+}
+
+// openDirectory opens the store the flags point at, and exits when it
+// cannot: nothing was changed or sent to webhooks yet.
+func openDirectory(location, file, region, actor string, trashDays int) *service.Directory {
+	if location == "" {
+		dataFile, err := filepath.Abs(file)
+		if err != nil {
+			fmt.Printf("Error: invalid file path: %v\n", err)
+			os.Exit(exitError)
+		}
+		location = "json://" + dataFile
+	}
+
+	store, err := storage.Open(location)
 	if err != nil {
-		fmt.Printf("Error: invalid file path: %v\n", err)
-		os.Exit(1)
+		fmt.Printf("Error opening store: %v\n", err)
+		os.Exit(exitError)
 	}
 
-	store := storage.NewJSONStorage(dataFile)
 	directory, err := service.NewDirectory(store)
 	if err != nil {
 		fmt.Printf("Error initializing directory: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
+	if err := directory.SetDefaultRegion(region); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	directory.SetActor(actor)
+	if err := directory.SetTrashRetention(time.Duration(trashDays) * 24 * time.Hour); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	return directory
+}
+
+func startWebServer(location, file, region, actor string, trashDays int, hooks *webhookFlags, port string) {
+	directory := openDirectory(location, file, region, actor, trashDays)
+	startWebhooks(directory, hooks)
+	go func() {
+		purgeExpiredTrash(directory)
+		for range time.Tick(trashPurgeInterval) {
+			purgeExpiredTrash(directory)
+		}
+	}()
+
 	server := api.NewServer(directory, port)
 	server.StartWithGracefulShutdown()
+	directory.FlushAccess()
+	stopWebhooks()
 }
 
 func printUsage() {
 	fmt.Println("\nUsage:")
-	fmt.Println("  go run ./cmd/go-directory/main.go --action <action> [options]")
-	fmt.Println("  go run ./cmd/go-directory/main.go --web [--port <port>]")
+	fmt.Println("  go run ./cmd/go-directory --action <action> [options]")
+	fmt.Println("  go run ./cmd/go-directory --action snapshot create|list|diff|restore [name] [options]")
+	fmt.Println("  go run ./cmd/go-directory --web [--port <port>]")
 	fmt.Println("\nActions:")
-	fmt.Println("  add     Add a new contact (requires --name and --tel)")
-	fmt.Println("  delete  Delete a contact (requires --name)")
-	fmt.Println("  edit    Edit a contact's phone number (requires --name and --tel)")
-	fmt.Println("  search  Search for a contact (requires --name)")
-	fmt.Println("  list    List all contacts")
+	fmt.Println("  add     Add a new contact (requires --name and --tel, --phone or --email)")
+	fmt.Println("  delete  Move a contact to the trash (requires --id or --name), or several with comma-separated ids, all or none")
+	fmt.Println("  edit    Edit a contact (requires --id or --name, and the fields to change)")
+	fmt.Println("  search  Search contacts, e.g. --name 'org:acme phone:+33* -city:paris' (requires --name)")
+	fmt.Println("  list    List contacts (--name, --tel, --org, --tag and --group filter them)")
+	fmt.Println("  favorites  List favorites, then the contacts looked up most recently (--limit of them, default 10)")
+	fmt.Println("  groups        List groups with their member counts")
+	fmt.Println("  group-create  Create a group (requires --group)")
+	fmt.Println("  group-rename  Rename a group (requires --group and --new-name)")
+	fmt.Println("  group-delete  Delete a group, keeping its contacts (requires --group)")
+	fmt.Println("  group-add     Add a contact to a group (requires --group, and --id or --name)")
+	fmt.Println("  group-remove  Remove a contact from a group (requires --group, and --id or --name)")
+	fmt.Println("  import  Import contacts from a vCard or CSV file (requires --input, --dry-run only reports)")
+	fmt.Println("  export  Export every contact as vCards or CSV (--format vcf or csv, to --output or stdout)")
+	fmt.Println("  dedupe  List likely duplicates, or merge the --merge contact into the --id one")
+	fmt.Println("  history  Show who changed a contact, when and how, deleted contacts included (requires --id or --name)")
+	fmt.Println("  trash        List the deleted contacts")
+	fmt.Println("  restore      Restore a deleted contact (requires --id or --name)")
+	fmt.Println("  purge        Delete a contact from the trash for good (requires --id or --name)")
+	fmt.Println("  empty-trash  Delete every contact in the trash for good")
+	fmt.Println("  snapshot create [name]  Save a copy of every contact and group (default name: the current time)")
+	fmt.Println("  snapshot list           List the snapshots, newest first")
+	fmt.Println("  snapshot diff <name>    Show the contacts added, removed and changed since a snapshot")
+	fmt.Println("  snapshot restore <name> Put every contact and group back as in a snapshot, saving the current ones first")
 	fmt.Println("\nOptions:")
+	fmt.Println("  --id      Contact ID, needed when several contacts share a name")
 	fmt.Println("  --name    Contact name (firstname lastname)")
-	fmt.Println("  --tel     Phone number")
+	fmt.Println("  --tel     Primary phone number")
+	fmt.Println("  --phone   Typed phone number as type:number, repeatable (mobile, work, home, other)")
+	fmt.Println("  --email   Email as [type:]address, repeatable")
+	fmt.Println("  --address Address as [type:]street, city, postal code, country, repeatable")
+	fmt.Println("  --first, --last, --org, --title, --birthday (YYYY-MM-DD), --notes")
+	fmt.Println("  --favorite  Mark a contact as a favorite with add and edit, --favorite=false clears it")
+	fmt.Println("  --tag     Tag, repeatable; replaces the contact's tags with add and edit")
+	fmt.Println("  --group   Group name or ID")
+	fmt.Println("  --new-name  New group name for group-rename")
+	fmt.Println("  --snapshot  Snapshot name, instead of the argument of snapshot: letters, digits, '.', '-' and '_'")
+	fmt.Println("  --limit, --offset  Page through the list action")
+	fmt.Println("  --sort    Sort the list by name, phone, created or accessed (default: name)")
+	fmt.Println("  --order   Sort order: asc or desc (default: asc)")
+	fmt.Println("  --input   File to import, - reads stdin")
+	fmt.Println("  --output  File to export to (default: stdout)")
+	fmt.Println("  --format  Import or export format: vcf or csv (default: from the file extension)")
+	fmt.Println("  --on-conflict  What import does with contacts already stored: skip, overwrite, merge or rename (default: skip)")
+	fmt.Println("  --dry-run  Report what import would create, update, skip or reject, storing nothing")
+	fmt.Println("  --vcard-version  vCard version to export: 3.0 or 4.0 (default: 3.0)")
+	fmt.Println("  --delimiter  CSV delimiter, e.g. ';' or tab (default: detected on import, comma on export)")
+	fmt.Println("  --map     CSV columns to import, by header or number, e.g. 'Full Name=name,3=phone'")
+	fmt.Println("  --fields  CSV fields to export, e.g. name,phone,email (default: all)")
+	fmt.Println("  --merge   ID of the contact dedupe merges into --id, then deletes")
+	fmt.Println("  --take    Fields dedupe takes from the --merge contact: name, organization, jobTitle, birthday")
+	fmt.Println("  --min-score  Lowest score, from 0 to 1, of the duplicates dedupe lists (default: 0.6)")
+	fmt.Println("  --region  Region for phone numbers without a country code (default: " + phone.DefaultRegion + ")")
+	fmt.Println("  --actor   Who changes are recorded as made by in the audit log (default: the current user, web for --web)")
+	fmt.Println("  --trash-days  Days deleted contacts stay in the trash, 0 keeps them until purged (default: 30)")
+	fmt.Println("  --webhook  URL to POST every contact change to, repeatable")
+	fmt.Println("  --webhook-secret  Secret signing the webhook payloads (default: $" + webhookSecretEnv + ")")
+	fmt.Println("  --webhook-dead-letter  File the undelivered webhooks are appended to (default: webhook-dead-letters.jsonl)")
 	fmt.Println("  --file    JSON file to store contacts (default: contacts.json)")
+	fmt.Println("  --store   Contact store URL: json://<path> or sqlite://<path> (overrides --file)")
 	fmt.Println("  --web     Run as web server")
 	fmt.Println("  --port    Port for web server (default: 8080)")
 	fmt.Println("\nExamples:")
-	fmt.Println("  go run ./cmd/go-directory/main.go --action add --name \"Charlie Brown\" --tel \"0000000000\"")
-	fmt.Println("  go run ./cmd/go-directory/main.go --action search --name \"Alice\"")
-	fmt.Println("  go run ./cmd/go-directory/main.go --action list")
-	fmt.Println("  go run ./cmd/go-directory/main.go --web")
-	fmt.Println("  go run ./cmd/go-directory/main.go --web --port 3000")
+	fmt.Println("  go run ./cmd/go-directory --action add --name \"Charlie Brown\" --tel \"0000000000\"")
+	fmt.Println("  go run ./cmd/go-directory --action search --name \"Alice\"")
+	fmt.Println("  go run ./cmd/go-directory --action list")
+	fmt.Println("  go run ./cmd/go-directory --action list --sort created --order desc --limit 10")
+	fmt.Println("  go run ./cmd/go-directory --action delete --id <id>,<id>,<id>")
+	fmt.Println("  go run ./cmd/go-directory --action edit --name \"Alice\" --favorite")
+	fmt.Println("  go run ./cmd/go-directory --action favorites")
+	fmt.Println("  go run ./cmd/go-directory --action group-add --group Family --name \"Alice\"")
+	fmt.Println("  go run ./cmd/go-directory --action list --group Family --tag vip")
+	fmt.Println("  go run ./cmd/go-directory --action import --input phone.vcf")
+	fmt.Println("  go run ./cmd/go-directory --action export --format vcf --vcard-version 4.0 --output contacts.vcf")
+	fmt.Println("  go run ./cmd/go-directory --action import --input people.csv --map 'Nom=name,Portable=phone' --dry-run")
+	fmt.Println("  go run ./cmd/go-directory --action import --input contacts.csv --on-conflict overwrite")
+	fmt.Println("  go run ./cmd/go-directory --action dedupe")
+	fmt.Println("  go run ./cmd/go-directory --action dedupe --id <keep-id> --merge <other-id> --take organization")
+	fmt.Println("  go run ./cmd/go-directory --action history --name \"Alice\"")
+	fmt.Println("  go run ./cmd/go-directory --action restore --name \"Alice\"")
+	fmt.Println("  go run ./cmd/go-directory --action snapshot create before-import")
+	fmt.Println("  go run ./cmd/go-directory --action snapshot diff before-import")
+	fmt.Println("  go run ./cmd/go-directory --web")
+	fmt.Println("  go run ./cmd/go-directory --web --port 3000")
 }
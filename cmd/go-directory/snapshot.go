@@ -0,0 +1,128 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+
+	"github.com/LaulauChau/go-directory/internal/service"
+)
+
+// snapshotCommands are the commands of the snapshot action.
+const snapshotCommands = "create, list, diff or restore"
+
+// handleSnapshot runs the snapshot command args start with, on the snapshot
+// args name next, or name otherwise.
+func handleSnapshot(directory *service.Directory, args []string, name string) int {
+	if len(args) == 0 {
+		fmt.Printf("Error: snapshot action requires a command: %s\n", snapshotCommands)
+		return exitUsage
+	}
+	if len(args) > 2 {
+		fmt.Printf("Error: snapshot %s takes at most a snapshot name, got %s\n", args[0], strings.Join(args[1:], " "))
+		return exitUsage
+	}
+	if len(args) == 2 {
+		name = args[1]
+	}
+
+	switch args[0] {
+	case "create":
+		return handleSnapshotCreate(directory, name)
+	case "list":
+		return handleSnapshotList(directory)
+	case "diff":
+		return handleSnapshotDiff(directory, name)
+	case "restore":
+		return handleSnapshotRestore(directory, name)
+	default:
+		fmt.Printf("Error: unknown snapshot command '%s', expected %s\n", args[0], snapshotCommands)
+		return exitUsage
+	}
+}
+
+func handleSnapshotCreate(directory *service.Directory, name string) int {
+	snapshot, err := directory.CreateSnapshot(name)
+	if err != nil {
+		fmt.Printf("Error creating snapshot: %v\n", err)
+		return exitCode(err)
+	}
+
+	fmt.Printf("Snapshot '%s' created with %d contact(s)\n", snapshot.Name, len(snapshot.Contacts))
+	return exitOK
+}
+
+func handleSnapshotList(directory *service.Directory) int {
+	snapshots, err := directory.Snapshots()
+	if err != nil {
+		fmt.Printf("Error listing snapshots: %v\n", err)
+		return exitCode(err)
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots found")
+		return exitOK
+	}
+
+	fmt.Printf("Found %d snapshot(s), newest first:\n", len(snapshots))
+	for _, snapshot := range snapshots {
+		fmt.Printf("  %s  %s (%d contact(s), %d in the trash) by %s\n", snapshot.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			snapshot.Name, len(snapshot.Live()), len(snapshot.Contacts)-len(snapshot.Live()), cmp.Or(snapshot.Actor, "unknown"))
+	}
+
+	return exitOK
+}
+
+// handleSnapshotDiff prints the contacts added, removed and changed since
+// the snapshot was taken.
+func handleSnapshotDiff(directory *service.Directory, name string) int {
+	if name == "" {
+		fmt.Println("Error: a snapshot name is required for snapshot diff, e.g. --action snapshot diff before-import")
+		return exitUsage
+	}
+
+	diff, err := directory.DiffSnapshot(name)
+	if err != nil {
+		fmt.Printf("Error comparing snapshot: %v\n", err)
+		return exitCode(err)
+	}
+
+	taken := diff.CreatedAt.Local().Format("2006-01-02 15:04:05")
+	if diff.Empty() {
+		fmt.Printf("No changes since snapshot '%s' (%s)\n", diff.Name, taken)
+		return exitOK
+	}
+
+	fmt.Printf("Changes since snapshot '%s' (%s): %d added, %d removed, %d changed\n",
+		diff.Name, taken, len(diff.Added), len(diff.Removed), len(diff.Changed))
+	for _, contact := range diff.Added {
+		fmt.Printf("+ %s (id: %s)\n", contact.Name, contact.ID)
+	}
+	for _, contact := range diff.Removed {
+		fmt.Printf("- %s (id: %s)\n", contact.Name, contact.ID)
+	}
+	for _, change := range diff.Changed {
+		fmt.Printf("~ %s (id: %s)\n", change.After.Name, change.After.ID)
+		for _, field := range change.Changes {
+			fmt.Printf("  %s: %s -> %s\n", field.Field, cmp.Or(field.Before, "(none)"), cmp.Or(field.After, "(none)"))
+		}
+	}
+
+	return exitOK
+}
+
+func handleSnapshotRestore(directory *service.Directory, name string) int {
+	if name == "" {
+		fmt.Println("Error: a snapshot name is required for snapshot restore, e.g. --action snapshot restore before-import")
+		return exitUsage
+	}
+
+	backup, err := directory.RestoreSnapshot(name)
+	if err != nil {
+		fmt.Printf("Error restoring snapshot: %v\n", err)
+		return exitCode(err)
+	}
+
+	fmt.Printf("Snapshot '%s' restored successfully\n", name)
+	fmt.Printf("The contacts as they were before are kept in snapshot '%s'\n", backup.Name)
+	return exitOK
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/LaulauChau/go-directory/internal/csvfile"
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/service"
+	"github.com/LaulauChau/go-directory/internal/vcard"
+)
+
+// Formats of the import and export actions.
+const (
+	formatVCard = "vcf"
+	formatCSV   = "csv"
+)
+
+// transferFormat returns format, or the format the extension of path stands
+// for when format is empty.
+func transferFormat(format, path string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".vcf", ".vcard":
+			return formatVCard, nil
+		case ".csv", ".tsv", ".txt":
+			return formatCSV, nil
+		}
+		return "", fmt.Errorf("cannot tell the format of '%s', use --format vcf or csv", path)
+	}
+	if format != formatVCard && format != formatCSV {
+		return "", fmt.Errorf("unknown format '%s', expected vcf or csv", format)
+	}
+	return format, nil
+}
+
+// csvOptions reads the CSV flags.
+func (f *transferFlags) csvOptions() (csvfile.Options, error) {
+	var (
+		opts csvfile.Options
+		err  error
+	)
+	if opts.Comma, err = csvfile.ParseDelimiter(*f.delimiter); err != nil {
+		return opts, err
+	}
+	if opts.Mapping, err = csvfile.ParseMapping(*f.mapping); err != nil {
+		return opts, err
+	}
+	if opts.Fields, err = csvfile.ParseFields(*f.fields); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// importFile is a decoded import file.
+type importFile struct {
+	contacts []domain.Contact
+	// origins tells where each contact comes from, e.g. "line 3".
+	origins []string
+	// unreadable lists the rows that could not be read, with their origin.
+	unreadable []string
+	// about tells how the file was read.
+	about string
+}
+
+func decodeImport(r io.Reader, format string, opts csvfile.Options) (importFile, error) {
+	var file importFile
+	if format == formatVCard {
+		contacts, err := vcard.Decode(r)
+		if err != nil {
+			return file, err
+		}
+		file.contacts = contacts
+		for i := range contacts {
+			file.origins = append(file.origins, fmt.Sprintf("card %d", i+1))
+		}
+		file.about = "vCard"
+		return file, nil
+	}
+
+	decoded, err := csvfile.Decode(r, opts)
+	if err != nil {
+		return file, err
+	}
+	for _, row := range decoded.Rows {
+		if row.Err != nil {
+			file.unreadable = append(file.unreadable, fmt.Sprintf("line %d: %v", row.Line, row.Err))
+			continue
+		}
+		file.contacts = append(file.contacts, row.Contact)
+		file.origins = append(file.origins, fmt.Sprintf("line %d", row.Line))
+	}
+
+	header := "no header"
+	if decoded.Header != nil {
+		header = "a header"
+	}
+	file.about = fmt.Sprintf("CSV, %s delimited, %s, %s", delimiterName(decoded.Comma), decoded.Encoding, header)
+	return file, nil
+}
+
+func delimiterName(comma rune) string {
+	if comma == '\t' {
+		return "tab"
+	}
+	return strconv.QuoteRune(comma)
+}
+
+func handleImport(directory *service.Directory, f *transferFlags) int {
+	input := *f.input
+	if input == "" {
+		fmt.Println("Error: --input is required for import action, - reads stdin")
+		return exitUsage
+	}
+	format, err := transferFormat(*f.format, input)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitUsage
+	}
+	opts, err := f.csvOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitUsage
+	}
+
+	r := io.Reader(os.Stdin)
+	if input != "-" {
+		in, err := os.Open(input)
+		if err != nil {
+			fmt.Printf("Error importing contacts: %v\n", err)
+			return exitError
+		}
+		defer in.Close()
+		r = in
+	}
+
+	file, err := decodeImport(r, format, opts)
+	if err != nil {
+		fmt.Printf("Error importing contacts: %v\n", err)
+		return exitValidation
+	}
+
+	result, err := directory.ImportContacts(file.contacts, service.ImportOptions{
+		Strategy: service.ConflictStrategy(*f.onConflict),
+		DryRun:   *f.dryRun,
+	})
+	if err != nil {
+		fmt.Printf("Error importing contacts: %v\n", err)
+		return exitCode(err)
+	}
+	printImportResult(file, result)
+
+	switch {
+	case len(file.unreadable) > 0:
+		return exitValidation
+	case len(result.Failed) > 0:
+		return exitCode(result.Failed[0].Err)
+	default:
+		return exitOK
+	}
+}
+
+func printImportResult(file importFile, result service.ImportResult) {
+	rows := len(file.contacts) + len(file.unreadable)
+	rejected := len(result.Failed) + len(file.unreadable)
+	if result.DryRun {
+		fmt.Printf("Dry run, nothing was stored: would create %d, update %d, skip %d and reject %d of %d row(s) (%s, %s on conflict)\n",
+			len(result.Created), len(result.Updated), len(result.Skipped), rejected, rows, file.about, result.Strategy)
+	} else {
+		fmt.Printf("Created %d, updated %d, skipped %d and rejected %d of %d row(s) (%s, %s on conflict)\n",
+			len(result.Created), len(result.Updated), len(result.Skipped), rejected, rows, file.about, result.Strategy)
+	}
+
+	if rejected > 0 {
+		fmt.Println("Rejected:")
+		for _, message := range file.unreadable {
+			fmt.Printf("  %s\n", message)
+		}
+		for _, failure := range result.Failed {
+			fmt.Printf("  %s (%s): %v\n", file.origins[failure.Index], failure.Name, failure.Err)
+		}
+	}
+	if len(result.Skipped) > 0 {
+		fmt.Println("Skipped:")
+		for _, skipped := range result.Skipped {
+			fmt.Printf("  %s (%s): %v\n", file.origins[skipped.Index], skipped.Name, skipped.Err)
+		}
+	}
+}
+
+func handleExport(directory *service.Directory, f *transferFlags) int {
+	output := *f.output
+	format, err := transferFormat(*f.format, output)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitUsage
+	}
+	vcardVersion, err := vcard.ParseVersion(*f.vcardVersion)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitUsage
+	}
+	opts, err := f.csvOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitUsage
+	}
+
+	contacts := directory.ListContacts()
+	encode := func(w io.Writer) error {
+		if format == formatCSV {
+			return csvfile.Encode(w, contacts, opts)
+		}
+		return vcard.Encode(w, contacts, vcardVersion)
+	}
+
+	if output == "" || output == "-" {
+		if err := encode(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting contacts: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	out, err := os.Create(output)
+	if err == nil {
+		err = encode(out)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error exporting contacts: %v\n", err)
+		return exitError
+	}
+	fmt.Printf("Exported %d contact(s) to %s as %s\n", len(contacts), output, format)
+	return exitOK
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/service"
+)
+
+// trashPurgeInterval is how often the web server purges the contacts kept in
+// the trash past the retention period.
+const trashPurgeInterval = time.Hour
+
+func handleTrash(directory *service.Directory) int {
+	trash := directory.Trash()
+	if len(trash) == 0 {
+		fmt.Println("The trash is empty")
+		return exitOK
+	}
+
+	fmt.Printf("%d contact(s) in the trash, most recently deleted first:\n", len(trash))
+	if retention := directory.TrashRetention(); retention > 0 {
+		fmt.Printf("Contacts are deleted for good %d day(s) after being moved to the trash\n", int(retention.Hours()/24))
+	}
+	groups := groupNames(directory)
+	fmt.Println("-------------------")
+	for _, contact := range trash {
+		printContact(contact, groups)
+		fmt.Println("-------------------")
+	}
+
+	return exitOK
+}
+
+func handleRestore(directory *service.Directory, id, name string) int {
+	if id == "" && name == "" {
+		fmt.Println("Error: --id or --name is required for restore action")
+		return exitUsage
+	}
+
+	id, err := trashedID(directory, id, name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitCode(err)
+	}
+
+	restored, err := directory.RestoreContact(id)
+	if err != nil {
+		fmt.Printf("Error restoring contact: %v\n", err)
+		return exitCode(err)
+	}
+
+	fmt.Printf("Contact '%s' restored successfully (id: %s)\n", restored.Name, restored.ID)
+	return exitOK
+}
+
+func handlePurge(directory *service.Directory, id, name string) int {
+	if id == "" && name == "" {
+		fmt.Println("Error: --id or --name is required for purge action")
+		return exitUsage
+	}
+
+	id, err := trashedID(directory, id, name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitCode(err)
+	}
+	if err := directory.PurgeContact(id); err != nil {
+		fmt.Printf("Error purging contact: %v\n", err)
+		return exitCode(err)
+	}
+
+	fmt.Printf("Contact %s deleted for good\n", id)
+	return exitOK
+}
+
+func handleEmptyTrash(directory *service.Directory) int {
+	purged, err := directory.EmptyTrash()
+	if err != nil {
+		fmt.Printf("Error emptying the trash: %v\n", err)
+		return exitCode(err)
+	}
+
+	fmt.Printf("%d contact(s) deleted for good\n", purged)
+	return exitOK
+}
+
+// trashedID returns id, or the ID of the deleted contact named name.
+func trashedID(directory *service.Directory, id, name string) (string, error) {
+	if id != "" {
+		return id, nil
+	}
+
+	contact, err := directory.FindInTrash(name)
+	if err != nil {
+		return "", err
+	}
+	return contact.ID, nil
+}
+
+// purgeExpiredTrash deletes for good the contacts kept in the trash past the
+// retention period. A failure only delays that, so it is reported and the
+// command goes on.
+func purgeExpiredTrash(directory *service.Directory) {
+	if _, err := directory.PurgeExpired(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to purge expired contacts from the trash: %v\n", err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/service"
+	"github.com/LaulauChau/go-directory/internal/webhook"
+)
+
+// webhookSecretEnv names the environment variable holding the webhook
+// secret, which keeps it out of the process list.
+const webhookSecretEnv = "GO_DIRECTORY_WEBHOOK_SECRET"
+
+// webhookDrainTimeout bounds how long the CLI waits for the webhooks of its
+// changes to be delivered before exiting.
+const webhookDrainTimeout = 30 * time.Second
+
+// stopWebhooks waits for the webhooks still queued to be delivered. The CLI
+// calls it before exiting once changes are stored.
+var stopWebhooks = func() {}
+
+// startWebhooks sends every change made to the directory to the --webhook
+// URLs.
+func startWebhooks(directory *service.Directory, f *webhookFlags) {
+	if len(f.urls) == 0 {
+		return
+	}
+
+	dispatcher := webhook.New(f.urls, webhook.Options{
+		Secret:     *f.secret,
+		DeadLetter: *f.deadLetter,
+	})
+	unsubscribe := directory.Subscribe(dispatcher.Handle)
+
+	stopWebhooks = func() {
+		unsubscribe()
+		ctx, cancel := context.WithTimeout(context.Background(), webhookDrainTimeout)
+		defer cancel()
+		if err := dispatcher.Close(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: webhooks not delivered in time were written to %s\n", *f.deadLetter)
+		}
+	}
+}
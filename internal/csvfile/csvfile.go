@@ -0,0 +1,180 @@
+// Package csvfile reads and writes contacts as CSV, the format spreadsheets
+// exchange. Columns map to contact fields by name, so files exported from
+// other address books can be read with their own headers.
+package csvfile
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Contact fields a column can hold. A contact has at most one phone and
+// email of each type, and one address, in a CSV file.
+const (
+	FieldID           = "id"
+	FieldName         = "name"
+	FieldFirstName    = "firstName"
+	FieldLastName     = "lastName"
+	FieldOrganization = "organization"
+	FieldJobTitle     = "jobTitle"
+	FieldPhone        = "phone"
+	FieldPhoneWork    = "phoneWork"
+	FieldPhoneHome    = "phoneHome"
+	FieldPhoneOther   = "phoneOther"
+	FieldEmail        = "email"
+	FieldEmailWork    = "emailWork"
+	FieldEmailHome    = "emailHome"
+	FieldStreet       = "street"
+	FieldCity         = "city"
+	FieldRegion       = "region"
+	FieldPostalCode   = "postalCode"
+	FieldCountry      = "country"
+	FieldBirthday     = "birthday"
+	FieldNotes        = "notes"
+	FieldTags         = "tags"
+	FieldFavorite     = "favorite"
+)
+
+// Fields lists every field in the order Encode writes them by default.
+var Fields = []string{
+	FieldID, FieldName, FieldFirstName, FieldLastName, FieldOrganization, FieldJobTitle,
+	FieldPhone, FieldPhoneWork, FieldPhoneHome, FieldPhoneOther,
+	FieldEmail, FieldEmailWork, FieldEmailHome,
+	FieldStreet, FieldCity, FieldRegion, FieldPostalCode, FieldCountry,
+	FieldBirthday, FieldNotes, FieldTags, FieldFavorite,
+}
+
+// aliases maps the headers other address books write, folded by headerKey,
+// to fields. Every field also matches its own name.
+var aliases = map[string]string{
+	"fullname":      FieldName,
+	"displayname":   FieldName,
+	"givenname":     FieldFirstName,
+	"familyname":    FieldLastName,
+	"surname":       FieldLastName,
+	"company":       FieldOrganization,
+	"organisation":  FieldOrganization,
+	"title":         FieldJobTitle,
+	"mobile":        FieldPhone,
+	"mobilephone":   FieldPhone,
+	"cell":          FieldPhone,
+	"cellphone":     FieldPhone,
+	"tel":           FieldPhone,
+	"telephone":     FieldPhone,
+	"phonenumber":   FieldPhone,
+	"workphone":     FieldPhoneWork,
+	"businessphone": FieldPhoneWork,
+	"homephone":     FieldPhoneHome,
+	"otherphone":    FieldPhoneOther,
+	"emailaddress":  FieldEmail,
+	"mail":          FieldEmail,
+	"workemail":     FieldEmailWork,
+	"homeemail":     FieldEmailHome,
+	"address":       FieldStreet,
+	"town":          FieldCity,
+	"state":         FieldRegion,
+	"province":      FieldRegion,
+	"zip":           FieldPostalCode,
+	"zipcode":       FieldPostalCode,
+	"postcode":      FieldPostalCode,
+	"birthdate":     FieldBirthday,
+	"dateofbirth":   FieldBirthday,
+	"note":          FieldNotes,
+	"comments":      FieldNotes,
+	"categories":    FieldTags,
+	"labels":        FieldTags,
+}
+
+// headerKey folds a header for matching: "E-mail Address" and "emailaddress"
+// are the same column.
+func headerKey(header string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, header)
+}
+
+// lookupField returns the field a header stands for.
+func lookupField(header string) (string, bool) {
+	key := headerKey(header)
+	for _, field := range Fields {
+		if strings.EqualFold(field, key) {
+			return field, true
+		}
+	}
+	field, ok := aliases[key]
+	return field, ok
+}
+
+// ParseMapping reads a column mapping written as "column=field" pairs
+// separated by commas, e.g. "Full Name=name,Mobile=phone". A column is a
+// header or a column number starting at 1. Fields are matched like headers.
+func ParseMapping(value string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		column, name, ok := strings.Cut(pair, "=")
+		column = strings.TrimSpace(column)
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid column mapping '%s', expected column=field", strings.TrimSpace(pair))
+		}
+		field, ok := lookupField(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field '%s' in column mapping, expected one of %s", strings.TrimSpace(name), strings.Join(Fields, ", "))
+		}
+		mapping[column] = field
+	}
+	return mapping, nil
+}
+
+// ParseFields reads a comma-separated list of fields, as chosen for Encode.
+func ParseFields(value string) ([]string, error) {
+	var fields []string
+	for _, name := range strings.Split(value, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		field, ok := lookupField(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field '%s', expected one of %s", strings.TrimSpace(name), strings.Join(Fields, ", "))
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+// ParseDelimiter reads a delimiter given as the character itself or as
+// "tab". An empty value asks Decode to detect it.
+func ParseDelimiter(value string) (rune, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	runes := []rune(value)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' || runes[0] == unicode.ReplacementChar {
+		return 0, fmt.Errorf("invalid delimiter %s, expected a single character or tab", strconv.Quote(value))
+	}
+	return runes[0], nil
+}
+
+// ParseError reports a CSV file that cannot be read at all. Errors in single
+// rows are reported in their Row instead.
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid CSV at line %d: %s", e.Line, e.Message)
+}
@@ -0,0 +1,224 @@
+package csvfile
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+func TestDecode_Header(t *testing.T) {
+	input := "\uFEFFFull Name;Mobile;E-mail Address;Company;Department;Categories;Favorite\r\n" +
+		"Ada Lovelace;+44 7911 123456;ada@engines.example;\"Engines; Ltd\";R&D;vip, pioneer;yes\r\n" +
+		";;;;;;\r\n" +
+		"Grace Hopper;;grace@navy.example;;;;maybe\r\n"
+
+	file, err := Decode(strings.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if file.Comma != ';' || file.Encoding != EncodingUTF8 || len(file.Header) != 7 {
+		t.Errorf("Expected a ';' delimited UTF-8 file with a header, got %q %s %v", file.Comma, file.Encoding, file.Header)
+	}
+	expectedColumns := []string{FieldName, FieldPhone, FieldEmail, FieldOrganization, "", FieldTags, FieldFavorite}
+	if !slices.Equal(file.Columns, expectedColumns) {
+		t.Errorf("Expected columns %v, got %v", expectedColumns, file.Columns)
+	}
+	if len(file.Rows) != 2 {
+		t.Fatalf("Expected 2 rows, blank ones skipped, got %d", len(file.Rows))
+	}
+
+	expected := domain.Contact{
+		Name:         "Ada Lovelace",
+		Organization: "Engines; Ltd",
+		Phones:       []domain.Phone{{Type: domain.PhoneMobile, Number: "+44 7911 123456"}},
+		Emails:       []domain.Email{{Address: "ada@engines.example"}},
+		Tags:         []string{"vip", "pioneer"},
+		Favorite:     true,
+	}
+	if row := file.Rows[0]; row.Line != 2 || row.Err != nil || !reflect.DeepEqual(row.Contact, expected) {
+		t.Errorf("Expected Ada on line 2, got %+v", row)
+	}
+	if row := file.Rows[1]; row.Line != 4 || row.Err == nil || !strings.Contains(row.Err.Error(), "favorite") {
+		t.Errorf("Expected a favorite error on line 4, got %+v", row)
+	}
+}
+
+func TestDecode_Detection(t *testing.T) {
+	byNumber := map[string]string{"1": FieldName, "2": FieldPhone}
+	tests := []struct {
+		name     string
+		input    []byte
+		mapping  map[string]string
+		comma    rune
+		encoding string
+		header   bool
+	}{
+		{"Comma", []byte("name,phone\nAda,+447911123456\n"), nil, ',', EncodingUTF8, true},
+		{"Tab", []byte("name\tphone\nAda\t+447911123456\n"), nil, '\t', EncodingUTF8, true},
+		{"Pipe", []byte("Ada|+447911123456\n"), byNumber, '|', EncodingUTF8, false},
+		{"QuotedDelimiters", []byte("\"Ada, Countess; of Lovelace\"|+447911123456\n"), byNumber, '|', EncodingUTF8, false},
+		{"Latin1", []byte("Nom;T\xe9l\xe9phone\nZo\xeb;+33612345678\n"), byNumber, ';', EncodingLatin1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Decode(bytes.NewReader(tt.input), Options{Mapping: tt.mapping})
+			if err != nil {
+				t.Fatalf("Failed to decode: %v", err)
+			}
+			if file.Comma != tt.comma || file.Encoding != tt.encoding || (file.Header != nil) != tt.header {
+				t.Errorf("Expected %q, %s and header %t, got %q, %s and %v", tt.comma, tt.encoding, tt.header, file.Comma, file.Encoding, file.Header)
+			}
+		})
+	}
+}
+
+func TestDecode_Latin1(t *testing.T) {
+	input := []byte("Zo\xeb \xc5ngstr\xf6m;+33612345678\n")
+	file, err := Decode(bytes.NewReader(input), Options{Mapping: map[string]string{"1": FieldName, "2": FieldPhone}})
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if len(file.Rows) != 1 {
+		t.Fatalf("Expected 1 row, got %d", len(file.Rows))
+	}
+	if name := file.Rows[0].Contact.Name; name != "Zoë Ångström" {
+		t.Errorf("Expected Latin-1 to be decoded, got '%s'", name)
+	}
+}
+
+func TestDecode_Mapping(t *testing.T) {
+	input := "Nom,Téléphone,Ville,Notes\nAda Lovelace,+447911123456,London,First programmer\n"
+
+	mapping, err := ParseMapping("Nom=name, téléphone=Work Phone,3=city")
+	if err != nil {
+		t.Fatalf("Failed to parse mapping: %v", err)
+	}
+	file, err := Decode(strings.NewReader(input), Options{Mapping: mapping})
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	expected := domain.Contact{
+		Name:      "Ada Lovelace",
+		Phones:    []domain.Phone{{Type: domain.PhoneWork, Number: "+447911123456"}},
+		Addresses: []domain.Address{{City: "London"}},
+		Notes:     "First programmer",
+	}
+	if len(file.Rows) != 1 || !reflect.DeepEqual(file.Rows[0].Contact, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, file.Rows)
+	}
+
+	if _, err := ParseMapping("Nom=nickname"); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+	if _, err := ParseMapping("=name"); err == nil {
+		t.Error("Expected an error for a missing column")
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		mapping map[string]string
+		line    int
+	}{
+		{"BareQuote", "name,phone\nAda,+44\nGrace \"Amazing\" Hopper,+1\n", nil, 3},
+		{"NoNameColumn", "phone,email\n+447911123456,ada@example.com\n", nil, 1},
+		{"NoHeaderNorMapping", "Ada Lovelace,+447911123456\n", nil, 1},
+		{"UnknownMappedColumn", "name,phone\nAda,+44\n", map[string]string{"Mobile": FieldPhone}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.input), Options{Mapping: tt.mapping})
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a *ParseError, got %v", err)
+			}
+			if parseErr.Line != tt.line {
+				t.Errorf("Expected the error at line %d, got %d: %v", tt.line, parseErr.Line, err)
+			}
+		})
+	}
+
+	file, err := Decode(strings.NewReader(""), Options{})
+	if err != nil || len(file.Rows) != 0 {
+		t.Errorf("Expected an empty file to hold no rows, got %v, %v", file, err)
+	}
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	contacts := []domain.Contact{
+		{
+			ID:           "1b4e28ba-2fa1-11d2-883f-0016d3cca427",
+			Name:         "Ada Lovelace",
+			FirstName:    "Ada",
+			LastName:     "Lovelace",
+			Organization: "Engines, \"Babbage\" and Co",
+			Phones: []domain.Phone{
+				{Type: domain.PhoneMobile, Number: "+447911123456"},
+				{Type: domain.PhoneWork, Number: "+442079460000"},
+			},
+			Emails:    []domain.Email{{Type: "work", Address: "ada@engines.example"}},
+			Addresses: []domain.Address{{Street: "12 St James's Square", City: "London", PostalCode: "SW1Y 4JH"}},
+			Birthday:  "1815-12-10",
+			Notes:     "Line one\nLine two",
+			Tags:      []string{"vip", "pioneer"},
+			Favorite:  true,
+		},
+		{ID: "other-id", Name: "Zoë Ångström", FirstName: "Zoë", LastName: "Ångström"},
+	}
+
+	for _, comma := range []rune{',', ';', '\t'} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, contacts, Options{Comma: comma}); err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+
+		file, err := Decode(&buf, Options{})
+		if err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if file.Comma != comma || !slices.Equal(file.Columns, Fields) {
+			t.Errorf("Expected %q and every field, got %q and %v", comma, file.Comma, file.Columns)
+		}
+
+		var decoded []domain.Contact
+		for _, row := range file.Rows {
+			decoded = append(decoded, row.Contact)
+		}
+		if !reflect.DeepEqual(decoded, contacts) {
+			t.Errorf("Expected %+v, got %+v", contacts, decoded)
+		}
+	}
+}
+
+func TestEncode_Fields(t *testing.T) {
+	contact := domain.Contact{
+		Name:   "Ada Lovelace",
+		Phones: []domain.Phone{{Type: domain.PhoneMobile, Number: "+447911123456", Display: "+44 7911 123456"}},
+	}
+
+	fields, err := ParseFields("name, Mobile,name")
+	if err != nil {
+		t.Fatalf("Failed to parse fields: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, []domain.Contact{contact}, Options{Fields: fields}); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if expected := "name,phone\nAda Lovelace,+44 7911 123456\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	if _, err := ParseFields("name,nickname"); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
@@ -0,0 +1,346 @@
+package csvfile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+// Encodings Decode tells apart.
+const (
+	EncodingUTF8   = "UTF-8"
+	EncodingLatin1 = "Latin-1"
+)
+
+// delimiters are the delimiters Decode detects, the first one winning ties.
+var delimiters = []rune{',', ';', '\t', '|'}
+
+// Options tune how a file is read and written.
+type Options struct {
+	// Comma is the delimiter. Decode detects it when zero, and Encode uses
+	// a comma.
+	Comma rune
+	// Mapping maps columns, by header or by number starting at 1, to
+	// fields. Decode matches the other columns by header.
+	Mapping map[string]string
+	// Fields lists the columns Encode writes, all of Fields when empty.
+	Fields []string
+}
+
+// File is a decoded CSV file.
+type File struct {
+	Comma    rune
+	Encoding string
+	// Header holds the first row when it names the columns, nil otherwise.
+	Header []string
+	// Columns holds the field each column was read into, "" for columns
+	// that were ignored.
+	Columns []string
+	Rows    []Row
+}
+
+// Row is one data row. Line is the line it starts on. Err is set, and
+// Contact left empty, when the row cannot be read.
+type Row struct {
+	Line    int
+	Contact domain.Contact
+	Err     error
+}
+
+// Decode reads a CSV file of contacts. The encoding is UTF-8, with or
+// without a byte order mark, or Latin-1 when the file is not valid UTF-8.
+// The first row is read as a header when it names columns, or holds a
+// column of the mapping. Columns of a file without a header must be mapped
+// by number.
+func Decode(r io.Reader, opts Options) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+
+	file := &File{Comma: opts.Comma, Encoding: EncodingUTF8}
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+	text := string(data)
+	if !utf8.Valid(data) {
+		file.Encoding = EncodingLatin1
+		text = decodeLatin1(data)
+	}
+	if file.Comma == 0 {
+		file.Comma = detectDelimiter(text)
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = file.Comma
+	reader.FieldsPerRecord = -1
+	// Leading spaces would swallow empty cells of a tab-delimited file.
+	reader.TrimLeadingSpace = !unicode.IsSpace(file.Comma)
+
+	var (
+		records [][]string
+		lines   []int
+	)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, &ParseError{Line: parseErr.Line, Message: parseErr.Err.Error()}
+			}
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	if len(records) == 0 {
+		return file, nil
+	}
+
+	if isHeader(records[0], opts.Mapping) {
+		file.Header = records[0]
+		records, lines = records[1:], lines[1:]
+	}
+	if file.Columns, err = columns(file.Header, records, opts.Mapping); err != nil {
+		return nil, err
+	}
+
+	for i, record := range records {
+		if blank(record) {
+			continue
+		}
+		row := Row{Line: lines[i]}
+		row.Contact, row.Err = decodeRecord(record, file.Columns)
+		file.Rows = append(file.Rows, row)
+	}
+	return file, nil
+}
+
+// decodeLatin1 maps every byte to the code point of the same value.
+func decodeLatin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// detectDelimiter returns the delimiter found most often outside quotes on
+// the first line that is not empty.
+func detectDelimiter(text string) rune {
+	var line string
+	for _, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) != "" {
+			line = l
+			break
+		}
+	}
+
+	counts := make(map[rune]int)
+	quoted := false
+	for _, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if !quoted {
+			counts[r]++
+		}
+	}
+
+	best := delimiters[0]
+	for _, d := range delimiters[1:] {
+		if counts[d] > counts[best] {
+			best = d
+		}
+	}
+	return best
+}
+
+// isHeader reports whether record names columns: it holds a column of the
+// mapping, or at least half its cells are known headers.
+func isHeader(record []string, mapping map[string]string) bool {
+	var cells, known int
+	for _, cell := range record {
+		if strings.TrimSpace(cell) == "" {
+			continue
+		}
+		cells++
+		if _, ok := mappedColumn(mapping, cell); ok {
+			return true
+		}
+		if _, ok := lookupField(cell); ok {
+			known++
+		}
+	}
+	return cells > 0 && known*2 >= cells
+}
+
+// mappedColumn returns the field mapping gives to the column with the given
+// header.
+func mappedColumn(mapping map[string]string, header string) (string, bool) {
+	key := headerKey(header)
+	if key == "" {
+		return "", false
+	}
+	for column, field := range mapping {
+		if headerKey(column) == key {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// columns returns the field of each column.
+func columns(header []string, records [][]string, mapping map[string]string) ([]string, error) {
+	width := len(header)
+	for _, record := range records {
+		width = max(width, len(record))
+	}
+
+	fields := make([]string, width)
+	for i := range fields {
+		if field, ok := mapping[strconv.Itoa(i+1)]; ok {
+			fields[i] = field
+			continue
+		}
+		if i < len(header) {
+			if field, ok := mappedColumn(mapping, header[i]); ok {
+				fields[i] = field
+			} else {
+				fields[i], _ = lookupField(header[i])
+			}
+		}
+	}
+
+	for column := range mapping {
+		if n, err := strconv.Atoi(column); err == nil {
+			if n < 1 {
+				return nil, &ParseError{Line: 1, Message: fmt.Sprintf("column numbers start at 1, got %d", n)}
+			}
+			continue
+		}
+		found := false
+		for _, h := range header {
+			found = found || headerKey(h) == headerKey(column)
+		}
+		if !found {
+			return nil, &ParseError{Line: 1, Message: fmt.Sprintf("no column is named '%s'", column)}
+		}
+	}
+
+	for _, field := range fields {
+		if field == FieldName || field == FieldFirstName || field == FieldLastName {
+			return fields, nil
+		}
+	}
+	return nil, &ParseError{Line: 1, Message: "no column holds a name: name the columns in a header, or map them, e.g. 1=name,2=phone"}
+}
+
+func blank(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeRecord reads the cells of a data row into a contact.
+func decodeRecord(record, columns []string) (domain.Contact, error) {
+	var contact domain.Contact
+	for i, cell := range record {
+		value := strings.TrimSpace(cell)
+		if i >= len(columns) || columns[i] == "" || value == "" {
+			continue
+		}
+		if err := setField(&contact, columns[i], value); err != nil {
+			return domain.Contact{}, err
+		}
+	}
+	return contact, nil
+}
+
+func setField(contact *domain.Contact, field, value string) error {
+	address := func() *domain.Address {
+		if len(contact.Addresses) == 0 {
+			contact.Addresses = []domain.Address{{}}
+		}
+		return &contact.Addresses[0]
+	}
+
+	switch field {
+	case FieldID:
+		contact.ID = value
+	case FieldName:
+		contact.Name = value
+	case FieldFirstName:
+		contact.FirstName = value
+	case FieldLastName:
+		contact.LastName = value
+	case FieldOrganization:
+		contact.Organization = value
+	case FieldJobTitle:
+		contact.JobTitle = value
+	case FieldPhone:
+		contact.Phones = append(contact.Phones, domain.Phone{Type: domain.PhoneMobile, Number: value})
+	case FieldPhoneWork:
+		contact.Phones = append(contact.Phones, domain.Phone{Type: domain.PhoneWork, Number: value})
+	case FieldPhoneHome:
+		contact.Phones = append(contact.Phones, domain.Phone{Type: domain.PhoneHome, Number: value})
+	case FieldPhoneOther:
+		contact.Phones = append(contact.Phones, domain.Phone{Type: domain.PhoneOther, Number: value})
+	case FieldEmail:
+		contact.Emails = append(contact.Emails, domain.Email{Address: value})
+	case FieldEmailWork:
+		contact.Emails = append(contact.Emails, domain.Email{Type: "work", Address: value})
+	case FieldEmailHome:
+		contact.Emails = append(contact.Emails, domain.Email{Type: "home", Address: value})
+	case FieldStreet:
+		address().Street = value
+	case FieldCity:
+		address().City = value
+	case FieldRegion:
+		address().Region = value
+	case FieldPostalCode:
+		address().PostalCode = value
+	case FieldCountry:
+		address().Country = value
+	case FieldBirthday:
+		contact.Birthday = value
+	case FieldNotes:
+		contact.Notes = value
+	case FieldTags:
+		for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				contact.Tags = append(contact.Tags, tag)
+			}
+		}
+	case FieldFavorite:
+		favorite, err := parseBool(value)
+		if err != nil {
+			return fmt.Errorf("favorite must be true or false, got '%s'", value)
+		}
+		contact.Favorite = favorite
+	}
+	return nil
+}
+
+// parseBool reads the booleans of strconv.ParseBool, and yes or no.
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "y":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
@@ -0,0 +1,120 @@
+package csvfile
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+// Encode writes contacts to w as UTF-8 CSV, with a header of field names.
+// Only the first phone and email of each type, and the first address, fit
+// in their columns.
+func Encode(w io.Writer, contacts []domain.Contact, opts Options) error {
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = Fields
+	}
+
+	writer := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		writer.Comma = opts.Comma
+	}
+
+	if err := writer.Write(fields); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	record := make([]string, len(fields))
+	for _, contact := range contacts {
+		for i, field := range fields {
+			record[i] = fieldValue(contact, field)
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+func fieldValue(contact domain.Contact, field string) string {
+	var address domain.Address
+	if len(contact.Addresses) > 0 {
+		address = contact.Addresses[0]
+	}
+
+	switch field {
+	case FieldID:
+		return contact.ID
+	case FieldName:
+		return contact.Name
+	case FieldFirstName:
+		return contact.FirstName
+	case FieldLastName:
+		return contact.LastName
+	case FieldOrganization:
+		return contact.Organization
+	case FieldJobTitle:
+		return contact.JobTitle
+	case FieldPhone:
+		return phoneOfType(contact, domain.PhoneMobile)
+	case FieldPhoneWork:
+		return phoneOfType(contact, domain.PhoneWork)
+	case FieldPhoneHome:
+		return phoneOfType(contact, domain.PhoneHome)
+	case FieldPhoneOther:
+		return phoneOfType(contact, domain.PhoneOther)
+	case FieldEmail:
+		return emailOfType(contact, "")
+	case FieldEmailWork:
+		return emailOfType(contact, "work")
+	case FieldEmailHome:
+		return emailOfType(contact, "home")
+	case FieldStreet:
+		return address.Street
+	case FieldCity:
+		return address.City
+	case FieldRegion:
+		return address.Region
+	case FieldPostalCode:
+		return address.PostalCode
+	case FieldCountry:
+		return address.Country
+	case FieldBirthday:
+		return contact.Birthday
+	case FieldNotes:
+		return contact.Notes
+	case FieldTags:
+		return strings.Join(contact.Tags, ", ")
+	case FieldFavorite:
+		if contact.Favorite {
+			return strconv.FormatBool(contact.Favorite)
+		}
+	}
+	return ""
+}
+
+func phoneOfType(contact domain.Contact, phoneType domain.PhoneType) string {
+	for _, phone := range contact.Phones {
+		if phone.Type == phoneType {
+			return phone.String()
+		}
+	}
+	return ""
+}
+
+func emailOfType(contact domain.Contact, emailType string) string {
+	for _, email := range contact.Emails {
+		if email.Type == emailType {
+			return email.Address
+		}
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+	AuditPurge   AuditAction = "purge"
+)
+
+// AuditEntry records a change made to a contact: who made it, when, and the
+// contact before and after it. Before is nil for a created or restored
+// contact, After for a deleted or purged one. A deleted contact goes to the
+// trash, a purged one is gone for good.
+type AuditEntry struct {
+	Time      time.Time   `json:"time"`
+	Actor     string      `json:"actor,omitempty"`
+	Action    AuditAction `json:"action"`
+	ContactID string      `json:"contactId"`
+	Before    *Contact    `json:"before,omitempty"`
+	After     *Contact    `json:"after,omitempty"`
+}
+
+// Name returns the name of the contact after the change, or before it for a
+// deletion.
+func (e AuditEntry) Name() string {
+	if e.After != nil {
+		return e.After.Name
+	}
+	if e.Before != nil {
+		return e.Before.Name
+	}
+	return ""
+}
+
+// Changes lists the fields the change set, changed or cleared.
+func (e AuditEntry) Changes() []FieldChange {
+	var before, after Contact
+	if e.Before != nil {
+		before = *e.Before
+	}
+	if e.After != nil {
+		after = *e.After
+	}
+	return CompareContacts(before, after)
+}
+
+// FieldChange is the value of a contact field before and after a change, as
+// shown to users. An empty value means the field was not set.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// CompareContacts lists the fields that differ between two versions of a
+// contact. Its ID and timestamps are not compared.
+func CompareContacts(before, after Contact) []FieldChange {
+	var changes []FieldChange
+	for _, field := range contactFields {
+		if b, a := field.value(before), field.value(after); b != a {
+			changes = append(changes, FieldChange{Field: field.name, Before: b, After: a})
+		}
+	}
+	return changes
+}
+
+var contactFields = []struct {
+	name  string
+	value func(Contact) string
+}{
+	{"name", func(c Contact) string { return c.Name }},
+	{"firstName", func(c Contact) string { return c.FirstName }},
+	{"lastName", func(c Contact) string { return c.LastName }},
+	{"organization", func(c Contact) string { return c.Organization }},
+	{"jobTitle", func(c Contact) string { return c.JobTitle }},
+	{"phones", func(c Contact) string {
+		return joinEach(c.Phones, func(p Phone) string { return string(p.Type) + " " + p.Number })
+	}},
+	{"emails", func(c Contact) string {
+		return joinEach(c.Emails, func(e Email) string { return strings.TrimSpace(e.Type + " " + e.Address) })
+	}},
+	{"addresses", func(c Contact) string {
+		return joinEach(c.Addresses, func(a Address) string {
+			return fmt.Sprintf("%s: %s, %s %s, %s, %s", a.Type, a.Street, a.PostalCode, a.City, a.Region, a.Country)
+		})
+	}},
+	{"birthday", func(c Contact) string { return c.Birthday }},
+	{"notes", func(c Contact) string { return c.Notes }},
+	{"tags", func(c Contact) string { return strings.Join(c.Tags, ", ") }},
+	{"groups", func(c Contact) string { return strings.Join(c.Groups, ", ") }},
+	{"favorite", func(c Contact) string {
+		if c.Favorite {
+			return "yes"
+		}
+		return ""
+	}},
+}
+
+func joinEach[T any](items []T, format func(T) string) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = format(item)
+	}
+	return strings.Join(parts, "; ")
+}
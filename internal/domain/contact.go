@@ -1,13 +1,179 @@
 package domain
 
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PhoneType string
+
+const (
+	PhoneMobile PhoneType = "mobile"
+	PhoneWork   PhoneType = "work"
+	PhoneHome   PhoneType = "home"
+	PhoneOther  PhoneType = "other"
+)
+
+// Phone holds a number in its canonical E.164 form and in the form shown to
+// users.
+type Phone struct {
+	Type    PhoneType `json:"type"`
+	Number  string    `json:"number"`
+	Display string    `json:"display,omitempty"`
+}
+
+// String returns the display form, falling back to the stored number.
+func (p Phone) String() string {
+	if p.Display != "" {
+		return p.Display
+	}
+	return p.Number
+}
+
+type Email struct {
+	Type    string `json:"type,omitempty"`
+	Address string `json:"address"`
+}
+
+type Address struct {
+	Type       string `json:"type,omitempty"`
+	Street     string `json:"street,omitempty"`
+	City       string `json:"city,omitempty"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postalCode,omitempty"`
+	Country    string `json:"country,omitempty"`
+}
+
+// Contact is a directory entry. Name is the display name; FirstName and
+// LastName hold its structured parts when they are known. Birthday uses the
+// YYYY-MM-DD layout. Groups holds the IDs of the groups the contact belongs
+// to. CreatedAt is zero for contacts stored before it was tracked, and
+// LastAccessedAt for contacts never looked up. DeletedAt is set while the
+// contact is in the trash.
 type Contact struct {
-	Name  string `json:"name"`
-	Phone string `json:"phone"`
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	FirstName    string    `json:"firstName,omitempty"`
+	LastName     string    `json:"lastName,omitempty"`
+	Organization string    `json:"organization,omitempty"`
+	JobTitle     string    `json:"jobTitle,omitempty"`
+	Phones       []Phone   `json:"phones,omitempty"`
+	Emails       []Email   `json:"emails,omitempty"`
+	Addresses    []Address `json:"addresses,omitempty"`
+	Birthday     string    `json:"birthday,omitempty"`
+	Notes        string    `json:"notes,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`
+	Groups       []string  `json:"groups,omitempty"`
+	Favorite     bool      `json:"favorite,omitempty"`
+	CreatedAt    time.Time `json:"createdAt,omitzero"`
+
+	LastAccessedAt time.Time `json:"lastAccessedAt,omitzero"`
+	DeletedAt      time.Time `json:"deletedAt,omitzero"`
 }
 
 func NewContact(name, phone string) Contact {
-	return Contact{
-		Name:  name,
-		Phone: phone,
+	first, last := SplitName(name)
+	contact := Contact{
+		ID:        NewID(),
+		Name:      name,
+		FirstName: first,
+		LastName:  last,
+	}
+
+	if phone != "" {
+		contact.Phones = []Phone{{Type: PhoneMobile, Number: phone}}
+	}
+
+	return contact
+}
+
+func NewID() string {
+	return uuid.NewString()
+}
+
+// PrimaryPhone returns the first phone number, or an empty string.
+func (c Contact) PrimaryPhone() string {
+	if len(c.Phones) == 0 {
+		return ""
+	}
+	return c.Phones[0].Number
+}
+
+// SetPrimaryPhone replaces the first phone number, keeping its type, or adds
+// a mobile number when the contact has none.
+func (c *Contact) SetPrimaryPhone(number string) {
+	if len(c.Phones) == 0 {
+		c.Phones = []Phone{{Type: PhoneMobile, Number: number}}
+		return
+	}
+	c.Phones[0].Number = number
+	c.Phones[0].Display = ""
+}
+
+// PrimaryEmail returns the first email address, or an empty string.
+func (c Contact) PrimaryEmail() string {
+	if len(c.Emails) == 0 {
+		return ""
+	}
+	return c.Emails[0].Address
+}
+
+// InGroup reports whether the contact belongs to the group with the given ID.
+func (c Contact) InGroup(id string) bool {
+	return slices.Contains(c.Groups, id)
+}
+
+// Clone returns a copy of c that shares no slices with it.
+func (c Contact) Clone() Contact {
+	c.Phones = append([]Phone(nil), c.Phones...)
+	c.Emails = append([]Email(nil), c.Emails...)
+	c.Addresses = append([]Address(nil), c.Addresses...)
+	c.Tags = append([]string(nil), c.Tags...)
+	c.Groups = append([]string(nil), c.Groups...)
+	return c
+}
+
+// UnmarshalJSON also accepts the original flat format, where a contact only
+// had a name and a single phone.
+func (c *Contact) UnmarshalJSON(data []byte) error {
+	type contact Contact
+	var decoded struct {
+		contact
+		Phone string `json:"phone"`
+	}
+
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*c = Contact(decoded.contact)
+
+	if decoded.Phone != "" && len(c.Phones) == 0 {
+		c.Phones = []Phone{{Type: PhoneMobile, Number: decoded.Phone}}
+	}
+
+	if c.FirstName == "" && c.LastName == "" {
+		c.FirstName, c.LastName = SplitName(c.Name)
 	}
+
+	return nil
+}
+
+// SplitName splits a "firstname lastname" display name on its last space.
+func SplitName(name string) (first, last string) {
+	name = strings.TrimSpace(name)
+	i := strings.LastIndex(name, " ")
+	if i < 0 {
+		return name, ""
+	}
+	return strings.TrimSpace(name[:i]), name[i+1:]
+}
+
+// JoinName builds a display name from its parts.
+func JoinName(first, last string) string {
+	return strings.TrimSpace(strings.TrimSpace(first) + " " + strings.TrimSpace(last))
 }
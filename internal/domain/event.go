@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+type EventType string
+
+const (
+	ContactCreated EventType = "contact.created"
+	ContactUpdated EventType = "contact.updated"
+	ContactDeleted EventType = "contact.deleted"
+)
+
+// Event tells subscribers that a contact changed. Contact is the contact
+// after the change, or as it was when deleted. Previous is only set for an
+// update. A contact restored from the trash is created again.
+type Event struct {
+	ID       string    `json:"id"`
+	Type     EventType `json:"type"`
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor,omitempty"`
+	Contact  Contact   `json:"contact"`
+	Previous *Contact  `json:"previous,omitempty"`
+}
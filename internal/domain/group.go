@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// Group is a named set of contacts. Membership is recorded on the contacts,
+// so a contact can be in any number of groups.
+type Group struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt,omitzero"`
+}
+
+func NewGroup(name string) Group {
+	return Group{
+		ID:   NewID(),
+		Name: name,
+	}
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// Snapshot is a copy of the whole directory at a point in time: every
+// contact, those in the trash included, and every group.
+type Snapshot struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	Actor     string    `json:"actor,omitempty"`
+	Contacts  []Contact `json:"contacts"`
+	Groups    []Group   `json:"groups,omitempty"`
+}
+
+// Live returns the contacts of the snapshot that were not in the trash.
+func (s Snapshot) Live() []Contact {
+	var live []Contact
+	for _, contact := range s.Contacts {
+		if contact.DeletedAt.IsZero() {
+			live = append(live, contact)
+		}
+	}
+	return live
+}
@@ -0,0 +1,227 @@
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultRegion is used for national numbers when no region is configured.
+const DefaultRegion = "US"
+
+const (
+	// maxDigits is the longest number E.164 allows, country code included.
+	maxDigits = 15
+	// minNationalDigits applies to calling codes without a region entry.
+	minNationalDigits = 4
+)
+
+var (
+	ErrEmpty              = errors.New("phone number is empty")
+	ErrInvalidCharacters  = errors.New("phone number may only contain digits, spaces, '+', '-', '.', '/' and parentheses")
+	ErrMissingRegion      = errors.New("national number needs a default region or a leading '+' and country code")
+	ErrUnknownRegion      = errors.New("unknown region")
+	ErrInvalidCountryCode = errors.New("invalid country calling code")
+	ErrTooShort           = errors.New("phone number is too short")
+	ErrTooLong            = errors.New("phone number is too long")
+)
+
+// ParseError reports why a phone number was rejected. Use errors.Is with the
+// Err* values to tell the reasons apart.
+type ParseError struct {
+	Input string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid phone number '%s': %v", e.Input, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Number is a parsed phone number.
+type Number struct {
+	// CountryCode is the calling code without the "+", e.g. "33".
+	CountryCode string
+	// National is the national significant number, without trunk prefix.
+	National string
+	// Region is the ISO 3166 code the number belongs to, or "" when its
+	// calling code is not in the region table.
+	Region string
+}
+
+// E164 returns the canonical form, e.g. "+33612345678".
+func (n Number) E164() string {
+	return "+" + n.CountryCode + n.National
+}
+
+// Display returns the international form grouped for reading, e.g.
+// "+33 6 12 34 56 78".
+func (n Number) Display() string {
+	groups := []int{3, 3, 4}
+	if info, ok := regions[n.Region]; ok {
+		groups = info.groups
+	}
+	return "+" + n.CountryCode + " " + group(n.National, groups)
+}
+
+func (n Number) String() string {
+	return n.E164()
+}
+
+// Parse reads a number written in national ("06 12 34 56 78") or
+// international ("+33 6 12 34 56 78", "0033 6...") format. National numbers
+// are read in defaultRegion.
+func Parse(raw, defaultRegion string) (Number, error) {
+	number, err := parse(raw, strings.ToUpper(strings.TrimSpace(defaultRegion)))
+	if err != nil {
+		return Number{}, &ParseError{Input: strings.TrimSpace(raw), Err: err}
+	}
+	return number, nil
+}
+
+// Normalize returns the E.164 and display forms of raw.
+func Normalize(raw, defaultRegion string) (canonical, display string, err error) {
+	number, err := Parse(raw, defaultRegion)
+	if err != nil {
+		return "", "", err
+	}
+	return number.E164(), number.Display(), nil
+}
+
+// KnownRegion reports whether region is in the region table.
+func KnownRegion(region string) bool {
+	_, ok := regions[strings.ToUpper(strings.TrimSpace(region))]
+	return ok
+}
+
+func parse(raw, defaultRegion string) (Number, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Number{}, ErrEmpty
+	}
+
+	// "+33 (0)6 12..." is a common way of writing the optional trunk prefix.
+	raw = strings.Replace(raw, "(0)", "", 1)
+
+	international := strings.HasPrefix(raw, "+")
+	digits, err := extractDigits(strings.TrimPrefix(raw, "+"))
+	if err != nil {
+		return Number{}, err
+	}
+	if digits == "" {
+		return Number{}, ErrEmpty
+	}
+
+	if !international {
+		if defaultRegion == "" {
+			if rest, found := strings.CutPrefix(digits, "00"); found {
+				return parseInternational(rest)
+			}
+			return Number{}, ErrMissingRegion
+		}
+
+		info, ok := regions[defaultRegion]
+		if !ok {
+			return Number{}, fmt.Errorf("%w '%s'", ErrUnknownRegion, defaultRegion)
+		}
+		if rest, found := strings.CutPrefix(digits, info.exit); found {
+			return parseInternational(rest)
+		}
+		return parseNational(digits, defaultRegion, info)
+	}
+
+	return parseInternational(digits)
+}
+
+func parseNational(digits, regionCode string, info region) (Number, error) {
+	national := digits
+	if rest, found := strings.CutPrefix(digits, info.trunk); found && info.trunk != "" && len(rest) >= info.minLen {
+		national = rest
+	}
+
+	if err := checkLength(national, info.minLen, info.maxLen, info.code); err != nil {
+		return Number{}, err
+	}
+
+	return Number{CountryCode: info.code, National: national, Region: regionCode}, nil
+}
+
+func parseInternational(digits string) (Number, error) {
+	if strings.HasPrefix(digits, "0") {
+		return Number{}, ErrInvalidCountryCode
+	}
+
+	for size := 1; size <= 3 && size < len(digits); size++ {
+		code, national := digits[:size], digits[size:]
+		if !callingCodes[code] {
+			continue
+		}
+
+		regionCode, ok := regionsByCode[code]
+		if !ok {
+			if err := checkLength(national, minNationalDigits, maxDigits, code); err != nil {
+				return Number{}, err
+			}
+			return Number{CountryCode: code, National: national}, nil
+		}
+
+		info := regions[regionCode]
+		if info.trunk == "0" {
+			national = strings.TrimPrefix(national, "0")
+		}
+		if err := checkLength(national, info.minLen, info.maxLen, code); err != nil {
+			return Number{}, err
+		}
+		return Number{CountryCode: code, National: national, Region: regionCode}, nil
+	}
+
+	if len(digits) <= 3 {
+		return Number{}, ErrTooShort
+	}
+	return Number{}, ErrInvalidCountryCode
+}
+
+func extractDigits(value string) (string, error) {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune(" -./() ", r):
+		default:
+			return "", ErrInvalidCharacters
+		}
+	}
+	return b.String(), nil
+}
+
+func checkLength(national string, minLen, maxLen int, code string) error {
+	switch {
+	case len(national) < minLen:
+		return ErrTooShort
+	case len(national) > maxLen, len(code)+len(national) > maxDigits:
+		return ErrTooLong
+	}
+	return nil
+}
+
+// group splits digits into groups of the given sizes separated by spaces. The
+// last group takes whatever is left.
+func group(digits string, sizes []int) string {
+	var parts []string
+	for i, size := range sizes {
+		if len(digits) == 0 {
+			break
+		}
+		if i == len(sizes)-1 || size >= len(digits) {
+			parts = append(parts, digits)
+			break
+		}
+		parts = append(parts, digits[:size])
+		digits = digits[size:]
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,92 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw     string
+		region  string
+		e164    string
+		display string
+	}{
+		{"06 12 34 56 78", "FR", "+33612345678", "+33 6 12 34 56 78"},
+		{"+33 6 12 34 56 78", "US", "+33612345678", "+33 6 12 34 56 78"},
+		{"+33 (0)6 12 34 56 78", "", "+33612345678", "+33 6 12 34 56 78"},
+		{"0033612345678", "FR", "+33612345678", "+33 6 12 34 56 78"},
+		{"(201) 555-0123", "US", "+12015550123", "+1 201 555 0123"},
+		{"1-201-555-0123", "us", "+12015550123", "+1 201 555 0123"},
+		{"011 44 7911 123456", "US", "+447911123456", "+44 7911 123456"},
+		{"07911 123456", "GB", "+447911123456", "+44 7911 123456"},
+		{"+1 234.567.8901", "FR", "+12345678901", "+1 234 567 8901"},
+		{"+372 5123 4567", "FR", "+37251234567", "+372 512 345 67"},
+	}
+
+	for _, tt := range tests {
+		number, err := Parse(tt.raw, tt.region)
+		if err != nil {
+			t.Errorf("Expected '%s' in %s to parse, got %v", tt.raw, tt.region, err)
+			continue
+		}
+		if number.E164() != tt.e164 {
+			t.Errorf("Expected '%s' to normalize to '%s', got '%s'", tt.raw, tt.e164, number.E164())
+		}
+		if number.Display() != tt.display {
+			t.Errorf("Expected '%s' to display as '%s', got '%s'", tt.raw, tt.display, number.Display())
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		raw    string
+		region string
+		err    error
+	}{
+		{"   ", "US", ErrEmpty},
+		{"abc", "US", ErrInvalidCharacters},
+		{"+", "US", ErrEmpty},
+		{"555 1234", "US", ErrTooShort},
+		{"06 12 34 56 78 90", "FR", ErrTooLong},
+		{"06 12 34 56 78", "", ErrMissingRegion},
+		{"06 12 34 56 78", "XX", ErrUnknownRegion},
+		{"+0 612345678", "FR", ErrInvalidCountryCode},
+		{"+999 612345678", "FR", ErrInvalidCountryCode},
+		{"+33 6 12", "FR", ErrTooShort},
+		{"+1 234 567 8901 2345", "US", ErrTooLong},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.raw, tt.region)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Expected '%s' in %s to fail with %v, got %v", tt.raw, tt.region, tt.err, err)
+		}
+
+		var parseErr *ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			t.Errorf("Expected a *ParseError for '%s', got %T", tt.raw, err)
+		}
+	}
+}
+
+func TestParse_Idempotent(t *testing.T) {
+	canonical, display, err := Normalize("06 12 34 56 78", "FR")
+	if err != nil {
+		t.Fatalf("Failed to normalize: %v", err)
+	}
+
+	again, _, err := Normalize(canonical, "US")
+	if err != nil {
+		t.Fatalf("Failed to normalize '%s': %v", canonical, err)
+	}
+	if again != canonical {
+		t.Errorf("Expected '%s' to stay '%s', got '%s'", canonical, canonical, again)
+	}
+
+	again, _, err = Normalize(display, "US")
+	if err != nil || again != canonical {
+		t.Errorf("Expected display form '%s' to parse back to '%s', got '%s' (%v)", display, canonical, again, err)
+	}
+}
@@ -0,0 +1,81 @@
+package phone
+
+import "strings"
+
+// region holds the numbering rules used to parse and display national
+// numbers. Lengths count the national significant number, without trunk
+// prefix.
+type region struct {
+	code   string
+	exit   string
+	trunk  string
+	minLen int
+	maxLen int
+	groups []int
+}
+
+var regions = map[string]region{
+	"US": {code: "1", exit: "011", trunk: "1", minLen: 10, maxLen: 10, groups: []int{3, 3, 4}},
+	"CA": {code: "1", exit: "011", trunk: "1", minLen: 10, maxLen: 10, groups: []int{3, 3, 4}},
+	"FR": {code: "33", exit: "00", trunk: "0", minLen: 9, maxLen: 9, groups: []int{1, 2, 2, 2, 2}},
+	"BE": {code: "32", exit: "00", trunk: "0", minLen: 8, maxLen: 9, groups: []int{3, 2, 2, 2}},
+	"CH": {code: "41", exit: "00", trunk: "0", minLen: 9, maxLen: 9, groups: []int{2, 3, 2, 2}},
+	"LU": {code: "352", exit: "00", minLen: 4, maxLen: 11, groups: []int{3, 3, 3}},
+	"DE": {code: "49", exit: "00", trunk: "0", minLen: 6, maxLen: 11, groups: []int{3, 8}},
+	"GB": {code: "44", exit: "00", trunk: "0", minLen: 9, maxLen: 10, groups: []int{4, 6}},
+	"IE": {code: "353", exit: "00", trunk: "0", minLen: 7, maxLen: 9, groups: []int{2, 3, 4}},
+	"NL": {code: "31", exit: "00", trunk: "0", minLen: 9, maxLen: 9, groups: []int{1, 8}},
+	"ES": {code: "34", exit: "00", minLen: 9, maxLen: 9, groups: []int{3, 3, 3}},
+	"PT": {code: "351", exit: "00", minLen: 9, maxLen: 9, groups: []int{3, 3, 3}},
+	"IT": {code: "39", exit: "00", minLen: 6, maxLen: 11, groups: []int{3, 3, 5}},
+	"MA": {code: "212", exit: "00", trunk: "0", minLen: 9, maxLen: 9, groups: []int{3, 6}},
+	"DZ": {code: "213", exit: "00", trunk: "0", minLen: 8, maxLen: 9, groups: []int{3, 2, 2, 2}},
+	"TN": {code: "216", exit: "00", minLen: 8, maxLen: 8, groups: []int{2, 3, 3}},
+	"SN": {code: "221", exit: "00", minLen: 9, maxLen: 9, groups: []int{2, 3, 2, 2}},
+	"AU": {code: "61", exit: "0011", trunk: "0", minLen: 9, maxLen: 9, groups: []int{3, 3, 3}},
+	"JP": {code: "81", exit: "010", trunk: "0", minLen: 9, maxLen: 10, groups: []int{2, 4, 4}},
+	"CN": {code: "86", exit: "00", trunk: "0", minLen: 10, maxLen: 11, groups: []int{3, 4, 4}},
+	"IN": {code: "91", exit: "00", trunk: "0", minLen: 10, maxLen: 10, groups: []int{5, 5}},
+	"VN": {code: "84", exit: "00", trunk: "0", minLen: 9, maxLen: 10, groups: []int{2, 3, 5}},
+	"BR": {code: "55", exit: "00", trunk: "0", minLen: 10, maxLen: 11, groups: []int{2, 5, 4}},
+	"MX": {code: "52", exit: "00", minLen: 10, maxLen: 10, groups: []int{2, 4, 4}},
+}
+
+// regionsByCode maps a calling code to the region used to validate numbers
+// written with it. Codes shared by several regions use the main one.
+var regionsByCode = map[string]string{}
+
+// callingCodes lists every assigned country calling code. They form a
+// prefix code, so the first match of an international number is its code.
+var callingCodes = map[string]bool{}
+
+func init() {
+	for name, info := range regions {
+		if _, ok := regionsByCode[info.code]; !ok || name == "US" {
+			regionsByCode[info.code] = name
+		}
+	}
+
+	for _, code := range strings.Fields(assignedCodes) {
+		callingCodes[code] = true
+	}
+}
+
+const assignedCodes = `
+1 7 20 27 30 31 32 33 34 36 39 40 41 43 44 45 46 47 48 49
+51 52 53 54 55 56 57 58 60 61 62 63 64 65 66 81 82 84 86
+90 91 92 93 94 95 98
+211 212 213 216 218 220 221 222 223 224 225 226 227 228 229
+230 231 232 233 234 235 236 237 238 239 240 241 242 243 244
+245 246 247 248 249 250 251 252 253 254 255 256 257 258
+260 261 262 263 264 265 266 267 268 269 290 291 297 298 299
+350 351 352 353 354 355 356 357 358 359 370 371 372 373 374
+375 376 377 378 379 380 381 382 383 385 386 387 389
+420 421 423 500 501 502 503 504 505 506 507 508 509
+590 591 592 593 594 595 596 597 598 599
+670 672 673 674 675 676 677 678 679 680 681 682 683 685 686
+687 688 689 690 691 692
+800 808 850 852 853 855 856 870 878 880 881 882 883 886 888
+960 961 962 963 964 965 966 967 968 970 971 972 973 974 975
+976 977 979 992 993 994 995 996 998
+`
@@ -0,0 +1,92 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// folds maps accented Latin letters, already lowercased, to the letters
+// people type when they leave the accents out.
+var folds = buildFolds(
+	"a", "àáâãäåāăą",
+	"c", "çćĉċč",
+	"d", "ďđð",
+	"e", "èéêëēĕėęě",
+	"g", "ĝğġģ",
+	"h", "ĥħ",
+	"i", "ìíîïĩīĭįı",
+	"j", "ĵ",
+	"k", "ķ",
+	"l", "ĺļľŀł",
+	"n", "ñńņňŉ",
+	"o", "òóôõöøōŏő",
+	"r", "ŕŗř",
+	"s", "śŝşšș",
+	"t", "ţťŧț",
+	"u", "ùúûüũūŭůűų",
+	"w", "ŵ",
+	"y", "ýÿŷ",
+	"z", "źżž",
+	"ae", "æ",
+	"oe", "œ",
+	"ss", "ß",
+	"th", "þ",
+)
+
+func buildFolds(pairs ...string) map[rune]string {
+	table := make(map[rune]string)
+	for i := 0; i < len(pairs); i += 2 {
+		for _, r := range pairs[i+1] {
+			table[r] = pairs[i]
+		}
+	}
+	return table
+}
+
+// Fold lowercases s and strips its diacritics, so "Zoë Ångström" and
+// "zoe angstrom" compare equal.
+func Fold(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		r = unicode.ToLower(r)
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Combining accents of decomposed text.
+		case folds[r] != "":
+			b.WriteString(folds[r])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Tokens splits folded s into words made of letters and digits.
+func Tokens(s string) []string {
+	return strings.FieldsFunc(Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// digits keeps the ASCII digits of s.
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// phoneQuery returns the digits of query when it looks like a phone number,
+// i.e. only digits and the usual separators with at least three digits.
+func phoneQuery(query string) (string, bool) {
+	for _, r := range query {
+		if (r < '0' || r > '9') && !strings.ContainsRune(" +-./()", r) {
+			return "", false
+		}
+	}
+	d := digits(query)
+	return d, len(d) >= 3
+}
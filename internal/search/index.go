@@ -0,0 +1,340 @@
+// Package search keeps an in-memory full-text index of contacts.
+package search
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/phone"
+)
+
+// Field weights: a word found in the name counts more than one in the notes.
+const (
+	weightName    = 3
+	weightWork    = 2
+	weightEmail   = 2
+	weightAddress = 1
+	weightNotes   = 1
+	weightTag     = 2
+	weightPhone   = 3
+
+	// exactNameBonus puts the contact whose whole name is the query first.
+	exactNameBonus = 10
+)
+
+// Scores of the ways a query word can match an indexed word, before weights.
+const (
+	scoreExact     = 1.0
+	scorePrefix    = 0.6
+	scoreSubstring = 0.4
+	scoreTypo      = 0.5
+)
+
+// Result is a matching contact ID with its relevance.
+type Result struct {
+	ID    string
+	Score float64
+}
+
+// Index is an inverted index from folded words to the contacts containing
+// them. It is safe for concurrent use.
+type Index struct {
+	mu sync.RWMutex
+	// terms maps a word to the contacts containing it, with the weight of
+	// the most important field it appears in.
+	terms map[string]map[string]float64
+	// words holds the keys of terms, to find the ones a query word matches.
+	words *vocabulary
+	docs  map[string]document
+}
+
+// document is what the index remembers about a contact to update, rank and
+// remove it.
+type document struct {
+	name   string
+	terms  []string
+	phones []string
+}
+
+// New returns an index of contacts.
+func New(contacts []domain.Contact) *Index {
+	ix := &Index{
+		terms: make(map[string]map[string]float64),
+		words: newVocabulary(),
+		docs:  make(map[string]document, len(contacts)),
+	}
+	for _, contact := range contacts {
+		ix.add(contact)
+	}
+	return ix
+}
+
+// Add indexes contact, replacing the previous version with the same ID.
+func (ix *Index) Add(contact domain.Contact) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(contact.ID)
+	ix.add(contact)
+}
+
+// Remove drops the contact with the given ID from the index.
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+}
+
+// Len returns the number of indexed contacts.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	return len(ix.docs)
+}
+
+// Search returns the contacts matching every word of query, best first.
+// Words match indexed words exactly, as a prefix, inside a longer word or
+// with a typo, ignoring case and accents. A query made of a phone number
+// matches the digits of phone numbers whatever their formatting.
+func (ix *Index) Search(query string) []Result {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	words := Tokens(query)
+
+	var scores map[string]float64
+	if number, ok := phoneQuery(query); ok && len(words) > 1 {
+		// "06 12 34" is one number rather than three words.
+		scores = ix.matchPhone(number)
+	} else {
+		for i, word := range words {
+			matches := ix.matchWord(word)
+			if i == 0 {
+				scores = matches
+				continue
+			}
+			for id, score := range scores {
+				if matches[id] == 0 {
+					delete(scores, id)
+				} else {
+					scores[id] = score + matches[id]
+				}
+			}
+		}
+	}
+
+	folded := strings.Join(words, " ")
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		if ix.docs[id].name == folded {
+			score += exactNameBonus
+		}
+		results = append(results, Result{ID: id, Score: score})
+	}
+
+	slices.SortFunc(results, func(a, b Result) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := strings.Compare(ix.docs[a.ID].name, ix.docs[b.ID].name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return results
+}
+
+func (ix *Index) add(contact domain.Contact) {
+	terms := make(map[string]float64)
+	addText := func(text string, weight float64) {
+		for _, term := range Tokens(text) {
+			terms[term] = max(terms[term], weight)
+		}
+	}
+
+	addText(contact.Name, weightName)
+	addText(contact.FirstName, weightName)
+	addText(contact.LastName, weightName)
+	addText(contact.Organization, weightWork)
+	addText(contact.JobTitle, weightWork)
+	for _, email := range contact.Emails {
+		addText(email.Address, weightEmail)
+	}
+	for _, address := range contact.Addresses {
+		addText(strings.Join([]string{address.Street, address.City, address.Region, address.PostalCode, address.Country}, " "), weightAddress)
+	}
+	addText(contact.Notes, weightNotes)
+	for _, tag := range contact.Tags {
+		addText(tag, weightTag)
+	}
+
+	doc := document{name: strings.Join(Tokens(contact.Name), " ")}
+	for term, weight := range terms {
+		postings := ix.terms[term]
+		if postings == nil {
+			postings = make(map[string]float64)
+			ix.terms[term] = postings
+			ix.words.add(term)
+		}
+		postings[contact.ID] = weight
+		doc.terms = append(doc.terms, term)
+	}
+
+	for _, p := range contact.Phones {
+		doc.phones = append(doc.phones, digits(p.Number))
+		// Also keep the national number so "06 12..." finds "+33 6 12...".
+		if number, err := phone.Parse(p.Number, ""); err == nil {
+			doc.phones = append(doc.phones, number.National)
+		}
+	}
+
+	ix.docs[contact.ID] = doc
+}
+
+func (ix *Index) remove(id string) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.terms {
+		delete(ix.terms[term], id)
+		if len(ix.terms[term]) == 0 {
+			delete(ix.terms, term)
+			ix.words.remove(term)
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// matchWord scores every contact containing an indexed word close to word.
+func (ix *Index) matchWord(word string) map[string]float64 {
+	scores := make(map[string]float64)
+	ix.words.candidates(word, func(term string) {
+		score := matchScore(word, term)
+		if score == 0 {
+			return
+		}
+		for id, weight := range ix.terms[term] {
+			scores[id] = max(scores[id], score*weight)
+		}
+	})
+
+	// Digits typed among words, e.g. "alice 0612", may be part of a phone.
+	if number, ok := phoneQuery(word); ok {
+		for id, score := range ix.matchPhone(number) {
+			scores[id] = max(scores[id], score)
+		}
+	}
+
+	return scores
+}
+
+func (ix *Index) matchPhone(number string) map[string]float64 {
+	// National numbers are written with a trunk prefix the index drops.
+	trimmed := strings.TrimLeft(number, "0")
+
+	scores := make(map[string]float64)
+	for id, doc := range ix.docs {
+		for _, stored := range doc.phones {
+			switch {
+			case stored == number || stored == trimmed:
+				scores[id] = max(scores[id], scoreExact*weightPhone)
+			case strings.Contains(stored, number) || (len(trimmed) >= 3 && strings.Contains(stored, trimmed)):
+				scores[id] = max(scores[id], scoreSubstring*weightPhone)
+			}
+		}
+	}
+	return scores
+}
+
+// matchScore rates how well the query word matches an indexed term, 0
+// meaning not at all.
+func matchScore(word, term string) float64 {
+	switch {
+	case word == term:
+		return scoreExact
+	case strings.HasPrefix(term, word):
+		// "jo" says less about "jonathan" than "jonath" does.
+		return scorePrefix + 0.3*float64(len(word))/float64(len(term))
+	case len(word) >= 3 && strings.Contains(term, word):
+		return scoreSubstring
+	}
+
+	allowed := maxTypos(word)
+	if allowed == 0 {
+		return 0
+	}
+	if abs(len(term)-len(word)) <= allowed {
+		if d := Distance(word, term, allowed); d <= allowed {
+			return scoreTypo / float64(d)
+		}
+	}
+	// Typos while still typing the word, e.g. "alxe" for "alexander".
+	if runes, n := []rune(term), len([]rune(word)); len(runes) > n {
+		if d := Distance(word, string(runes[:n]), allowed); d <= allowed {
+			return scoreTypo / float64(d+1)
+		}
+	}
+	return 0
+}
+
+// maxTypos is the number of edits tolerated for a word of that length. Short
+// words would match too many others.
+func maxTypos(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Distance returns the optimal string alignment distance between a and b:
+// insertions, deletions, substitutions and swaps of adjacent letters each
+// count as one edit. It gives up early and returns limit+1 once the distance
+// exceeds limit.
+func Distance(a, b string, limit int) int {
+	s, t := []rune(a), []rune(b)
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		best := curr[0]
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+			best = min(best, curr[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(t)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
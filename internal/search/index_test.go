@@ -0,0 +1,182 @@
+package search
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+func testContacts() []domain.Contact {
+	return []domain.Contact{
+		{ID: "1", Name: "José Álvarez", Organization: "Acme", Phones: []domain.Phone{{Number: "+33612345678"}}},
+		{ID: "2", Name: "Jonathan Smith", JobTitle: "Engineer", Emails: []domain.Email{{Address: "jon@globex.com"}}},
+		{ID: "3", Name: "Alexander Müller", Addresses: []domain.Address{{City: "München", Country: "Germany"}}},
+		{ID: "4", Name: "Jo", Notes: "Met at Acme conference"},
+		{ID: "5", Name: "Ann Lee", Phones: []domain.Phone{{Number: "+14155550100"}}},
+	}
+}
+
+func ids(results []Result) []string {
+	found := make([]string, len(results))
+	for i, result := range results {
+		found[i] = result.ID
+	}
+	return found
+}
+
+func TestFold(t *testing.T) {
+	tests := map[string]string{
+		"José Álvarez":  "jose alvarez",
+		"Zoë Ångström":  "zoe angstrom",
+		"Straße":        "strasse",
+		"Œuvre":         "oeuvre",
+		"Jose\u0301":    "jose",
+		"Plain ASCII 1": "plain ascii 1",
+	}
+
+	for input, want := range tests {
+		if got := Fold(input); got != want {
+			t.Errorf("Expected Fold(%q) to be %q, got %q", input, want, got)
+		}
+	}
+}
+
+func TestIndex_Search(t *testing.T) {
+	ix := New(testContacts())
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"jose", []string{"1"}},
+		{"ALVAREZ", []string{"1"}},
+		{"muller munchen", []string{"3"}},
+		{"jon", []string{"2"}},
+		{"jonahtan", []string{"2"}},
+		{"alxe", []string{"3"}},
+		{"globex", []string{"2"}},
+		{"engineer", []string{"2"}},
+		{"acme", []string{"1", "4"}},
+		{"jo", []string{"4", "1", "2"}},
+		{"0612345678", []string{"1"}},
+		{"+33 6 12 34 56 78", []string{"1"}},
+		{"(415) 555", []string{"5"}},
+		{"ann 0100", []string{"5"}},
+		{"nobody", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := ids(ix.Search(tt.query)); !slices.Equal(got, tt.want) {
+			t.Errorf("Expected %v for %q, got %v", tt.want, tt.query, got)
+		}
+	}
+}
+
+func TestIndex_Ranking(t *testing.T) {
+	ix := New([]domain.Contact{
+		{ID: "notes", Name: "Bob Stone", Notes: "Introduced by Martin"},
+		{ID: "prefix", Name: "Martina Lopez"},
+		{ID: "exact", Name: "Martin"},
+		{ID: "last", Name: "Paul Martin"},
+	})
+
+	want := []string{"exact", "last", "prefix", "notes"}
+	if got := ids(ix.Search("martin")); !slices.Equal(got, want) {
+		t.Errorf("Expected ranking %v, got %v", want, got)
+	}
+}
+
+func TestIndex_Incremental(t *testing.T) {
+	contacts := testContacts()
+	ix := New(contacts)
+
+	updated := contacts[4]
+	updated.Name = "Annabel Lee"
+	updated.Phones = []domain.Phone{{Number: "+442071234567"}}
+	ix.Add(updated)
+
+	if got := ids(ix.Search("annabel")); !slices.Equal(got, []string{"5"}) {
+		t.Errorf("Expected the updated contact to be found, got %v", got)
+	}
+	if got := ix.Search("4155550100"); len(got) != 0 {
+		t.Errorf("Expected the old phone to be forgotten, got %v", ids(got))
+	}
+
+	ix.Remove("1")
+	if got := ix.Search("alvarez"); len(got) != 0 {
+		t.Errorf("Expected the removed contact not to be found, got %v", ids(got))
+	}
+	if got := ids(ix.Search("acme")); !slices.Equal(got, []string{"4"}) {
+		t.Errorf("Expected only the remaining Acme contact, got %v", got)
+	}
+	if ix.Len() != 4 {
+		t.Errorf("Expected 4 indexed contacts, got %d", ix.Len())
+	}
+
+	ix.Add(domain.Contact{ID: "6", Name: "Zoë Martin"})
+	if got := ids(ix.Search("zoe")); !slices.Equal(got, []string{"6"}) {
+		t.Errorf("Expected the added contact to be found, got %v", got)
+	}
+}
+
+func TestIndex_MatchesLikeAScan(t *testing.T) {
+	contacts := append(testContacts(),
+		domain.Contact{ID: "6", Name: "Alexandra Alexis", Notes: "lexicon of alexandrian lex"},
+		domain.Contact{ID: "7", Name: "Jonathon Jhonson", Tags: []string{"jonas", "nathan"}},
+		domain.Contact{ID: "8", Name: "Mika Kim", JobTitle: "Kimono maker"},
+	)
+	ix := New(contacts)
+	ix.Remove("8")
+	ix.Add(domain.Contact{ID: "8", Name: "Mika Kimura"})
+
+	// scan scores word against every indexed term, as the index once did.
+	scan := func(word string) map[string]float64 {
+		scores := make(map[string]float64)
+		for term, postings := range ix.terms {
+			if score := matchScore(word, term); score > 0 {
+				for id, weight := range postings {
+					scores[id] = max(scores[id], score*weight)
+				}
+			}
+		}
+		return scores
+	}
+
+	words := []string{"jo", "jon", "jonh", "jonahtan", "alxe", "alexnader", "lex", "xand", "exi",
+		"kimura", "kimoon", "mika", "mkia", "nathna", "sonj", "ohns", "munchen", "mnuchen", "zzzz"}
+	for term := range ix.terms {
+		words = append(words, term, term[:len(term)/2+1])
+	}
+	for _, word := range words {
+		got, want := ix.matchWord(word), scan(word)
+		if !maps.Equal(got, want) {
+			t.Errorf("Expected %v for %q, got %v", want, word, got)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"john", "john", 0},
+		{"jhon", "john", 1},
+		{"jon", "john", 1},
+		{"smith", "smyth", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b, 5); got != tt.want {
+			t.Errorf("Expected Distance(%q, %q) = %d, got %d", tt.a, tt.b, tt.want, got)
+		}
+	}
+
+	if got := Distance("kitten", "sitting", 1); got != 2 {
+		t.Errorf("Expected distance to stop past the limit, got %d", got)
+	}
+}
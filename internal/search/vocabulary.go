@@ -0,0 +1,160 @@
+package search
+
+// vocabulary finds the indexed words a query word may match without going
+// through all of them: a trie of the words answers exact, prefix and typo
+// lookups, and an index of their three-byte sequences substring lookups.
+type vocabulary struct {
+	root  trieNode
+	grams map[string]map[string]struct{}
+}
+
+// trieNode is a node of the trie of words, keyed by rune.
+type trieNode struct {
+	children map[rune]*trieNode
+	// term is the word ending at this node, empty when none does.
+	term string
+}
+
+func newVocabulary() *vocabulary {
+	return &vocabulary{grams: make(map[string]map[string]struct{})}
+}
+
+func (v *vocabulary) add(term string) {
+	node := &v.root
+	for _, r := range term {
+		child := node.children[r]
+		if child == nil {
+			if node.children == nil {
+				node.children = make(map[rune]*trieNode)
+			}
+			child = &trieNode{}
+			node.children[r] = child
+		}
+		node = child
+	}
+	node.term = term
+
+	for i := 0; i+3 <= len(term); i++ {
+		gram := term[i : i+3]
+		if v.grams[gram] == nil {
+			v.grams[gram] = make(map[string]struct{})
+		}
+		v.grams[gram][term] = struct{}{}
+	}
+}
+
+func (v *vocabulary) remove(term string) {
+	v.root.remove([]rune(term))
+
+	for i := 0; i+3 <= len(term); i++ {
+		gram := term[i : i+3]
+		delete(v.grams[gram], term)
+		if len(v.grams[gram]) == 0 {
+			delete(v.grams, gram)
+		}
+	}
+}
+
+// candidates calls fn with every word matchScore may rate above 0 for word,
+// some of them more than once, and possibly others.
+func (v *vocabulary) candidates(word string, fn func(term string)) {
+	// The word itself and the words it starts.
+	node := &v.root
+	for _, r := range word {
+		if node = node.children[r]; node == nil {
+			break
+		}
+	}
+	if node != nil {
+		node.each(fn)
+	}
+
+	// Words containing it hold its rarest three bytes.
+	if len(word) >= 3 {
+		var rarest map[string]struct{}
+		for i := 0; i+3 <= len(word); i++ {
+			terms := v.grams[word[i:i+3]]
+			if i == 0 || len(terms) < len(rarest) {
+				rarest = terms
+			}
+		}
+		for term := range rarest {
+			fn(term)
+		}
+	}
+
+	// Words within the typos allowed, whole or cut to the length of word.
+	if allowed := maxTypos(word); allowed > 0 {
+		runes := []rune(word)
+		first := make([]int, len(runes)+1)
+		for j := range first {
+			first[j] = j
+		}
+		for r, child := range v.root.children {
+			child.near(runes, allowed, 1, 0, r, nil, first, fn)
+		}
+	}
+}
+
+// remove drops the word spelled by runes below n, and reports whether n is
+// left without words.
+func (n *trieNode) remove(runes []rune) bool {
+	if len(runes) == 0 {
+		n.term = ""
+	} else if child := n.children[runes[0]]; child != nil && child.remove(runes[1:]) {
+		delete(n.children, runes[0])
+	}
+	return n.term == "" && len(n.children) == 0
+}
+
+// each calls fn with every word ending at n or below it.
+func (n *trieNode) each(fn func(term string)) {
+	if n.term != "" {
+		fn(n.term)
+	}
+	for _, child := range n.children {
+		child.each(fn)
+	}
+}
+
+// near calls fn with the words below n within allowed edits of word, and
+// with the longer ones whose first len(word) runes are. n is at the given
+// depth, reached with r after last; prev and prev2 are the rows of Distance
+// for its parent and grandparent. Branches are left as soon as every
+// alignment needs more edits than allowed, as Distance gives up.
+func (n *trieNode) near(word []rune, allowed, depth int, last, r rune, prev2, prev []int, fn func(term string)) {
+	curr := make([]int, len(word)+1)
+	curr[0] = depth
+	best := curr[0]
+	for j := 1; j <= len(word); j++ {
+		cost := 1
+		if word[j-1] == r {
+			cost = 0
+		}
+		curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		if depth > 1 && j > 1 && word[j-2] == r && word[j-1] == last {
+			curr[j] = min(curr[j], prev2[j-2]+1)
+		}
+		best = min(best, curr[j])
+	}
+	if best > allowed {
+		return
+	}
+
+	if n.term != "" && curr[len(word)] <= allowed {
+		fn(n.term)
+	}
+	if depth == len(word) && curr[len(word)] <= allowed {
+		// Typos while still typing: every longer word is a candidate.
+		for _, child := range n.children {
+			child.each(fn)
+		}
+		return
+	}
+	if depth >= len(word)+allowed {
+		return
+	}
+	for next, child := range n.children {
+		child.near(word, allowed, depth+1, r, next, prev, curr, fn)
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+// errAuditUnsupported is returned when reading the history of a storage
+// that keeps no audit log.
+var errAuditUnsupported = fmt.Errorf("this storage cannot keep an audit log: %w", errors.ErrUnsupported)
+
+// SetActor sets who the changes made from now on are recorded as made by in
+// the audit log, e.g. a user name.
+func (d *Directory) SetActor(actor string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.actor = strings.TrimSpace(actor)
+}
+
+// History returns the changes recorded for a contact, oldest first. ref is
+// the ID of the contact or one of the names it had, so deleted and renamed
+// contacts can be looked up too; every contact that had that name is
+// included.
+func (d *Directory) History(ref string) ([]domain.AuditEntry, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, invalidField("id", "contact id or name is required", nil)
+	}
+	if d.auditStore == nil {
+		return nil, errAuditUnsupported
+	}
+
+	entries, err := d.auditStore.LoadAudit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit log: %w", err)
+	}
+
+	ids := map[string]bool{ref: true}
+	for _, entry := range entries {
+		for _, contact := range []*domain.Contact{entry.Before, entry.After} {
+			if contact != nil && strings.EqualFold(contact.Name, ref) {
+				ids[entry.ContactID] = true
+			}
+		}
+	}
+
+	var history []domain.AuditEntry
+	for _, entry := range entries {
+		if ids[entry.ContactID] {
+			history = append(history, entry)
+		}
+	}
+	if len(history) == 0 {
+		return nil, newError(ErrNotFound, "no history found for contact '%s'", ref)
+	}
+	return history, nil
+}
+
+// auditEntries records the changes that make contacts the directory's
+// contacts, for the audit log and the subscribers. Changes to when a contact
+// was last looked up are not recorded, and a contact leaving the trash is
+// recorded as restored. Callers must hold the write lock.
+func (d *Directory) auditEntries(contacts []domain.Contact) []domain.AuditEntry {
+	if d.auditStore == nil && len(d.subscribers) == 0 {
+		return nil
+	}
+
+	now := d.now().UTC()
+	entry := func(action domain.AuditAction, id string, before, after *domain.Contact) domain.AuditEntry {
+		return domain.AuditEntry{Time: now, Actor: d.actor, Action: action, ContactID: id, Before: before, After: after}
+	}
+
+	previous := make(map[string]domain.Contact, len(d.contacts))
+	for _, contact := range d.contacts {
+		previous[contact.ID] = contact
+	}
+
+	var entries []domain.AuditEntry
+	for _, contact := range contacts {
+		before, ok := previous[contact.ID]
+		delete(previous, contact.ID)
+
+		switch {
+		case !ok:
+			action := domain.AuditCreate
+			if slices.ContainsFunc(d.trash, func(c domain.Contact) bool { return c.ID == contact.ID }) {
+				action = domain.AuditRestore
+			}
+			after := contact.Clone()
+			entries = append(entries, entry(action, contact.ID, nil, &after))
+		case !reflect.DeepEqual(before, contact) && len(domain.CompareContacts(before, contact)) > 0:
+			before, after := before.Clone(), contact.Clone()
+			entries = append(entries, entry(domain.AuditUpdate, contact.ID, &before, &after))
+		}
+	}
+	for _, contact := range d.contacts {
+		if _, deleted := previous[contact.ID]; deleted {
+			before := contact.Clone()
+			entries = append(entries, entry(domain.AuditDelete, contact.ID, &before, nil))
+		}
+	}
+	return entries
+}
+
+// purgeEntries records the purge of contacts from the trash.
+func (d *Directory) purgeEntries(purged []domain.Contact) []domain.AuditEntry {
+	if d.auditStore == nil {
+		return nil
+	}
+
+	now := d.now().UTC()
+	entries := make([]domain.AuditEntry, len(purged))
+	for i, contact := range purged {
+		before := contact.Clone()
+		entries[i] = domain.AuditEntry{Time: now, Actor: d.actor, Action: domain.AuditPurge, ContactID: contact.ID, Before: &before}
+	}
+	return entries
+}
+
+// appendAudit stores entries once the change they record is stored. The
+// change cannot be undone by then, so a failure is only logged.
+func (d *Directory) appendAudit(entries []domain.AuditEntry) {
+	if len(entries) == 0 || d.auditStore == nil {
+		return
+	}
+	if err := d.auditStore.AppendAudit(entries); err != nil {
+		log.Printf("warning: %d change(s) were stored but not recorded in the audit log: %v", len(entries), err)
+	}
+}
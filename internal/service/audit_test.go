@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+type auditStorage struct {
+	mockStorage
+	entries []domain.AuditEntry
+}
+
+func (a *auditStorage) AppendAudit(entries []domain.AuditEntry) error {
+	a.entries = append(a.entries, entries...)
+	return nil
+}
+
+func (a *auditStorage) LoadAudit() ([]domain.AuditEntry, error) {
+	return a.entries, nil
+}
+
+func auditActions(entries []domain.AuditEntry) []string {
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, string(entry.Action)+" "+entry.Name())
+	}
+	return actions
+}
+
+func TestAuditLog(t *testing.T) {
+	store := &auditStorage{}
+	dir, _ := NewDirectory(store)
+	dir.SetActor("alice")
+
+	john, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	jane, _ := dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101"))
+
+	dir.SetActor("bob")
+	updated := john
+	updated.Name = "Johnny Doe"
+	updated.Organization = "Acme"
+	if err := dir.UpdateContact(updated); err != nil {
+		t.Fatalf("Failed to update contact: %v", err)
+	}
+	// Neither a lookup nor an update changing nothing is a change.
+	dir.SearchContact("johnny")
+	if err := dir.UpdateContact(updated); err != nil {
+		t.Fatalf("Failed to update contact: %v", err)
+	}
+	if err := dir.DeleteContacts(john.ID, jane.ID); err != nil {
+		t.Fatalf("Failed to delete contacts: %v", err)
+	}
+
+	want := []string{"create John Doe", "create Jane Doe", "update Johnny Doe", "delete Johnny Doe", "delete Jane Doe"}
+	if got := auditActions(store.entries); !slices.Equal(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+
+	edit := store.entries[2]
+	if edit.Actor != "bob" || store.entries[0].Actor != "alice" || edit.Time.IsZero() {
+		t.Errorf("Expected who made the change and when, got %+v", edit)
+	}
+	if edit.Before == nil || edit.Before.Name != "John Doe" || edit.After == nil || edit.After.Organization != "Acme" {
+		t.Errorf("Expected the contact before and after the edit, got %+v", edit)
+	}
+	changes := edit.Changes()
+	if len(changes) != 2 || changes[0] != (domain.FieldChange{Field: "name", Before: "John Doe", After: "Johnny Doe"}) ||
+		changes[1] != (domain.FieldChange{Field: "organization", After: "Acme"}) {
+		t.Errorf("Expected the name and organization to change, got %+v", changes)
+	}
+	if store.entries[3].Before == nil || store.entries[3].After != nil {
+		t.Errorf("Expected a deletion to keep the deleted contact, got %+v", store.entries[3])
+	}
+}
+
+func TestHistory(t *testing.T) {
+	store := &auditStorage{}
+	dir, _ := NewDirectory(store)
+
+	john, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101"))
+	renamed := john
+	renamed.Name = "Johnny Doe"
+	dir.UpdateContact(renamed)
+	dir.DeleteContact(john.ID)
+
+	want := []string{"create John Doe", "update Johnny Doe", "delete Johnny Doe"}
+	for _, ref := range []string{john.ID, "john doe", "Johnny Doe"} {
+		history, err := dir.History(ref)
+		if err != nil {
+			t.Fatalf("Failed to read the history of %s: %v", ref, err)
+		}
+		if got := auditActions(history); !slices.Equal(got, want) {
+			t.Errorf("Expected the history of %s to be %v, got %v", ref, want, got)
+		}
+	}
+
+	if _, err := dir.History("nobody"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := dir.History(" "); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrValidation, got %v", err)
+	}
+
+	unsupported, _ := NewDirectory(newMockStorage())
+	if _, err := unsupported.History("John Doe"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestAuditLog_FailedWrite(t *testing.T) {
+	store := &failingAuditStorage{}
+	dir, _ := NewDirectory(store)
+
+	if _, err := dir.CreateContact(domain.NewContact("John Doe", "+12025550100")); err == nil {
+		t.Fatal("Expected an error when the storage fails")
+	}
+	if len(store.entries) != 0 {
+		t.Errorf("Expected a change that was not stored to stay out of the audit log, got %v", auditActions(store.entries))
+	}
+}
+
+type failingAuditStorage struct {
+	auditStorage
+}
+
+func (f *failingAuditStorage) Save(contacts []domain.Contact) error {
+	return errors.New("disk full")
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+// Tx stages changes to the contacts of a directory, applied together when
+// the Batch that opened it returns. It must not be used after that.
+type Tx struct {
+	d        *Directory
+	region   string
+	contacts []domain.Contact
+	trash    []domain.Contact
+	// touched lists the IDs of the contacts created, updated or deleted, in
+	// the order they were first changed.
+	touched []string
+}
+
+// Batch runs fn with a transaction and stores every change it staged in a
+// single storage write. Nothing is stored when fn returns an error or when
+// the write fails, and the directory is left as it was. fn must not call
+// other methods of the directory, whose lock the batch holds.
+func (d *Directory) Batch(fn func(tx *Tx) error) error {
+	region := d.defaultRegion()
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tx := &Tx{d: d, region: region, contacts: cloneContacts(d.contacts), trash: slices.Clone(d.trash)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.touched) == 0 {
+		return nil
+	}
+
+	err = d.commit(tx.contacts, func(ctx context.Context) error {
+		return d.storage.Save(slices.Concat(tx.contacts, tx.trash))
+	})
+	if err != nil {
+		return err
+	}
+	d.trash = tx.trash
+	for _, id := range tx.touched {
+		if i := tx.indexOf(id); i >= 0 {
+			d.index.Add(tx.contacts[i])
+		} else {
+			d.index.Remove(id)
+		}
+	}
+	return nil
+}
+
+// Get returns the contact with the given ID as staged so far.
+func (tx *Tx) Get(id string) (domain.Contact, error) {
+	id = strings.TrimSpace(id)
+	i := tx.indexOf(id)
+	if i < 0 {
+		return domain.Contact{}, newError(ErrNotFound, "contact with id '%s' not found", id)
+	}
+	return tx.contacts[i].Clone(), nil
+}
+
+// Contacts returns every contact as staged so far.
+func (tx *Tx) Contacts() []domain.Contact {
+	return cloneContacts(tx.contacts)
+}
+
+// Create stages a new contact as CreateContact would, and returns it as it
+// will be stored.
+func (tx *Tx) Create(contact domain.Contact) (domain.Contact, error) {
+	contact, err := tx.prepare(contact)
+	if err != nil {
+		return domain.Contact{}, err
+	}
+	for _, existing := range tx.contacts {
+		if strings.EqualFold(existing.Name, contact.Name) && existing.PrimaryPhone() == contact.PrimaryPhone() {
+			return domain.Contact{}, newError(ErrAlreadyExists, "contact '%s' with phone '%s' already exists", contact.Name, contact.PrimaryPhone())
+		}
+	}
+	contact.ID = domain.NewID()
+	contact.CreatedAt = tx.d.now().UTC()
+	contact.LastAccessedAt = time.Time{}
+	contact.DeletedAt = time.Time{}
+
+	tx.put(contact)
+	return contact.Clone(), nil
+}
+
+// Update stages the replacement of every field of the contact with the same
+// ID, as UpdateContact would.
+func (tx *Tx) Update(contact domain.Contact) error {
+	contact, err := tx.prepare(contact)
+	if err != nil {
+		return err
+	}
+	i := tx.indexOf(contact.ID)
+	if i < 0 {
+		return newError(ErrNotFound, "contact with id '%s' not found", contact.ID)
+	}
+	contact.CreatedAt = tx.contacts[i].CreatedAt
+	contact.LastAccessedAt = tx.contacts[i].LastAccessedAt
+	contact.DeletedAt = tx.contacts[i].DeletedAt
+
+	tx.put(contact)
+	return nil
+}
+
+// Delete stages moving the contact with the given ID to the trash.
+func (tx *Tx) Delete(id string) error {
+	id = strings.TrimSpace(id)
+	i := tx.indexOf(id)
+	if i < 0 {
+		return newError(ErrNotFound, "contact with id '%s' not found", id)
+	}
+
+	deleted := tx.contacts[i]
+	deleted.DeletedAt = tx.d.now().UTC()
+	tx.trash = append(tx.trash, deleted)
+	tx.contacts = slices.Delete(tx.contacts, i, i+1)
+	tx.touch(id)
+	return nil
+}
+
+// prepare is prepareContact without the directory lock, which the batch
+// holds, plus the check of the groups.
+func (tx *Tx) prepare(contact domain.Contact) (domain.Contact, error) {
+	contact = normalizeContact(contact)
+
+	errs := validateContact(contact)
+	errs = append(errs, normalizePhones(&contact, tx.region)...)
+	if len(errs) > 0 {
+		return domain.Contact{}, &ValidationError{Fields: errs}
+	}
+	if err := tx.d.checkGroups(contact); err != nil {
+		return domain.Contact{}, err
+	}
+	return contact, nil
+}
+
+// put stages contact as it is, replacing the contact with its ID or adding
+// it. The caller has validated it.
+func (tx *Tx) put(contact domain.Contact) {
+	if i := tx.indexOf(contact.ID); i >= 0 {
+		tx.contacts[i] = contact
+	} else {
+		tx.contacts = append(tx.contacts, contact)
+	}
+	tx.touch(contact.ID)
+}
+
+func (tx *Tx) indexOf(id string) int {
+	return slices.IndexFunc(tx.contacts, func(c domain.Contact) bool { return c.ID == id })
+}
+
+func (tx *Tx) touch(id string) {
+	if !slices.Contains(tx.touched, id) {
+		tx.touched = append(tx.touched, id)
+	}
+}
+
+// DeleteContacts moves every contact listed to the trash, or none of them
+// when one is not found.
+func (d *Directory) DeleteContacts(ids ...string) error {
+	return d.Batch(func(tx *Tx) error {
+		for _, id := range ids {
+			if err := tx.Delete(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+func TestBatch(t *testing.T) {
+	store := &recordStorage{}
+	dir, _ := NewDirectory(store)
+	alice, _ := dir.CreateContact(domain.NewContact("Alice Martin", "+33612345678"))
+	bob, _ := dir.CreateContact(domain.NewContact("Bob Stone", "+14155550100"))
+	store.calls = nil
+
+	var carol domain.Contact
+	err := dir.Batch(func(tx *Tx) error {
+		var err error
+		if carol, err = tx.Create(domain.NewContact("Carol White", "+33 6 11 22 33 44")); err != nil {
+			return err
+		}
+		if _, err := tx.Create(domain.NewContact("carol white", "+33611223344")); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("Expected staged contacts to be found as duplicates, got %v", err)
+		}
+
+		staged, err := tx.Get(alice.ID)
+		if err != nil {
+			return err
+		}
+		staged.Organization = "Acme"
+		if err := tx.Update(staged); err != nil {
+			return err
+		}
+		return tx.Delete(bob.ID)
+	})
+	if err != nil {
+		t.Fatalf("Failed to run the batch: %v", err)
+	}
+
+	if !slices.Equal(store.calls, []string{"save"}) {
+		t.Errorf("Expected a single storage write, got %v", store.calls)
+	}
+	if got := contactNames(dir.ListContacts()); !slices.Equal(got, []string{"Alice Martin", "Carol White"}) {
+		t.Errorf("Expected Alice and Carol, got %v", got)
+	}
+	if carol.ID == "" || carol.PrimaryPhone() != "+33611223344" {
+		t.Errorf("Expected Carol to get an ID and a normalized phone, got %+v", carol)
+	}
+	if stored, _ := dir.GetContact(alice.ID); stored.Organization != "Acme" || !stored.CreatedAt.Equal(alice.CreatedAt) {
+		t.Errorf("Expected Alice updated with her creation time, got %+v", stored)
+	}
+	if got := contactNames(dir.SearchContacts("acme")); !slices.Equal(got, []string{"Alice Martin"}) {
+		t.Errorf("Expected the index to hold the update, got %v", got)
+	}
+	if matches := dir.SearchContacts("bob"); len(matches) != 0 {
+		t.Errorf("Expected the index to drop Bob, got %v", contactNames(matches))
+	}
+}
+
+func TestBatch_Rollback(t *testing.T) {
+	seeded := []domain.Contact{{ID: "alice", Name: "Alice Martin", Phones: []domain.Phone{{Number: "+33612345678"}}}}
+
+	t.Run("Error", func(t *testing.T) {
+		store := &recordStorage{mockStorage: mockStorage{contacts: cloneContacts(seeded)}}
+		dir, _ := NewDirectory(store)
+
+		failure := errors.New("stop")
+		err := dir.Batch(func(tx *Tx) error {
+			tx.Create(domain.NewContact("Bob Stone", "+14155550100"))
+			tx.Delete("alice")
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Errorf("Expected the batch to return the error of fn, got %v", err)
+		}
+		if len(store.calls) != 0 {
+			t.Errorf("Expected nothing to be written, got %v", store.calls)
+		}
+		if got := contactNames(dir.ListContacts()); !slices.Equal(got, []string{"Alice Martin"}) {
+			t.Errorf("Expected the contacts to be left as they were, got %v", got)
+		}
+	})
+
+	t.Run("FailedWrite", func(t *testing.T) {
+		dir, _ := NewDirectory(&failingStorage{mockStorage{contacts: cloneContacts(seeded)}})
+
+		err := dir.Batch(func(tx *Tx) error {
+			if _, err := tx.Create(domain.NewContact("Bob Stone", "+14155550100")); err != nil {
+				return err
+			}
+			return tx.Delete("alice")
+		})
+		if err == nil {
+			t.Fatal("Expected an error when the storage fails")
+		}
+		if got := contactNames(dir.ListContacts()); !slices.Equal(got, []string{"Alice Martin"}) {
+			t.Errorf("Expected the contacts to be left as they were, got %v", got)
+		}
+		if got := contactNames(dir.SearchContacts("alice")); !slices.Equal(got, []string{"Alice Martin"}) {
+			t.Errorf("Expected the index to be left as it was, got %v", got)
+		}
+		if matches := dir.SearchContacts("bob"); len(matches) != 0 {
+			t.Errorf("Expected the index to be left as it was, got %v", contactNames(matches))
+		}
+	})
+}
+
+func TestDeleteContacts(t *testing.T) {
+	store := &recordStorage{}
+	dir, _ := NewDirectory(store)
+	alice, _ := dir.CreateContact(domain.NewContact("Alice Martin", "+33612345678"))
+	bob, _ := dir.CreateContact(domain.NewContact("Bob Stone", "+14155550100"))
+	carol, _ := dir.CreateContact(domain.NewContact("Carol White", "+33611223344"))
+	store.calls = nil
+
+	if err := dir.DeleteContacts(alice.ID, "missing", bob.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if len(dir.ListContacts()) != 3 || len(store.calls) != 0 {
+		t.Errorf("Expected nothing to be deleted when an ID is missing, got %v", contactNames(dir.ListContacts()))
+	}
+
+	if err := dir.DeleteContacts(alice.ID, bob.ID); err != nil {
+		t.Fatalf("Failed to delete contacts: %v", err)
+	}
+	if got := contactNames(dir.ListContacts()); !slices.Equal(got, []string{carol.Name}) {
+		t.Errorf("Expected only Carol left, got %v", got)
+	}
+	if !slices.Equal(store.calls, []string{"save"}) {
+		t.Errorf("Expected a single storage write, got %v", store.calls)
+	}
+}
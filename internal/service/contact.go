@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/phone"
+)
+
+const birthdayLayout = "2006-01-02"
+
+// normalizeContact trims every field, drops empty phones, emails and
+// addresses, and fills in the display name from its parts or the parts from
+// the display name.
+func normalizeContact(contact domain.Contact) domain.Contact {
+	contact = contact.Clone()
+
+	contact.Name = strings.Join(strings.Fields(contact.Name), " ")
+	contact.FirstName = strings.TrimSpace(contact.FirstName)
+	contact.LastName = strings.TrimSpace(contact.LastName)
+	contact.Organization = strings.TrimSpace(contact.Organization)
+	contact.JobTitle = strings.TrimSpace(contact.JobTitle)
+	contact.Birthday = strings.TrimSpace(contact.Birthday)
+	contact.Notes = strings.TrimSpace(contact.Notes)
+
+	switch {
+	case contact.Name == "":
+		contact.Name = domain.JoinName(contact.FirstName, contact.LastName)
+	case contact.FirstName == "" && contact.LastName == "":
+		contact.FirstName, contact.LastName = domain.SplitName(contact.Name)
+	}
+
+	phones := contact.Phones[:0]
+	for _, phone := range contact.Phones {
+		phone.Number = strings.TrimSpace(phone.Number)
+		if phone.Number == "" {
+			continue
+		}
+		if phone.Type == "" {
+			phone.Type = domain.PhoneMobile
+		}
+		phones = append(phones, phone)
+	}
+	contact.Phones = phones
+
+	emails := contact.Emails[:0]
+	for _, email := range contact.Emails {
+		email.Type = strings.TrimSpace(email.Type)
+		email.Address = strings.TrimSpace(email.Address)
+		if email.Address != "" {
+			emails = append(emails, email)
+		}
+	}
+	contact.Emails = emails
+
+	addresses := contact.Addresses[:0]
+	for _, address := range contact.Addresses {
+		address.Type = strings.TrimSpace(address.Type)
+		address.Street = strings.TrimSpace(address.Street)
+		address.City = strings.TrimSpace(address.City)
+		address.Region = strings.TrimSpace(address.Region)
+		address.PostalCode = strings.TrimSpace(address.PostalCode)
+		address.Country = strings.TrimSpace(address.Country)
+		if address != (domain.Address{Type: address.Type}) {
+			addresses = append(addresses, address)
+		}
+	}
+	contact.Addresses = addresses
+
+	contact.Tags = normalizeTags(contact.Tags)
+
+	groups := contact.Groups[:0]
+	for _, id := range contact.Groups {
+		id = strings.TrimSpace(id)
+		if id != "" && !slices.Contains(groups, id) {
+			groups = append(groups, id)
+		}
+	}
+	contact.Groups = groups
+
+	return contact
+}
+
+// normalizeTags collapses the spaces in every tag and drops empty tags and
+// tags repeated with another case.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(tag), " ")
+		if tag != "" && !slices.ContainsFunc(normalized, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// validateContact returns the problems found in a normalized contact.
+func validateContact(contact domain.Contact) []FieldError {
+	var errs []FieldError
+
+	if contact.Name == "" {
+		errs = append(errs, FieldError{Field: "name", Message: "contact name is required"})
+	}
+
+	if contact.Birthday != "" {
+		if _, err := time.Parse(birthdayLayout, contact.Birthday); err != nil {
+			errs = append(errs, FieldError{Field: "birthday", Message: "birthday must use the YYYY-MM-DD format", Err: err})
+		}
+	}
+
+	for i, tag := range contact.Tags {
+		if strings.Contains(tag, ",") {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("tags[%d]", i), Message: "tags cannot contain commas"})
+		}
+	}
+
+	for i, phone := range contact.Phones {
+		switch phone.Type {
+		case domain.PhoneMobile, domain.PhoneWork, domain.PhoneHome, domain.PhoneOther:
+		default:
+			errs = append(errs, FieldError{
+				Field:   fmt.Sprintf("phones[%d].type", i),
+				Message: "phone type must be mobile, work, home or other",
+			})
+		}
+	}
+
+	return errs
+}
+
+// normalizePhones rewrites every phone number to E.164 and fills in its
+// display form. National numbers are read in region.
+func normalizePhones(contact *domain.Contact, region string) []FieldError {
+	var errs []FieldError
+	for i, p := range contact.Phones {
+		canonical, display, err := phone.Normalize(p.Number, region)
+		if err != nil {
+			errs = append(errs, phoneFieldError(i, p.Type, err))
+			continue
+		}
+		contact.Phones[i].Number = canonical
+		contact.Phones[i].Display = display
+	}
+	return errs
+}
+
+func phoneFieldError(i int, phoneType domain.PhoneType, err error) FieldError {
+	return FieldError{
+		Field:   fmt.Sprintf("phones[%d].number", i),
+		Message: fmt.Sprintf("invalid %s phone: %v", phoneType, err),
+		Err:     err,
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/phone"
+)
+
+func TestCreateContact(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+	if err := dir.SetDefaultRegion("fr"); err != nil {
+		t.Fatalf("Failed to set region: %v", err)
+	}
+
+	created, err := dir.CreateContact(domain.Contact{
+		FirstName:    " Ada ",
+		LastName:     "Lovelace",
+		Organization: "Analytical Engines Ltd",
+		Phones: []domain.Phone{
+			{Number: " 0611223344 "},
+			{Type: domain.PhoneWork, Number: ""},
+			{Type: domain.PhoneWork, Number: "0144556677"},
+		},
+		Emails:    []domain.Email{{Type: "work", Address: "ada@engines.example"}, {Address: "  "}},
+		Addresses: []domain.Address{{Type: "home"}, {Type: "work", City: "London"}},
+		Birthday:  "1815-12-10",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error creating contact, got %v", err)
+	}
+
+	if created.ID == "" {
+		t.Error("Expected created contact to have an ID")
+	}
+
+	if created.Name != "Ada Lovelace" {
+		t.Errorf("Expected name 'Ada Lovelace', got '%s'", created.Name)
+	}
+
+	expectedPhones := []domain.Phone{
+		{Type: domain.PhoneMobile, Number: "+33611223344", Display: "+33 6 11 22 33 44"},
+		{Type: domain.PhoneWork, Number: "+33144556677", Display: "+33 1 44 55 66 77"},
+	}
+	if !reflect.DeepEqual(created.Phones, expectedPhones) {
+		t.Errorf("Expected phones %v, got %v", expectedPhones, created.Phones)
+	}
+
+	if len(created.Emails) != 1 || len(created.Addresses) != 1 {
+		t.Errorf("Expected empty emails and addresses to be dropped, got %v and %v", created.Emails, created.Addresses)
+	}
+
+	if !reflect.DeepEqual(dir.contacts[0], created) {
+		t.Errorf("Expected stored contact %v, got %v", created, dir.contacts[0])
+	}
+}
+
+func TestCreateContact_Invalid(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+
+	invalid := []domain.Contact{
+		{Name: "   "},
+		{Name: "John Doe", Birthday: "10/12/1815"},
+		{Name: "John Doe", Phones: []domain.Phone{{Type: "pager", Number: "1"}}},
+		{Name: "John Doe", Phones: []domain.Phone{{Number: "abc"}}},
+		{Name: "John Doe", Phones: []domain.Phone{{Number: "555 1234"}}},
+	}
+
+	for _, contact := range invalid {
+		if _, err := dir.CreateContact(contact); err == nil {
+			t.Errorf("Expected error creating %+v", contact)
+		}
+	}
+
+	if len(dir.contacts) != 0 {
+		t.Errorf("Expected no contacts, got %d", len(dir.contacts))
+	}
+}
+
+func TestUpdateContact(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+
+	created, err := dir.CreateContact(domain.NewContact("John Doe", "1234567890"))
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+
+	created.JobTitle = "Engineer"
+	created.Emails = []domain.Email{{Type: "work", Address: "john@example.com"}}
+	if err := dir.UpdateContact(created); err != nil {
+		t.Fatalf("Expected no error updating contact, got %v", err)
+	}
+
+	contact, _ := dir.GetContact(created.ID)
+	if contact.JobTitle != "Engineer" || contact.PrimaryEmail() != "john@example.com" {
+		t.Errorf("Expected updated contact, got %+v", contact)
+	}
+
+	if err := dir.UpdateContact(domain.NewContact("Non Existent", "1234567890")); err == nil {
+		t.Error("Expected error when updating non-existent contact")
+	}
+}
+
+func TestCreateContact_PhoneErrors(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+
+	_, err := dir.CreateContact(domain.NewContact("John Doe", "06 12"))
+
+	var parseErr *phone.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected a *phone.ParseError, got %v", err)
+	}
+	if !errors.Is(err, phone.ErrTooShort) {
+		t.Errorf("Expected ErrTooShort, got %v", err)
+	}
+
+	if err := dir.AddContact("John Doe", "   "); !errors.Is(err, phone.ErrEmpty) {
+		t.Errorf("Expected ErrEmpty adding a blank phone, got %v", err)
+	}
+
+	if err := dir.SetDefaultRegion("XX"); err == nil {
+		t.Error("Expected error setting an unknown region")
+	}
+}
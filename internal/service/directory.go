@@ -1,102 +1,570 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/phone"
+	"github.com/LaulauChau/go-directory/internal/search"
 	"github.com/LaulauChau/go-directory/internal/storage"
 )
 
 type Directory struct {
+	mu       sync.RWMutex
 	storage  storage.Storage
+	records  storage.RecordStore
 	contacts []domain.Contact
+	index    *search.Index
+	version  string
+	region   string
+	now      func() time.Time
+
+	// trash holds the deleted contacts, stored with the others until they
+	// are purged after retention, or never when it is zero.
+	trash     []domain.Contact
+	retention time.Duration
+
+	// auditStore is nil when the storage keeps no audit log. actor is
+	// recorded as the author of the changes.
+	auditStore storage.AuditStore
+	actor      string
+
+	// groupStore is nil when the storage cannot keep groups.
+	groupStore storage.GroupStore
+	groups     []domain.Group
+
+	// snapshotStore is nil when the storage cannot keep snapshots.
+	snapshotStore storage.SnapshotStore
+
+	// subscribers are told about every change once it is stored. events
+	// queues the changes to tell them about until the write lock is
+	// released; delivering lets one caller at a time send them, in order.
+	subscribers    []subscriber
+	lastSubscriber int
+	eventsMu       sync.Mutex
+	events         []queuedEvent
+	delivering     sync.Mutex
+
+	// accessed holds the lookups noted in memory and not saved yet, by
+	// contact ID. flushed is closed once flushAccess saved them all; it is
+	// nil when no flush runs.
+	accessMu sync.Mutex
+	accessed map[string]time.Time
+	flushed  chan struct{}
 }
 
-func NewDirectory(storage storage.Storage) (*Directory, error) {
+func NewDirectory(store storage.Storage) (*Directory, error) {
 	dir := &Directory{
-		storage: storage,
+		storage:   store,
+		records:   storage.Records(store),
+		region:    phone.DefaultRegion,
+		now:       time.Now,
+		retention: DefaultTrashRetention,
 	}
+	dir.groupStore, _ = store.(storage.GroupStore)
+	dir.auditStore, _ = store.(storage.AuditStore)
+	dir.snapshotStore, _ = store.(storage.SnapshotStore)
 
-	contacts, err := storage.Load()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load contacts: %w", err)
+	if err := dir.reload(false); err != nil {
+		return nil, err
 	}
 
-	dir.contacts = contacts
 	return dir, nil
 }
 
-func (d *Directory) AddContact(name, phone string) error {
-	name = strings.TrimSpace(name)
-	phone = strings.TrimSpace(phone)
+// SetDefaultRegion sets the region used to read phone numbers written
+// without a country code.
+func (d *Directory) SetDefaultRegion(region string) error {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if !phone.KnownRegion(region) {
+		return invalidField("region", fmt.Sprintf("unknown phone region '%s'", region), nil)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.region = region
+	return nil
+}
+
+func (d *Directory) AddContact(name, number string) error {
+	if strings.TrimSpace(number) == "" {
+		err := &phone.ParseError{Input: number, Err: phone.ErrEmpty}
+		return &ValidationError{Fields: []FieldError{phoneFieldError(0, domain.PhoneMobile, err)}}
+	}
+
+	_, err := d.CreateContact(domain.NewContact(name, number))
+	return err
+}
+
+// CreateContact stores a new contact with a freshly generated ID and returns
+// it as stored.
+func (d *Directory) CreateContact(contact domain.Contact) (domain.Contact, error) {
+	contact, err := d.prepareContact(contact)
+	if err != nil {
+		return domain.Contact{}, err
+	}
+	contact.ID = domain.NewID()
+	contact.CreatedAt = d.now().UTC()
+	contact.LastAccessedAt = time.Time{}
+	contact.DeletedAt = time.Time{}
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return domain.Contact{}, err
+	}
+	defer unlock()
+
+	if err := d.checkGroups(contact); err != nil {
+		return domain.Contact{}, err
+	}
+	if d.contactExists(contact.Name, contact.PrimaryPhone()) {
+		return domain.Contact{}, newError(ErrAlreadyExists, "contact '%s' with phone '%s' already exists", contact.Name, contact.PrimaryPhone())
+	}
+
+	updated := append(cloneContacts(d.contacts), contact)
+	err = d.commit(updated, func(ctx context.Context) error {
+		return d.records.Insert(ctx, contact)
+	})
+	if err != nil {
+		return domain.Contact{}, err
+	}
+	d.index.Add(contact)
+
+	return contact.Clone(), nil
+}
+
+// UpdateContact replaces every field of the contact with the same ID.
+func (d *Directory) UpdateContact(contact domain.Contact) error {
+	contact, err := d.prepareContact(contact)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := d.indexOf(contact.ID)
+	if i < 0 {
+		return newError(ErrNotFound, "contact with id '%s' not found", contact.ID)
+	}
+	if err := d.checkGroups(contact); err != nil {
+		return err
+	}
+	contact.CreatedAt = d.contacts[i].CreatedAt
+	contact.LastAccessedAt = d.contacts[i].LastAccessedAt
+	contact.DeletedAt = d.contacts[i].DeletedAt
+
+	updated := cloneContacts(d.contacts)
+	updated[i] = contact
+	err = d.commit(updated, func(ctx context.Context) error {
+		return d.records.Update(ctx, contact)
+	})
+	if err != nil {
+		return err
+	}
+	d.index.Add(contact)
+
+	return nil
+}
 
-	if d.contactExists(name) {
-		return fmt.Errorf("contact with name '%s' already exists", name)
+// DeleteContact moves the contact to the trash, from which RestoreContact
+// brings it back.
+func (d *Directory) DeleteContact(id string) error {
+	id = strings.TrimSpace(id)
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := d.indexOf(id)
+	if i < 0 {
+		return newError(ErrNotFound, "contact with id '%s' not found", id)
+	}
+
+	deleted := d.contacts[i].Clone()
+	deleted.DeletedAt = d.now().UTC()
+
+	updated := cloneContacts(d.contacts)
+	updated = append(updated[:i], updated[i+1:]...)
+	err = d.commit(updated, func(ctx context.Context) error {
+		return d.records.Update(ctx, deleted)
+	})
+	if err != nil {
+		return err
+	}
+	d.trash = append(d.trash, deleted)
+	d.index.Remove(id)
+
+	return nil
+}
+
+func (d *Directory) EditContact(id, newPhone string) error {
+	id = strings.TrimSpace(id)
+
+	number, err := phone.Parse(newPhone, d.defaultRegion())
+	if err != nil {
+		return &ValidationError{Fields: []FieldError{phoneFieldError(0, domain.PhoneMobile, err)}}
+	}
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := d.indexOf(id)
+	if i < 0 {
+		return newError(ErrNotFound, "contact with id '%s' not found", id)
+	}
+
+	updated := cloneContacts(d.contacts)
+	updated[i].SetPrimaryPhone(number.E164())
+	updated[i].Phones[0].Display = number.Display()
+	err = d.commit(updated, func(ctx context.Context) error {
+		return d.records.Update(ctx, updated[i])
+	})
+	if err != nil {
+		return err
 	}
+	d.index.Add(updated[i])
 
-	contact := domain.NewContact(name, phone)
-	d.contacts = append(d.contacts, contact)
-	return d.storage.Save(d.contacts)
+	return nil
 }
 
-func (d *Directory) DeleteContact(name string) error {
+func (d *Directory) GetContact(id string) (*domain.Contact, error) {
+	id = strings.TrimSpace(id)
+
+	d.refresh()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	i := d.indexOf(id)
+	if i < 0 {
+		return nil, newError(ErrNotFound, "contact with id '%s' not found", id)
+	}
+
+	contact := d.contacts[i].Clone()
+	return &contact, nil
+}
+
+// FindByName returns the contact whose name matches exactly, ignoring case.
+// It fails when several contacts share that name, since the caller then has
+// to pick one by ID.
+func (d *Directory) FindByName(name string) (*domain.Contact, error) {
 	name = strings.TrimSpace(name)
-	for i, contact := range d.contacts {
-		if strings.EqualFold(contact.Name, name) {
-			d.contacts = append(d.contacts[:i], d.contacts[i+1:]...)
-			return d.storage.Save(d.contacts)
+
+	d.refresh()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var found *domain.Contact
+	for _, contact := range d.contacts {
+		if !strings.EqualFold(contact.Name, name) {
+			continue
+		}
+		if found != nil {
+			return nil, newError(ErrConflict, "several contacts are named '%s', use their id instead", name)
 		}
+		contact = contact.Clone()
+		found = &contact
 	}
-	return fmt.Errorf("contact with name '%s' not found", name)
+
+	if found == nil {
+		return nil, newError(ErrNotFound, "contact with name '%s' not found", name)
+	}
+	return found, nil
 }
 
-func (d *Directory) EditContact(name, newPhone string) error {
-	name = strings.TrimSpace(name)
-	newPhone = strings.TrimSpace(newPhone)
+// SearchContact returns the contact best matching query.
+func (d *Directory) SearchContact(query string) (*domain.Contact, error) {
+	matches := d.SearchContacts(query)
+	if len(matches) == 0 {
+		return nil, newError(ErrNotFound, "contact with name '%s' not found", strings.TrimSpace(query))
+	}
+	return &matches[0], nil
+}
+
+// SearchContacts returns the contacts matching every word of query in any of
+// their fields, best matches first. Matching ignores case and accents, and
+// tolerates typos and partly typed words; see search.Index.Search. Unlike
+// Search, it never records the matches as looked up.
+func (d *Directory) SearchContacts(query string) []domain.Contact {
+	d.refresh()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	results := d.index.Search(query)
+	if len(results) == 0 {
+		return nil
+	}
 
+	positions := make(map[string]int, len(d.contacts))
 	for i, contact := range d.contacts {
-		if strings.EqualFold(contact.Name, name) {
-			d.contacts[i].Phone = newPhone
-			return d.storage.Save(d.contacts)
+		positions[contact.ID] = i
+	}
+
+	matches := make([]domain.Contact, 0, len(results))
+	for _, result := range results {
+		if i, ok := positions[result.ID]; ok {
+			matches = append(matches, d.contacts[i].Clone())
 		}
 	}
-	return fmt.Errorf("contact with name '%s' not found", name)
+	return matches
 }
 
-func (d *Directory) SearchContact(name string) (*domain.Contact, error) {
-	name = strings.TrimSpace(name)
-	for _, contact := range d.contacts {
-		if strings.Contains(strings.ToLower(contact.Name), strings.ToLower(name)) {
-			return &contact, nil
+func (d *Directory) ListContacts() []domain.Contact {
+	d.refresh()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return cloneContacts(d.contacts)
+}
+
+// prepareContact normalizes contact and reports every invalid field at once.
+func (d *Directory) prepareContact(contact domain.Contact) (domain.Contact, error) {
+	contact = normalizeContact(contact)
+
+	errs := validateContact(contact)
+	errs = append(errs, normalizePhones(&contact, d.defaultRegion())...)
+	if len(errs) > 0 {
+		return domain.Contact{}, &ValidationError{Fields: errs}
+	}
+
+	return contact, nil
+}
+
+func (d *Directory) defaultRegion() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.region
+}
+
+// lockForWrite takes the in-process write lock and, when the storage is
+// shared with other processes, the storage lock. Contacts are reloaded if
+// another process changed them, so the caller's update applies on top of the
+// latest data instead of clobbering it. The returned function releases the
+// locks, then sends the events of the changes made to the subscribers.
+func (d *Directory) lockForWrite() (func(), error) {
+	d.mu.Lock()
+	release := d.mu.Unlock
+
+	if locker, ok := d.storage.(storage.Locker); ok {
+		if err := locker.Lock(); err != nil {
+			d.mu.Unlock()
+			return nil, fmt.Errorf("failed to lock storage: %w", err)
+		}
+
+		release = func() {
+			_ = locker.Unlock()
+			d.mu.Unlock()
 		}
 	}
-	return nil, fmt.Errorf("contact with name '%s' not found", name)
+
+	changed, err := d.changedOnDisk()
+	if err == nil && changed {
+		err = d.reload(true)
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	return func() {
+		release()
+		d.deliver()
+	}, nil
 }
 
-func (d *Directory) SearchContacts(name string) []domain.Contact {
-	name = strings.TrimSpace(name)
-	var matches []domain.Contact
+// commit runs persist and only then makes contacts the directory's state, so
+// a failed write never leaves memory and storage out of sync. The changes are
+// then recorded in the audit log and queued for the subscribers. Callers must
+// hold the write lock.
+func (d *Directory) commit(contacts []domain.Contact, persist func(ctx context.Context) error) error {
+	changed, err := d.changedOnDisk()
+	if err != nil {
+		return err
+	}
+	if changed {
+		return newError(ErrConflict, "contacts were modified by another process, please retry")
+	}
 
-	for _, contact := range d.contacts {
-		if strings.Contains(strings.ToLower(contact.Name), strings.ToLower(name)) {
-			matches = append(matches, contact)
+	entries := d.auditEntries(contacts)
+	if err := persist(context.Background()); err != nil {
+		// The store disagreeing with the cache means someone else wrote to it.
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrExists) {
+			return newError(ErrConflict, "contacts were modified by another process, please retry: %w", err)
 		}
+		return err
 	}
+	d.contacts = contacts
+	d.appendAudit(entries)
+	d.publish(entries)
 
-	return matches
+	d.version, err = d.currentVersion()
+	return err
 }
 
-func (d *Directory) ListContacts() []domain.Contact {
-	return d.contacts
+// refresh reloads the contacts if another process changed them. Reads keep
+// serving the cached contacts when the storage cannot be checked.
+func (d *Directory) refresh() {
+	d.mu.RLock()
+	changed, err := d.changedOnDisk()
+	d.mu.RUnlock()
+
+	if err != nil || !changed {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if changed, err := d.changedOnDisk(); err == nil && changed {
+		_ = d.reload(false)
+	}
 }
 
-func (d *Directory) contactExists(name string) bool {
-	name = strings.TrimSpace(name)
+// reloadAttempts bounds how many times reload reads the storage again when
+// its version moved during a load.
+const reloadAttempts = 3
+
+// reload reads the contacts and groups along with the version they were read
+// at. The version is checked again after loading, since the storage may have
+// migrated what it read or another process written meanwhile; the contacts
+// are then read again rather than cached under a stale version. locked tells
+// whether the caller holds the storage lock.
+func (d *Directory) reload(locked bool) error {
+	var (
+		version  string
+		contacts []domain.Contact
+		groups   []domain.Group
+	)
+	for attempt := 1; ; attempt++ {
+		var err error
+		if version, err = d.currentVersion(); err != nil {
+			return err
+		}
+		if contacts, groups, err = d.load(locked); err != nil {
+			return err
+		}
+
+		after, err := d.currentVersion()
+		if err != nil {
+			return err
+		}
+		if after == version || attempt == reloadAttempts {
+			break
+		}
+	}
+
+	live := make([]domain.Contact, 0, len(contacts))
+	var trash []domain.Contact
+	for _, contact := range contacts {
+		if contact.DeletedAt.IsZero() {
+			live = append(live, contact)
+		} else {
+			trash = append(trash, contact)
+		}
+	}
+
+	d.accessMu.Lock()
+	for i, contact := range live {
+		if at, ok := d.accessed[contact.ID]; ok && at.After(contact.LastAccessedAt) {
+			live[i].LastAccessedAt = at
+		}
+	}
+	d.accessMu.Unlock()
+
+	d.contacts = live
+	d.trash = trash
+	d.groups = groups
+	d.index = search.New(live)
+	d.version = version
+	return nil
+}
+
+func (d *Directory) load(locked bool) ([]domain.Contact, []domain.Group, error) {
+	load := d.storage.Load
+	if loader, ok := d.storage.(storage.LockedLoader); ok && locked {
+		load = loader.LoadLocked
+	}
+
+	contacts, err := load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load contacts: %w", err)
+	}
+
+	var groups []domain.Group
+	if d.groupStore != nil {
+		if groups, err = d.groupStore.LoadGroups(); err != nil {
+			return nil, nil, fmt.Errorf("failed to load groups: %w", err)
+		}
+	}
+	return contacts, groups, nil
+}
+
+func (d *Directory) changedOnDisk() (bool, error) {
+	if _, ok := d.storage.(storage.Versioner); !ok {
+		return false, nil
+	}
+
+	version, err := d.currentVersion()
+	if err != nil {
+		return false, err
+	}
+
+	return version != d.version, nil
+}
+
+func (d *Directory) currentVersion() (string, error) {
+	versioner, ok := d.storage.(storage.Versioner)
+	if !ok {
+		return "", nil
+	}
+
+	version, err := versioner.Version()
+	if err != nil {
+		return "", fmt.Errorf("failed to check storage version: %w", err)
+	}
+
+	return version, nil
+}
+
+func (d *Directory) contactExists(name, phone string) bool {
 	for _, contact := range d.contacts {
-		if strings.EqualFold(contact.Name, name) {
+		if strings.EqualFold(contact.Name, name) && contact.PrimaryPhone() == phone {
 			return true
 		}
 	}
 	return false
 }
+
+func (d *Directory) indexOf(id string) int {
+	for i, contact := range d.contacts {
+		if contact.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func cloneContacts(contacts []domain.Contact) []domain.Contact {
+	clone := make([]domain.Contact, len(contacts))
+	for i, contact := range contacts {
+		clone[i] = contact.Clone()
+	}
+	return clone
+}
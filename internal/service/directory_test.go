@@ -1,13 +1,23 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/storage"
 )
 
 type mockStorage struct {
 	contacts []domain.Contact
+	groups   []domain.Group
 }
 
 func (m *mockStorage) Load() ([]domain.Contact, error) {
@@ -19,12 +29,42 @@ func (m *mockStorage) Save(contacts []domain.Contact) error {
 	return nil
 }
 
+func (m *mockStorage) LoadGroups() ([]domain.Group, error) {
+	return m.groups, nil
+}
+
+func (m *mockStorage) SaveGroups(groups []domain.Group) error {
+	m.groups = groups
+	return nil
+}
+
 func newMockStorage() *mockStorage {
 	return &mockStorage{
 		contacts: make([]domain.Contact, 0),
 	}
 }
 
+// newSeededDirectory returns a directory holding contacts, created in order
+// a day apart, the first a day after start. Its clock keeps advancing a day
+// each time it is read.
+func newSeededDirectory(t *testing.T, start time.Time, contacts []domain.Contact) *Directory {
+	t.Helper()
+
+	dir, _ := NewDirectory(newMockStorage())
+	created := start
+	dir.now = func() time.Time {
+		created = created.Add(24 * time.Hour)
+		return created
+	}
+
+	for _, contact := range contacts {
+		if _, err := dir.CreateContact(contact); err != nil {
+			t.Fatalf("Failed to create %s: %v", contact.Name, err)
+		}
+	}
+	return dir
+}
+
 func TestNewDirectory(t *testing.T) {
 	storage := newMockStorage()
 	dir, err := NewDirectory(storage)
@@ -56,8 +96,8 @@ func TestAddContact(t *testing.T) {
 		t.Errorf("Expected name 'John Doe', got '%s'", contact.Name)
 	}
 
-	if contact.Phone != "1234567890" {
-		t.Errorf("Expected phone '1234567890', got '%s'", contact.Phone)
+	if contact.PrimaryPhone() != "+11234567890" {
+		t.Errorf("Expected phone '+11234567890', got '%s'", contact.PrimaryPhone())
 	}
 }
 
@@ -70,7 +110,7 @@ func TestAddContact_Duplicate(t *testing.T) {
 		t.Fatalf("Failed to add first contact: %v", err)
 	}
 
-	err = dir.AddContact("John Doe", "0987654321")
+	err = dir.AddContact("John Doe", "1234567890")
 	if err == nil {
 		t.Error("Expected error when adding duplicate contact")
 	}
@@ -89,12 +129,39 @@ func TestAddContact_CaseInsensitive(t *testing.T) {
 		t.Fatalf("Failed to add first contact: %v", err)
 	}
 
-	err = dir.AddContact("john doe", "0987654321")
+	err = dir.AddContact("john doe", "1234567890")
 	if err == nil {
 		t.Error("Expected error when adding duplicate contact with different case")
 	}
 }
 
+func TestAddContact_SameName(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+
+	err := dir.AddContact("John Doe", "1234567890")
+	if err != nil {
+		t.Fatalf("Failed to add first contact: %v", err)
+	}
+
+	err = dir.AddContact("John Doe", "0987654321")
+	if err != nil {
+		t.Fatalf("Expected no error adding a namesake, got %v", err)
+	}
+
+	if len(dir.contacts) != 2 {
+		t.Fatalf("Expected 2 contacts, got %d", len(dir.contacts))
+	}
+
+	if dir.contacts[0].ID == "" || dir.contacts[0].ID == dir.contacts[1].ID {
+		t.Errorf("Expected distinct IDs, got '%s' and '%s'", dir.contacts[0].ID, dir.contacts[1].ID)
+	}
+
+	if _, err := dir.FindByName("John Doe"); err == nil {
+		t.Error("Expected error looking up an ambiguous name")
+	}
+}
+
 func TestDeleteContact(t *testing.T) {
 	storage := newMockStorage()
 	dir, _ := NewDirectory(storage)
@@ -109,7 +176,7 @@ func TestDeleteContact(t *testing.T) {
 		t.Fatalf("Failed to add second contact: %v", err)
 	}
 
-	err = dir.DeleteContact("John Doe")
+	err = dir.DeleteContact(dir.contacts[0].ID)
 	if err != nil {
 		t.Errorf("Expected no error deleting contact, got %v", err)
 	}
@@ -127,7 +194,7 @@ func TestDeleteContact_NotFound(t *testing.T) {
 	storage := newMockStorage()
 	dir, _ := NewDirectory(storage)
 
-	err := dir.DeleteContact("Non Existent")
+	err := dir.DeleteContact("non-existent-id")
 	if err == nil {
 		t.Error("Expected error when deleting non-existent contact")
 	}
@@ -142,13 +209,13 @@ func TestEditContact(t *testing.T) {
 		t.Fatalf("Failed to add contact: %v", err)
 	}
 
-	err = dir.EditContact("John Doe", "5555555555")
+	err = dir.EditContact(dir.contacts[0].ID, "5555555555")
 	if err != nil {
 		t.Errorf("Expected no error editing contact, got %v", err)
 	}
 
-	if dir.contacts[0].Phone != "5555555555" {
-		t.Errorf("Expected phone to be '5555555555', got '%s'", dir.contacts[0].Phone)
+	if dir.contacts[0].PrimaryPhone() != "+15555555555" {
+		t.Errorf("Expected phone to be '+15555555555', got '%s'", dir.contacts[0].PrimaryPhone())
 	}
 }
 
@@ -156,7 +223,7 @@ func TestEditContact_NotFound(t *testing.T) {
 	storage := newMockStorage()
 	dir, _ := NewDirectory(storage)
 
-	err := dir.EditContact("Non Existent", "1234567890")
+	err := dir.EditContact("non-existent-id", "1234567890")
 	if err == nil {
 		t.Error("Expected error when editing non-existent contact")
 	}
@@ -185,8 +252,90 @@ func TestSearchContact(t *testing.T) {
 		t.Errorf("Expected name 'John Doe', got '%s'", contact.Name)
 	}
 
-	if contact.Phone != "1234567890" {
-		t.Errorf("Expected phone '1234567890', got '%s'", contact.Phone)
+	if contact.PrimaryPhone() != "+11234567890" {
+		t.Errorf("Expected phone '+11234567890', got '%s'", contact.PrimaryPhone())
+	}
+}
+
+func TestSearchContacts_KeepsIndexUpToDate(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+
+	elodie, _ := dir.CreateContact(domain.Contact{Name: "Élodie Durand", Organization: "Acme", Phones: []domain.Phone{{Number: "+33 6 12 34 56 78"}}})
+	_, _ = dir.CreateContact(domain.Contact{Name: "Acme Support", Phones: []domain.Phone{{Number: "+1 415 555 0100"}}})
+
+	matches := dir.SearchContacts("acme")
+	if len(matches) != 2 || matches[0].Name != "Acme Support" {
+		t.Errorf("Expected both Acme contacts, the one named Acme first, got %v", matches)
+	}
+	if matches := dir.SearchContacts("elodie durnad"); len(matches) != 1 || matches[0].ID != elodie.ID {
+		t.Errorf("Expected a typo and missing accents to find Élodie, got %v", matches)
+	}
+	if matches := dir.SearchContacts("06 12 34 56 78"); len(matches) != 1 || matches[0].ID != elodie.ID {
+		t.Errorf("Expected the national number to find Élodie, got %v", matches)
+	}
+
+	elodie.Organization = "Globex"
+	if err := dir.UpdateContact(elodie); err != nil {
+		t.Fatalf("Failed to update contact: %v", err)
+	}
+	if matches := dir.SearchContacts("globex"); len(matches) != 1 {
+		t.Errorf("Expected the new organization to be searchable, got %v", matches)
+	}
+	if matches := dir.SearchContacts("acme"); len(matches) != 1 {
+		t.Errorf("Expected the old organization to be forgotten, got %v", matches)
+	}
+
+	if err := dir.DeleteContact(elodie.ID); err != nil {
+		t.Fatalf("Failed to delete contact: %v", err)
+	}
+	if _, err := dir.SearchContact("elodie"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the deleted contact not to be found, got %v", err)
+	}
+}
+
+func TestGetContact(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+
+	err := dir.AddContact("John Doe", "1234567890")
+	if err != nil {
+		t.Fatalf("Failed to add contact: %v", err)
+	}
+
+	contact, err := dir.GetContact(dir.contacts[0].ID)
+	if err != nil {
+		t.Errorf("Expected no error getting contact, got %v", err)
+	}
+
+	if contact.Name != "John Doe" {
+		t.Errorf("Expected name 'John Doe', got '%s'", contact.Name)
+	}
+
+	if _, err := dir.GetContact("non-existent-id"); err == nil {
+		t.Error("Expected error when getting non-existent contact")
+	}
+}
+
+func TestFindByName(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+
+	err := dir.AddContact("John Doe", "1234567890")
+	if err != nil {
+		t.Fatalf("Failed to add contact: %v", err)
+	}
+
+	contact, err := dir.FindByName("john doe")
+	if err != nil {
+		t.Errorf("Expected no error finding contact, got %v", err)
+	}
+
+	if contact.ID != dir.contacts[0].ID {
+		t.Errorf("Expected ID '%s', got '%s'", dir.contacts[0].ID, contact.ID)
+	}
+
+	if _, err := dir.FindByName("John"); err == nil {
+		t.Error("Expected error when name only partially matches")
 	}
 }
 
@@ -239,7 +388,261 @@ func TestTrimSpaces(t *testing.T) {
 		t.Errorf("Expected trimmed name 'John Doe', got '%s'", contact.Name)
 	}
 
-	if contact.Phone != "1234567890" {
-		t.Errorf("Expected trimmed phone '1234567890', got '%s'", contact.Phone)
+	if contact.PrimaryPhone() != "+11234567890" {
+		t.Errorf("Expected trimmed phone '+11234567890', got '%s'", contact.PrimaryPhone())
+	}
+}
+
+func TestListContacts_ReturnsCopy(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+
+	err := dir.AddContact("John Doe", "1234567890")
+	if err != nil {
+		t.Fatalf("Failed to add contact: %v", err)
+	}
+
+	contacts := dir.ListContacts()
+	contacts[0].Phones[0].Number = "0000000000"
+
+	if dir.ListContacts()[0].PrimaryPhone() != "+11234567890" {
+		t.Error("Expected ListContacts to return a copy of the internal slice")
+	}
+
+	matches := dir.SearchContacts("John")
+	matches[0].Phones[0].Number = "0000000000"
+
+	if dir.ListContacts()[0].PrimaryPhone() != "+11234567890" {
+		t.Error("Expected SearchContacts to return a copy of the internal slice")
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+
+	const workers = 20
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("Contact %d", i)
+			if err := dir.AddContact(name, "1234567890"); err != nil {
+				t.Errorf("Expected no error adding %s, got %v", name, err)
+				return
+			}
+			contact, err := dir.FindByName(name)
+			if err != nil {
+				t.Errorf("Expected no error finding %s, got %v", name, err)
+				return
+			}
+			if err := dir.EditContact(contact.ID, "0987654321"); err != nil {
+				t.Errorf("Expected no error editing %s, got %v", name, err)
+			}
+			_ = dir.SearchContacts("Contact")
+			_, _ = dir.SearchContact(name)
+			_ = dir.ListContacts()
+			if i%2 == 0 {
+				if err := dir.DeleteContact(contact.ID); err != nil {
+					t.Errorf("Expected no error deleting %s, got %v", name, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	contacts := dir.ListContacts()
+	if len(contacts) != workers/2 {
+		t.Errorf("Expected %d contacts, got %d", workers/2, len(contacts))
+	}
+
+	// Deleted contacts stay stored, in the trash.
+	if stored := len(contacts) + len(dir.Trash()); len(storage.contacts) != stored {
+		t.Errorf("Expected storage to hold %d contacts, got %d", stored, len(storage.contacts))
+	}
+}
+
+func TestDirectory_ReloadsChangesFromOtherProcess(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "contacts.json")
+
+	cli, err := NewDirectory(storage.NewJSONStorage(filePath))
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	web, err := NewDirectory(storage.NewJSONStorage(filePath))
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if err := web.AddContact("John Doe", "1234567890"); err != nil {
+		t.Fatalf("Failed to add contact: %v", err)
+	}
+	if err := cli.AddContact("Jane Smith", "0987654321"); err != nil {
+		t.Fatalf("Failed to add contact: %v", err)
+	}
+	john, err := web.FindByName("John Doe")
+	if err != nil {
+		t.Fatalf("Failed to find contact: %v", err)
+	}
+	if err := web.EditContact(john.ID, "5555555555"); err != nil {
+		t.Fatalf("Failed to edit contact: %v", err)
+	}
+
+	loaded, err := storage.NewJSONStorage(filePath).Load()
+	if err != nil {
+		t.Fatalf("Failed to load contacts: %v", err)
+	}
+
+	if len(loaded) != 2 {
+		t.Fatalf("Expected 2 contacts on disk, got %d", len(loaded))
+	}
+
+	if len(cli.ListContacts()) != 2 {
+		t.Errorf("Expected reader to see 2 contacts, got %d", len(cli.ListContacts()))
+	}
+
+	if err := cli.AddContact("john doe", "5555555555"); err == nil {
+		t.Error("Expected duplicate error for contact added by another process")
+	}
+}
+
+func TestDirectory_LegacyFileVersion(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "contacts.json")
+	legacy := `[{"name": "John Doe", "phone": "1234567890"}]`
+	if err := os.WriteFile(filePath, []byte(legacy), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	dir, err := NewDirectory(storage.NewJSONStorage(filePath))
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if changed, err := dir.changedOnDisk(); err != nil || changed {
+		t.Errorf("Expected the version of the migrated file to be cached, got changed=%v, err=%v", changed, err)
+	}
+	contacts := dir.ListContacts()
+	loaded, _ := storage.NewJSONStorage(filePath).Load()
+	if len(contacts) != 1 || len(loaded) != 1 || contacts[0].ID != loaded[0].ID {
+		t.Errorf("Expected the ID on disk, got %+v and %+v", contacts, loaded)
+	}
+}
+
+func TestDirectory_ConcurrentProcessesDoNotLoseWrites(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "contacts.json")
+
+	const processes = 4
+	const perProcess = 5
+
+	dirs := make([]*Directory, processes)
+	for i := range dirs {
+		dir, err := NewDirectory(storage.NewJSONStorage(filePath))
+		if err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		dirs[i] = dir
+	}
+
+	var wg sync.WaitGroup
+	for i, dir := range dirs {
+		wg.Add(1)
+		go func(i int, dir *Directory) {
+			defer wg.Done()
+			for j := 0; j < perProcess; j++ {
+				name := fmt.Sprintf("Contact %d-%d", i, j)
+				if err := dir.AddContact(name, "1234567890"); err != nil {
+					t.Errorf("Expected no error adding %s, got %v", name, err)
+				}
+			}
+		}(i, dir)
+	}
+	wg.Wait()
+
+	loaded, err := storage.NewJSONStorage(filePath).Load()
+	if err != nil {
+		t.Fatalf("Failed to load contacts: %v", err)
+	}
+
+	if len(loaded) != processes*perProcess {
+		t.Errorf("Expected %d contacts on disk, got %d", processes*perProcess, len(loaded))
+	}
+}
+
+type recordStorage struct {
+	mockStorage
+	calls []string
+}
+
+func (r *recordStorage) Save(contacts []domain.Contact) error {
+	r.calls = append(r.calls, "save")
+	return r.mockStorage.Save(contacts)
+}
+
+func (r *recordStorage) Get(ctx context.Context, id string) (domain.Contact, error) {
+	return domain.Contact{}, storage.ErrNotFound
+}
+
+func (r *recordStorage) Insert(ctx context.Context, contact domain.Contact) error {
+	r.calls = append(r.calls, "insert "+contact.Name)
+	return nil
+}
+
+func (r *recordStorage) Update(ctx context.Context, contact domain.Contact) error {
+	r.calls = append(r.calls, "update "+contact.ID)
+	return nil
+}
+
+func (r *recordStorage) Delete(ctx context.Context, id string) error {
+	r.calls = append(r.calls, "delete "+id)
+	return nil
+}
+
+func (r *recordStorage) Query(ctx context.Context, query storage.Query) ([]domain.Contact, error) {
+	return nil, nil
+}
+
+func TestDirectory_UsesRecordStore(t *testing.T) {
+	store := &recordStorage{}
+	dir, _ := NewDirectory(store)
+
+	if err := dir.AddContact("John Doe", "1234567890"); err != nil {
+		t.Fatalf("Failed to add contact: %v", err)
+	}
+	id := dir.contacts[0].ID
+	if err := dir.EditContact(id, "0987654321"); err != nil {
+		t.Fatalf("Failed to edit contact: %v", err)
+	}
+	if err := dir.DeleteContact(id); err != nil {
+		t.Fatalf("Failed to delete contact: %v", err)
+	}
+	if err := dir.PurgeContact(id); err != nil {
+		t.Fatalf("Failed to purge contact: %v", err)
+	}
+
+	expected := []string{"insert John Doe", "update " + id, "update " + id, "delete " + id}
+	if !slices.Equal(store.calls, expected) {
+		t.Errorf("Expected calls %v, got %v", expected, store.calls)
+	}
+}
+
+type failingStorage struct {
+	mockStorage
+}
+
+func (f *failingStorage) Save(contacts []domain.Contact) error {
+	return errors.New("disk full")
+}
+
+func TestDirectory_FailedWriteKeepsState(t *testing.T) {
+	dir, _ := NewDirectory(&failingStorage{})
+
+	if err := dir.AddContact("John Doe", "1234567890"); err == nil {
+		t.Fatal("Expected error when storage fails")
+	}
+
+	if len(dir.ListContacts()) != 0 {
+		t.Errorf("Expected no contacts after failed write, got %d", len(dir.ListContacts()))
 	}
 }
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/search"
+)
+
+// DefaultDuplicateScore is the score from which FindDuplicates reports a
+// pair: contacts with close names and a phone or email in common. Contacts
+// merely sharing a name score 0.5.
+const DefaultDuplicateScore = 0.6
+
+// Weights of the duplicate score. A pair with the same name, a phone and an
+// email in common scores 1.
+const (
+	nameWeight  = 0.5
+	phoneWeight = 0.25
+	emailWeight = 0.25
+)
+
+// MergeFields lists the fields two contacts can disagree on when merged,
+// named as in JSON. "name" stands for the first and last name too. Other
+// fields are combined: phones, emails, addresses, tags and groups are
+// joined, and so are differing notes.
+var MergeFields = []string{"name", "organization", "jobTitle", "birthday"}
+
+// Duplicate is a pair of contacts that are likely the same person. Keep is
+// the one worth keeping, the most complete or else the oldest. Score ranges
+// from 0 to 1, and Reasons explain it, e.g. "same phone +1 202 555 0100".
+type Duplicate struct {
+	Keep      domain.Contact
+	Other     domain.Contact
+	Score     float64
+	Reasons   []string
+	Conflicts []MergeConflict
+}
+
+// MergeConflict is a field two contacts hold different values of.
+type MergeConflict struct {
+	Field string
+	Keep  string
+	Other string
+}
+
+// MergeOptions settle the conflicts of a merge.
+type MergeOptions struct {
+	// TakeOther lists the fields of MergeFields whose value is taken from
+	// the merged contact rather than the kept one.
+	TakeOther []string
+}
+
+// FindDuplicates returns the pairs of contacts scoring at least minScore,
+// most likely duplicates first. Names are compared ignoring case, accents,
+// spacing and word order, and tolerating typos. A zero minScore uses
+// DefaultDuplicateScore.
+func (d *Directory) FindDuplicates(minScore float64) ([]Duplicate, error) {
+	if minScore == 0 {
+		minScore = DefaultDuplicateScore
+	}
+	if minScore < 0 || minScore > 1 {
+		return nil, invalidField("minScore", fmt.Sprintf("minimum score must be between 0 and 1, got %g", minScore), nil)
+	}
+
+	contacts := d.ListContacts()
+
+	// Only contacts sharing a phone, an email or a name word are compared,
+	// rather than every pair.
+	buckets := make(map[string][]int)
+	for i, contact := range contacts {
+		var keys []string
+		for _, phone := range contact.Phones {
+			keys = append(keys, "phone:"+phone.Number)
+		}
+		for _, email := range contact.Emails {
+			keys = append(keys, "email:"+strings.ToLower(email.Address))
+		}
+		for _, word := range search.Tokens(contact.Name) {
+			keys = append(keys, "name:"+word)
+		}
+		slices.Sort(keys)
+		for _, key := range slices.Compact(keys) {
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	seen := make(map[[2]int]bool)
+	var duplicates []Duplicate
+	for _, bucket := range buckets {
+		for x, i := range bucket {
+			for _, j := range bucket[x+1:] {
+				if seen[[2]int{i, j}] {
+					continue
+				}
+				seen[[2]int{i, j}] = true
+
+				if duplicate, ok := scorePair(contacts[i], contacts[j]); ok && duplicate.Score >= minScore {
+					duplicates = append(duplicates, duplicate)
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(duplicates, func(a, b Duplicate) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			strings.Compare(search.Fold(a.Keep.Name), search.Fold(b.Keep.Name)),
+			strings.Compare(a.Keep.ID, b.Keep.ID),
+			strings.Compare(a.Other.ID, b.Other.ID),
+		)
+	})
+	return duplicates, nil
+}
+
+// scorePair rates how likely a and b are the same person.
+func scorePair(a, b domain.Contact) (Duplicate, bool) {
+	var reasons []string
+	similarity := nameSimilarity(a.Name, b.Name)
+	switch {
+	case similarity == 1:
+		reasons = append(reasons, "same name")
+	case similarity >= 0.75:
+		reasons = append(reasons, "similar names")
+	}
+	score := nameWeight * similarity
+
+	if phone, ok := sharedPhone(a, b); ok {
+		score += phoneWeight
+		reasons = append(reasons, "same phone "+phone.String())
+	}
+	if email, ok := sharedEmail(a, b); ok {
+		score += emailWeight
+		reasons = append(reasons, "same email "+email)
+	}
+	if len(reasons) == 0 {
+		return Duplicate{}, false
+	}
+
+	if completeness(b) > completeness(a) ||
+		(completeness(b) == completeness(a) && olderThan(b, a)) {
+		a, b = b, a
+	}
+	return Duplicate{
+		Keep:      a,
+		Other:     b,
+		Score:     score,
+		Reasons:   reasons,
+		Conflicts: MergeConflicts(a, b),
+	}, true
+}
+
+// nameSimilarity returns 1 for names with the same words, whatever their
+// order, down to 0 for names with nothing in common.
+func nameSimilarity(a, b string) float64 {
+	wordsA, wordsB := search.Tokens(a), search.Tokens(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+
+	similarity := func(x, y string) float64 {
+		n := max(len([]rune(x)), len([]rune(y)))
+		return 1 - float64(search.Distance(x, y, n))/float64(n)
+	}
+	inOrder := similarity(strings.Join(wordsA, " "), strings.Join(wordsB, " "))
+	slices.Sort(wordsA)
+	slices.Sort(wordsB)
+	return max(inOrder, similarity(strings.Join(wordsA, " "), strings.Join(wordsB, " ")))
+}
+
+func sharedPhone(a, b domain.Contact) (domain.Phone, bool) {
+	for _, phone := range a.Phones {
+		if slices.ContainsFunc(b.Phones, func(p domain.Phone) bool { return p.Number == phone.Number }) {
+			return phone, true
+		}
+	}
+	return domain.Phone{}, false
+}
+
+func sharedEmail(a, b domain.Contact) (string, bool) {
+	for _, email := range a.Emails {
+		if slices.ContainsFunc(b.Emails, func(e domain.Email) bool { return strings.EqualFold(e.Address, email.Address) }) {
+			return email.Address, true
+		}
+	}
+	return "", false
+}
+
+// completeness counts the fields of contact that are filled in.
+func completeness(contact domain.Contact) int {
+	n := len(contact.Phones) + len(contact.Emails) + len(contact.Addresses) + len(contact.Tags) + len(contact.Groups)
+	for _, field := range []string{contact.Organization, contact.JobTitle, contact.Birthday, contact.Notes} {
+		if field != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// olderThan reports whether a was created before b. Contacts stored before
+// creation times were tracked count as oldest.
+func olderThan(a, b domain.Contact) bool {
+	if a.CreatedAt.Equal(b.CreatedAt) {
+		return a.ID < b.ID
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+// MergeConflicts returns the fields of MergeFields that keep and other both
+// fill in with different values.
+func MergeConflicts(keep, other domain.Contact) []MergeConflict {
+	var conflicts []MergeConflict
+	for _, field := range MergeFields {
+		a, b := mergeField(keep, field), mergeField(other, field)
+		if a != "" && b != "" && a != b {
+			conflicts = append(conflicts, MergeConflict{Field: field, Keep: a, Other: b})
+		}
+	}
+	return conflicts
+}
+
+func mergeField(contact domain.Contact, field string) string {
+	switch field {
+	case "name":
+		return contact.Name
+	case "organization":
+		return contact.Organization
+	case "jobTitle":
+		return contact.JobTitle
+	case "birthday":
+		return contact.Birthday
+	}
+	return ""
+}
+
+// MergeContacts merges the contact otherID into the contact keepID, deletes
+// it, and returns the merged contact. Fields only one of them fills in are
+// kept; see MergeFields and MergeOptions for the others.
+func (d *Directory) MergeContacts(keepID, otherID string, opts MergeOptions) (domain.Contact, error) {
+	keepID, otherID = strings.TrimSpace(keepID), strings.TrimSpace(otherID)
+	if keepID == otherID {
+		return domain.Contact{}, invalidField("id", "cannot merge a contact into itself", nil)
+	}
+	for _, field := range opts.TakeOther {
+		if !slices.Contains(MergeFields, field) {
+			return domain.Contact{}, invalidField("takeOther", fmt.Sprintf("unknown merge field '%s', expected one of %s", field, strings.Join(MergeFields, ", ")), nil)
+		}
+	}
+
+	var merged domain.Contact
+	// A single write, so the merged contact is never stored next to the one
+	// it absorbed.
+	err := d.Batch(func(tx *Tx) error {
+		keep, err := tx.Get(keepID)
+		if err != nil {
+			return err
+		}
+		other, err := tx.Get(otherID)
+		if err != nil {
+			return err
+		}
+
+		// Stored contacts are normalized already, their phones included.
+		merged = normalizeContact(mergeContacts(keep, other, opts.TakeOther))
+		if errs := validateContact(merged); len(errs) > 0 {
+			return &ValidationError{Fields: errs}
+		}
+		if err := d.checkGroups(merged); err != nil {
+			return err
+		}
+
+		tx.put(merged)
+		return tx.Delete(otherID)
+	})
+	if err != nil {
+		return domain.Contact{}, err
+	}
+
+	return merged.Clone(), nil
+}
+
+// mergeContacts combines other into keep, taking the fields listed in
+// takeOther from other.
+func mergeContacts(keep, other domain.Contact, takeOther []string) domain.Contact {
+	merged := keep.Clone()
+	other = other.Clone()
+	take := func(field string, value *string, otherValue string) {
+		if otherValue != "" && (*value == "" || slices.Contains(takeOther, field)) {
+			*value = otherValue
+		}
+	}
+
+	if other.Name != "" && (merged.Name == "" || slices.Contains(takeOther, "name")) {
+		merged.Name, merged.FirstName, merged.LastName = other.Name, other.FirstName, other.LastName
+	}
+	take("organization", &merged.Organization, other.Organization)
+	take("jobTitle", &merged.JobTitle, other.JobTitle)
+	take("birthday", &merged.Birthday, other.Birthday)
+
+	switch {
+	case other.Notes == "" || strings.Contains(merged.Notes, other.Notes):
+	case merged.Notes == "" || strings.Contains(other.Notes, merged.Notes):
+		merged.Notes = other.Notes
+	default:
+		merged.Notes += "\n\n" + other.Notes
+	}
+
+	for _, phone := range other.Phones {
+		if !slices.ContainsFunc(merged.Phones, func(p domain.Phone) bool { return p.Number == phone.Number }) {
+			merged.Phones = append(merged.Phones, phone)
+		}
+	}
+	for _, email := range other.Emails {
+		if !slices.ContainsFunc(merged.Emails, func(e domain.Email) bool { return strings.EqualFold(e.Address, email.Address) }) {
+			merged.Emails = append(merged.Emails, email)
+		}
+	}
+	for _, address := range other.Addresses {
+		if !slices.ContainsFunc(merged.Addresses, func(a domain.Address) bool { return sameAddress(a, address) }) {
+			merged.Addresses = append(merged.Addresses, address)
+		}
+	}
+	// normalizeContact drops the repeated tags and groups.
+	merged.Tags = append(merged.Tags, other.Tags...)
+	merged.Groups = append(merged.Groups, other.Groups...)
+	merged.Favorite = merged.Favorite || other.Favorite
+
+	if merged.CreatedAt.IsZero() || (!other.CreatedAt.IsZero() && other.CreatedAt.Before(merged.CreatedAt)) {
+		merged.CreatedAt = other.CreatedAt
+	}
+	if other.LastAccessedAt.After(merged.LastAccessedAt) {
+		merged.LastAccessedAt = other.LastAccessedAt
+	}
+	return merged
+}
+
+// sameAddress compares addresses ignoring their type, case and accents.
+func sameAddress(a, b domain.Address) bool {
+	fold := func(address domain.Address) domain.Address {
+		return domain.Address{
+			Street:     search.Fold(address.Street),
+			City:       search.Fold(address.City),
+			Region:     search.Fold(address.Region),
+			PostalCode: search.Fold(address.PostalCode),
+			Country:    search.Fold(address.Country),
+		}
+	}
+	return fold(a) == fold(b)
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+
+	john := domain.NewContact("John Doe", "+12025550100")
+	john.Organization = "Acme"
+	john, _ = dir.CreateContact(john)
+	jon, _ := dir.CreateContact(domain.NewContact("Jon  Doe", "+1 202 555 0100"))
+
+	reversed := domain.Contact{Name: "Zoë Martin", Emails: []domain.Email{{Address: "zoe@example.com"}}}
+	if _, err := dir.CreateContact(reversed); err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	dir.CreateContact(domain.Contact{Name: "martin zoe", Emails: []domain.Email{{Address: "ZOE@example.com"}}})
+
+	// A shared landline alone does not make two people the same.
+	dir.CreateContact(domain.NewContact("Alice Stone", "+442071234567"))
+	dir.CreateContact(domain.NewContact("Bob Stone", "+442071234567"))
+	dir.CreateContact(domain.NewContact("Jane Doe", "+12025550199"))
+
+	duplicates, err := dir.FindDuplicates(0)
+	if err != nil {
+		t.Fatalf("Failed to find duplicates: %v", err)
+	}
+	if len(duplicates) != 2 {
+		t.Fatalf("Expected 2 duplicates, got %d: %+v", len(duplicates), duplicates)
+	}
+
+	first := duplicates[0]
+	if names := []string{first.Keep.Name, first.Other.Name}; !slices.Contains(names, "Zoë Martin") || !slices.Contains(names, "martin zoe") {
+		t.Errorf("Expected the same name in another order first, got %v", names)
+	}
+	if first.Score != 0.75 || len(first.Reasons) != 2 || first.Reasons[0] != "same name" {
+		t.Errorf("Expected score 0.75 for the same name and email, got %v %v", first.Score, first.Reasons)
+	}
+
+	second := duplicates[1]
+	if second.Keep.ID != john.ID || second.Other.ID != jon.ID {
+		t.Errorf("Expected to keep the more complete John Doe, got %s over %s", second.Keep.Name, second.Other.Name)
+	}
+	if !slices.Equal(second.Reasons, []string{"similar names", "same phone +1 202 555 0100"}) {
+		t.Errorf("Expected similar names and the same phone, got %v", second.Reasons)
+	}
+	if len(second.Conflicts) != 1 || second.Conflicts[0] != (MergeConflict{Field: "name", Keep: "John Doe", Other: "Jon Doe"}) {
+		t.Errorf("Expected the names to conflict, got %+v", second.Conflicts)
+	}
+
+	if strict, _ := dir.FindDuplicates(0.9); len(strict) != 0 {
+		t.Errorf("Expected no pair above 0.9, got %d", len(strict))
+	}
+	if loose, _ := dir.FindDuplicates(0.5); len(loose) != 3 {
+		t.Errorf("Expected the Stones sharing a phone from 0.5, got %d pairs", len(loose))
+	}
+	if _, err := dir.FindDuplicates(1.5); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a score above 1, got %v", err)
+	}
+}
+
+func TestMergeContacts(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	dir.now = func() time.Time { return now }
+	family, _ := dir.CreateGroup("Family")
+
+	keep, _ := dir.CreateContact(domain.Contact{
+		Name:         "John Doe",
+		Organization: "Acme",
+		Phones:       []domain.Phone{{Number: "+12025550100"}},
+		Notes:        "Met at the conference",
+		Tags:         []string{"work"},
+	})
+	now = now.Add(-time.Hour)
+	other, _ := dir.CreateContact(domain.Contact{
+		Name:         "Jon Doe",
+		Organization: "Acme Inc",
+		JobTitle:     "Engineer",
+		Phones:       []domain.Phone{{Number: "+1 202 555 0100"}, {Type: domain.PhoneWork, Number: "+12025550111"}},
+		Emails:       []domain.Email{{Address: "jon@example.com"}},
+		Notes:        "Likes chess",
+		Tags:         []string{"Work", "chess"},
+		Groups:       []string{family.ID},
+		Favorite:     true,
+	})
+
+	merged, err := dir.MergeContacts(keep.ID, other.ID, MergeOptions{TakeOther: []string{"organization"}})
+	if err != nil {
+		t.Fatalf("Failed to merge contacts: %v", err)
+	}
+
+	if merged.ID != keep.ID || merged.Name != "John Doe" || merged.FirstName != "John" {
+		t.Errorf("Expected the kept contact's id and name, got %s %q", merged.ID, merged.Name)
+	}
+	if merged.Organization != "Acme Inc" || merged.JobTitle != "Engineer" {
+		t.Errorf("Expected the other organization and the job title only it had, got %q %q", merged.Organization, merged.JobTitle)
+	}
+	if len(merged.Phones) != 2 || merged.Phones[1].Number != "+12025550111" {
+		t.Errorf("Expected the shared phone once and the work phone, got %+v", merged.Phones)
+	}
+	if merged.PrimaryEmail() != "jon@example.com" || !merged.Favorite || !merged.InGroup(family.ID) {
+		t.Errorf("Expected the email, favorite and group of the other contact, got %+v", merged)
+	}
+	if !slices.Equal(merged.Tags, []string{"work", "chess"}) {
+		t.Errorf("Expected tags without repeats, got %v", merged.Tags)
+	}
+	if merged.Notes != "Met at the conference\n\nLikes chess" {
+		t.Errorf("Expected both notes, got %q", merged.Notes)
+	}
+	if !merged.CreatedAt.Equal(other.CreatedAt) {
+		t.Errorf("Expected the oldest creation time, got %v", merged.CreatedAt)
+	}
+
+	if _, err := dir.GetContact(other.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the merged contact to be deleted, got %v", err)
+	}
+	if stored, _ := dir.GetContact(keep.ID); stored.Organization != "Acme Inc" {
+		t.Errorf("Expected the merge to be stored, got %+v", stored)
+	}
+	if got := contactNames(dir.SearchContacts("jon@example.com")); !slices.Equal(got, []string{"John Doe"}) {
+		t.Errorf("Expected the search index to follow the merge, got %v", got)
+	}
+	if duplicates, _ := dir.FindDuplicates(0); len(duplicates) != 0 {
+		t.Errorf("Expected no duplicates left, got %d", len(duplicates))
+	}
+}
+
+func TestMergeContacts_Errors(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+	a, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	b, _ := dir.CreateContact(domain.NewContact("Jon Doe", "+12025550100"))
+
+	tests := []struct {
+		name        string
+		keep, other string
+		opts        MergeOptions
+		want        error
+	}{
+		{"Itself", a.ID, a.ID, MergeOptions{}, ErrValidation},
+		{"UnknownField", a.ID, b.ID, MergeOptions{TakeOther: []string{"phones"}}, ErrValidation},
+		{"MissingKeep", "missing", b.ID, MergeOptions{}, ErrNotFound},
+		{"MissingOther", a.ID, "missing", MergeOptions{}, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := dir.MergeContacts(tt.keep, tt.other, tt.opts); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if contacts := dir.ListContacts(); len(contacts) != 2 {
+		t.Errorf("Expected failed merges to change nothing, got %d contacts", len(contacts))
+	}
+}
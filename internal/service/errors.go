@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by Directory can be told apart with errors.Is.
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrValidation    = errors.New("validation failed")
+	ErrConflict      = errors.New("conflict")
+)
+
+// FieldError describes why a single field was rejected. Field uses the JSON
+// path of the value, e.g. "phones[1].number".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Err     error  `json:"-"`
+}
+
+// ValidationError lists every invalid field of a contact. It matches
+// ErrValidation, and the cause of each field, e.g. a *phone.ParseError, can
+// be reached with errors.As.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+func (e *ValidationError) Unwrap() []error {
+	var errs []error
+	for _, field := range e.Fields {
+		if field.Err != nil {
+			errs = append(errs, field.Err)
+		}
+	}
+	return errs
+}
+
+func invalidField(field, message string, err error) *ValidationError {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message, Err: err}}}
+}
+
+// kindError keeps a readable message while matching one of the Err values.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+func newError(kind error, format string, args ...any) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/phone"
+)
+
+func TestDirectory_TypedErrors(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+
+	created, err := dir.CreateContact(domain.NewContact("John Doe", "1234567890"))
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	if _, err := dir.CreateContact(domain.NewContact("Jane Doe", "1234567890")); err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	if _, err := dir.CreateContact(domain.NewContact("Jane Doe", "0987654321")); err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+
+	_, duplicateErr := dir.CreateContact(domain.NewContact("john doe", "123-456-7890"))
+	_, getErr := dir.GetContact("missing")
+	_, findErr := dir.FindByName("Nobody")
+	_, ambiguousErr := dir.FindByName("Jane Doe")
+
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"duplicate", duplicateErr, ErrAlreadyExists},
+		{"get missing", getErr, ErrNotFound},
+		{"find missing", findErr, ErrNotFound},
+		{"ambiguous name", ambiguousErr, ErrConflict},
+		{"delete missing", dir.DeleteContact("missing"), ErrNotFound},
+		{"edit missing", dir.EditContact("missing", "1234567890"), ErrNotFound},
+		{"update missing", dir.UpdateContact(domain.NewContact("Non Existent", "1234567890")), ErrNotFound},
+		{"invalid phone", dir.EditContact(created.ID, "abc"), ErrValidation},
+		{"invalid region", dir.SetDefaultRegion("XX"), ErrValidation},
+	}
+
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.expected) {
+			t.Errorf("Expected %s to match %v, got %v", tt.name, tt.expected, tt.err)
+		}
+	}
+}
+
+func TestValidationError_Fields(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+
+	_, err := dir.CreateContact(domain.Contact{
+		Birthday: "tomorrow",
+		Phones: []domain.Phone{
+			{Number: "1234567890"},
+			{Type: "pager", Number: "abc"},
+		},
+	})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a *ValidationError, got %v", err)
+	}
+
+	expected := []string{"name", "birthday", "phones[1].type", "phones[1].number"}
+	if len(validationErr.Fields) != len(expected) {
+		t.Fatalf("Expected fields %v, got %+v", expected, validationErr.Fields)
+	}
+	for i, field := range validationErr.Fields {
+		if field.Field != expected[i] {
+			t.Errorf("Expected field %d to be '%s', got '%s'", i, expected[i], field.Field)
+		}
+	}
+
+	if !errors.Is(err, phone.ErrInvalidCharacters) {
+		t.Errorf("Expected the phone error to be reachable, got %v", err)
+	}
+}
+
+func TestDirectory_ConflictError(t *testing.T) {
+	storage := newMockStorage()
+	dir, _ := NewDirectory(storage)
+
+	if err := dir.AddContact("John Doe", "1234567890"); err != nil {
+		t.Fatalf("Failed to add contact: %v", err)
+	}
+
+	// Another writer removes the contact behind the directory's back.
+	id := dir.contacts[0].ID
+	storage.contacts = nil
+
+	err := dir.EditContact(id, "0987654321")
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"slices"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+type subscriber struct {
+	id      int
+	handler func(domain.Event)
+}
+
+// queuedEvent is an event waiting to be sent to the subscribers there were
+// when its change was stored.
+type queuedEvent struct {
+	event       domain.Event
+	subscribers []subscriber
+}
+
+// Subscribe calls handler with an event for every contact created, updated
+// or deleted from now on, once the change is stored, in the order the
+// changes were made. Handlers run one at a time once the directory is
+// unlocked, usually before the method that made the change returns: they may
+// call the directory, but a slow handler holds back the next events. The
+// returned function unsubscribes handler.
+func (d *Directory) Subscribe(handler func(domain.Event)) func() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastSubscriber++
+	id := d.lastSubscriber
+	d.subscribers = append(d.subscribers, subscriber{id: id, handler: handler})
+
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.subscribers = slices.DeleteFunc(slices.Clone(d.subscribers), func(s subscriber) bool { return s.id == id })
+	}
+}
+
+// publish queues the events matching the stored changes for deliver to send.
+// Lookups and purges from the trash send none. Callers must hold the write
+// lock.
+func (d *Directory) publish(entries []domain.AuditEntry) {
+	if len(d.subscribers) == 0 {
+		return
+	}
+
+	for _, entry := range entries {
+		event := domain.Event{ID: domain.NewID(), Time: entry.Time, Actor: entry.Actor}
+		switch entry.Action {
+		case domain.AuditCreate, domain.AuditRestore:
+			event.Type, event.Contact = domain.ContactCreated, *entry.After
+		case domain.AuditUpdate:
+			event.Type, event.Contact, event.Previous = domain.ContactUpdated, *entry.After, entry.Before
+		case domain.AuditDelete:
+			event.Type, event.Contact = domain.ContactDeleted, *entry.Before
+		default:
+			continue
+		}
+
+		d.eventsMu.Lock()
+		d.events = append(d.events, queuedEvent{event: event, subscribers: d.subscribers})
+		d.eventsMu.Unlock()
+	}
+}
+
+// deliver sends the queued events to their subscribers, unless another
+// call is already sending them: that one sends the events queued meanwhile
+// too, which keeps them in order. A handler changing the directory thus has
+// its events sent once it returns.
+func (d *Directory) deliver() {
+	for d.delivering.TryLock() {
+		for {
+			d.eventsMu.Lock()
+			events := d.events
+			d.events = nil
+			d.eventsMu.Unlock()
+			if len(events) == 0 {
+				break
+			}
+
+			for _, queued := range events {
+				for _, s := range queued.subscribers {
+					s.handler(queued.event)
+				}
+			}
+		}
+		d.delivering.Unlock()
+
+		// Events queued after the check above and before the unlock were
+		// left for this call to send.
+		d.eventsMu.Lock()
+		pending := len(d.events)
+		d.eventsMu.Unlock()
+		if pending == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+func eventNames(events []domain.Event) []string {
+	var names []string
+	for _, event := range events {
+		names = append(names, string(event.Type)+" "+event.Contact.Name)
+	}
+	return names
+}
+
+func TestSubscribe(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+	dir.SetActor("alice")
+
+	var events []domain.Event
+	unsubscribe := dir.Subscribe(func(event domain.Event) { events = append(events, event) })
+
+	john, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	jane, _ := dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101"))
+	renamed := john
+	renamed.Name = "Johnny Doe"
+	dir.UpdateContact(renamed)
+	// Neither a lookup nor a rejected change is an event.
+	dir.SearchContact("johnny")
+	if _, err := dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101")); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("Expected ErrAlreadyExists, got %v", err)
+	}
+	dir.DeleteContacts(john.ID, jane.ID)
+	dir.RestoreContact(jane.ID)
+	dir.PurgeContact(john.ID)
+
+	want := []string{
+		"contact.created John Doe", "contact.created Jane Doe", "contact.updated Johnny Doe",
+		"contact.deleted Johnny Doe", "contact.deleted Jane Doe", "contact.created Jane Doe",
+	}
+	if got := eventNames(events); !slices.Equal(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+
+	update := events[2]
+	if update.Previous == nil || update.Previous.Name != "John Doe" || update.Actor != "alice" || update.Time.IsZero() || update.ID == "" {
+		t.Errorf("Expected the contact before the update, who made it and when, got %+v", update)
+	}
+	if events[0].Previous != nil || events[0].ID == events[1].ID {
+		t.Errorf("Expected distinct events without a previous contact, got %+v", events[:2])
+	}
+
+	unsubscribe()
+	dir.CreateContact(domain.NewContact("Ann Lee", "+12025550102"))
+	if len(events) != len(want) {
+		t.Errorf("Expected no event once unsubscribed, got %v", eventNames(events[len(want):]))
+	}
+}
+
+func TestSubscribe_FailedWrite(t *testing.T) {
+	dir, _ := NewDirectory(&failingStorage{})
+
+	var events []domain.Event
+	dir.Subscribe(func(event domain.Event) { events = append(events, event) })
+
+	if _, err := dir.CreateContact(domain.NewContact("John Doe", "+12025550100")); err == nil {
+		t.Fatal("Expected an error when the storage fails")
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected a change that was not stored to send no event, got %v", eventNames(events))
+	}
+}
+
+func TestSubscribe_HandlerCallsDirectory(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+
+	var events []domain.Event
+	dir.Subscribe(func(event domain.Event) {
+		events = append(events, event)
+		// Reading and writing from a handler would deadlock under the lock.
+		if len(dir.ListContacts()) == 1 {
+			dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101"))
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected handlers to run once the directory is unlocked")
+	}
+
+	want := []string{"contact.created John Doe", "contact.created Jane Doe"}
+	if got := eventNames(events); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+// accessPrecision is how precisely LastAccessedAt is tracked. A contact
+// looked up again sooner is not written back, so searching as the user types
+// does not rewrite the store on every key.
+const accessPrecision = time.Minute
+
+// SetFavorite marks the contact as a favorite, or clears the mark.
+func (d *Directory) SetFavorite(id string, favorite bool) error {
+	id = strings.TrimSpace(id)
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := d.indexOf(id)
+	if i < 0 {
+		return newError(ErrNotFound, "contact with id '%s' not found", id)
+	}
+	if d.contacts[i].Favorite == favorite {
+		return nil
+	}
+
+	updated := cloneContacts(d.contacts)
+	updated[i].Favorite = favorite
+	return d.commit(updated, func(ctx context.Context) error {
+		return d.records.Update(ctx, updated[i])
+	})
+}
+
+// Favorites returns the favorite contacts sorted by name.
+func (d *Directory) Favorites() []domain.Contact {
+	page, _ := d.QueryContacts(ListQuery{Sort: SortName, Filter: ListFilter{Favorite: true}})
+	return page.Contacts
+}
+
+// RecentContacts returns up to limit contacts, the most recently looked up
+// first. Contacts never looked up are left out.
+func (d *Directory) RecentContacts(limit int) []domain.Contact {
+	d.refresh()
+
+	d.mu.RLock()
+	var recent []domain.Contact
+	for _, contact := range d.contacts {
+		if !contact.LastAccessedAt.IsZero() {
+			recent = append(recent, contact.Clone())
+		}
+	}
+	d.mu.RUnlock()
+
+	slices.SortFunc(recent, func(a, b domain.Contact) int {
+		return cmp.Or(b.LastAccessedAt.Compare(a.LastAccessedAt), strings.Compare(a.ID, b.ID))
+	})
+	if limit > 0 && len(recent) > limit {
+		recent = recent[:limit]
+	}
+	return recent
+}
+
+// ViewContact returns the contact with the given ID, like GetContact, and
+// records that it was looked up. Failing to record it does not fail the
+// lookup.
+func (d *Directory) ViewContact(id string) (*domain.Contact, error) {
+	contact, err := d.GetContact(id)
+	if err != nil {
+		return nil, err
+	}
+
+	d.noteAccess(contact)
+	return contact, nil
+}
+
+// noteAccess records that contact was looked up and updates it to match.
+// The access time is kept in memory and saved in the background, along with
+// the other lookups noted meanwhile, so a lookup neither waits for the store
+// nor holds the write lock while it is written.
+func (d *Directory) noteAccess(contact *domain.Contact) {
+	now := d.now().UTC()
+	if now.Sub(contact.LastAccessedAt) < accessPrecision {
+		return
+	}
+
+	d.mu.Lock()
+	i := d.indexOf(contact.ID)
+	if i >= 0 {
+		d.contacts[i].LastAccessedAt = now
+	}
+	d.mu.Unlock()
+	if i < 0 {
+		return
+	}
+	contact.LastAccessedAt = now
+
+	d.accessMu.Lock()
+	defer d.accessMu.Unlock()
+	if d.accessed == nil {
+		d.accessed = make(map[string]time.Time)
+	}
+	d.accessed[contact.ID] = now
+	if d.flushed == nil {
+		d.flushed = make(chan struct{})
+		go d.flushAccess(d.flushed)
+	}
+}
+
+// FlushAccess waits for the lookups noted so far to be saved. Programs call
+// it before exiting.
+func (d *Directory) FlushAccess() {
+	d.accessMu.Lock()
+	flushed := d.flushed
+	d.accessMu.Unlock()
+
+	if flushed != nil {
+		<-flushed
+	}
+}
+
+// flushAccess saves the noted lookups until none is left, then closes
+// flushed. Errors are logged: looking a contact up must work even when the
+// store cannot be written to.
+func (d *Directory) flushAccess(flushed chan struct{}) {
+	for {
+		d.accessMu.Lock()
+		pending := d.accessed
+		d.accessed = nil
+		if len(pending) == 0 {
+			d.flushed = nil
+			d.accessMu.Unlock()
+			close(flushed)
+			return
+		}
+		d.accessMu.Unlock()
+
+		if err := d.saveAccess(pending); err != nil {
+			log.Printf("warning: failed to record %d contact lookup(s): %v", len(pending), err)
+		}
+	}
+}
+
+// saveAccess stores the access times in pending in a single write.
+func (d *Directory) saveAccess(pending map[string]time.Time) error {
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	updated := cloneContacts(d.contacts)
+	var changed []int
+	for i, contact := range updated {
+		if at, ok := pending[contact.ID]; ok {
+			updated[i].LastAccessedAt = maxTime(at, contact.LastAccessedAt)
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	return d.commit(updated, func(ctx context.Context) error {
+		if len(changed) == 1 {
+			return d.records.Update(ctx, updated[changed[0]])
+		}
+		return d.storage.Save(slices.Concat(updated, d.trash))
+	})
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
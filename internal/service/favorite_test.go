@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/storage"
+)
+
+func contactNames(contacts []domain.Contact) []string {
+	var names []string
+	for _, contact := range contacts {
+		names = append(names, contact.Name)
+	}
+	return names
+}
+
+func TestFavorites(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+
+	alice, _ := dir.CreateContact(domain.NewContact("Alice Martin", "+33612345678"))
+	bob, _ := dir.CreateContact(domain.NewContact("Bob Stone", "+14155550100"))
+	favorite := domain.NewContact("Carol White", "+442071234567")
+	favorite.Favorite = true
+	if _, err := dir.CreateContact(favorite); err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+
+	if err := dir.SetFavorite(bob.ID, true); err != nil {
+		t.Fatalf("Failed to set favorite: %v", err)
+	}
+	if err := dir.SetFavorite(alice.ID, false); err != nil {
+		t.Errorf("Expected clearing an unset favorite to succeed, got %v", err)
+	}
+	if err := dir.SetFavorite("missing", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown contact, got %v", err)
+	}
+
+	if got := contactNames(dir.Favorites()); !slices.Equal(got, []string{"Bob Stone", "Carol White"}) {
+		t.Errorf("Expected Bob and Carol as favorites, got %v", got)
+	}
+
+	if err := dir.SetFavorite(bob.ID, false); err != nil {
+		t.Fatalf("Failed to clear favorite: %v", err)
+	}
+	page, _ := dir.QueryContacts(ListQuery{Filter: ListFilter{Favorite: true}})
+	if got := pageNames(page); !slices.Equal(got, []string{"Carol White"}) {
+		t.Errorf("Expected only Carol left, got %v", got)
+	}
+}
+
+func TestRecentContacts(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	dir.now = func() time.Time { return now }
+
+	alice, _ := dir.CreateContact(domain.NewContact("Alice Martin", "+33612345678"))
+	dir.CreateContact(domain.NewContact("Bob Stone", "+14155550100"))
+	dir.CreateContact(domain.NewContact("Carol White", "+442071234567"))
+
+	if recent := dir.RecentContacts(10); len(recent) != 0 {
+		t.Errorf("Expected no recent contacts before any lookup, got %v", contactNames(recent))
+	}
+
+	viewed, err := dir.ViewContact(alice.ID)
+	if err != nil {
+		t.Fatalf("Failed to view contact: %v", err)
+	}
+	if !viewed.LastAccessedAt.Equal(now) {
+		t.Errorf("Expected the viewed contact to carry its access time, got %v", viewed.LastAccessedAt)
+	}
+	if _, err := dir.ViewContact("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown contact, got %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if matches, _ := dir.Search("name:bob"); len(matches) != 1 || !matches[0].LastAccessedAt.Equal(now) {
+		t.Errorf("Expected a single search match to be recorded, got %+v", matches)
+	}
+	now = now.Add(time.Hour)
+	if matches, _ := dir.Search("white"); len(matches) != 1 {
+		t.Fatalf("Expected a single match for white, got %v", contactNames(matches))
+	}
+	accessed := now
+	now = now.Add(30 * time.Second)
+	dir.Search("carol")
+	if contact := dir.RecentContacts(1)[0]; !contact.LastAccessedAt.Equal(accessed) {
+		t.Errorf("Expected a lookup within a minute of the last one not to be recorded, got %v", contact.LastAccessedAt)
+	}
+
+	now = now.Add(time.Hour)
+	dir.Search("org:missing OR phone:+*")
+	dir.SearchContacts("alice")
+
+	if got := contactNames(dir.RecentContacts(10)); !slices.Equal(got, []string{"Carol White", "Bob Stone", "Alice Martin"}) {
+		t.Errorf("Expected the most recent lookups first, skipping searches with several matches and plain SearchContacts, got %v", got)
+	}
+	if got := contactNames(dir.RecentContacts(1)); !slices.Equal(got, []string{"Carol White"}) {
+		t.Errorf("Expected the limit to apply, got %v", got)
+	}
+
+	page, _ := dir.QueryContacts(ListQuery{Sort: SortAccessed, Desc: true})
+	if got := pageNames(page); !slices.Equal(got, []string{"Carol White", "Bob Stone", "Alice Martin"}) {
+		t.Errorf("Expected contacts sorted by recency, got %v", got)
+	}
+
+	edited := *viewed
+	edited.Notes = "Called back"
+	edited.LastAccessedAt = time.Time{}
+	if err := dir.UpdateContact(edited); err != nil {
+		t.Fatalf("Failed to update contact: %v", err)
+	}
+	if contact, _ := dir.GetContact(alice.ID); contact.LastAccessedAt.IsZero() {
+		t.Error("Expected updating a contact to keep its access time")
+	}
+}
+
+func TestRecentContacts_Persisted(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "contacts.json")
+
+	dir, err := NewDirectory(storage.NewJSONStorage(filePath))
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	contact, _ := dir.CreateContact(domain.NewContact("Alice Martin", "+33612345678"))
+	dir.ViewContact(contact.ID)
+	dir.SetFavorite(contact.ID, true)
+	dir.FlushAccess()
+
+	reopened, err := NewDirectory(storage.NewJSONStorage(filePath))
+	if err != nil {
+		t.Fatalf("Failed to reopen directory: %v", err)
+	}
+	if got := contactNames(reopened.RecentContacts(0)); !slices.Equal(got, []string{"Alice Martin"}) {
+		t.Errorf("Expected the access to be reloaded, got %v", got)
+	}
+	if got := contactNames(reopened.Favorites()); !slices.Equal(got, []string{"Alice Martin"}) {
+		t.Errorf("Expected the favorite to be reloaded, got %v", got)
+	}
+}
+
+func TestNoteAccess_SavesInBackground(t *testing.T) {
+	store := &recordStorage{}
+	dir, _ := NewDirectory(store)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	dir.now = func() time.Time { return now }
+
+	alice, _ := dir.CreateContact(domain.NewContact("Alice Martin", "+33612345678"))
+	bob, _ := dir.CreateContact(domain.NewContact("Bob Stone", "+14155550100"))
+	store.calls = nil
+
+	dir.ViewContact(alice.ID)
+	if got := contactNames(dir.RecentContacts(0)); !slices.Equal(got, []string{"Alice Martin"}) {
+		t.Errorf("Expected the lookup to show before it is saved, got %v", got)
+	}
+	dir.FlushAccess()
+	if !slices.Equal(store.calls, []string{"update " + alice.ID}) {
+		t.Errorf("Expected the lookup to be saved, got %v", store.calls)
+	}
+
+	// Lookups noted while a flush runs are saved together.
+	store.calls = nil
+	if err := dir.saveAccess(map[string]time.Time{alice.ID: now, bob.ID: now, "missing": now}); err != nil {
+		t.Fatalf("Failed to save lookups: %v", err)
+	}
+	if !slices.Equal(store.calls, []string{"save"}) {
+		t.Errorf("Expected a single write for both lookups, got %v", store.calls)
+	}
+	if contact, _ := dir.GetContact(bob.ID); !contact.LastAccessedAt.Equal(now) {
+		t.Errorf("Expected Bob Stone's lookup to be saved, got %v", contact.LastAccessedAt)
+	}
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/search"
+)
+
+// errGroupsUnsupported is returned when changing groups in a storage that
+// has nowhere to keep them.
+var errGroupsUnsupported = fmt.Errorf("this storage cannot keep groups: %w", errors.ErrUnsupported)
+
+// GroupSummary is a group with the number of contacts in it.
+type GroupSummary struct {
+	domain.Group
+	Members int `json:"members"`
+}
+
+// ListGroups returns every group sorted by name.
+func (d *Directory) ListGroups() []GroupSummary {
+	d.refresh()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	summaries := make([]GroupSummary, len(d.groups))
+	positions := make(map[string]int, len(d.groups))
+	for i, group := range d.groups {
+		summaries[i].Group = group
+		positions[group.ID] = i
+	}
+	for _, contact := range d.contacts {
+		for _, id := range contact.Groups {
+			if i, ok := positions[id]; ok {
+				summaries[i].Members++
+			}
+		}
+	}
+
+	slices.SortFunc(summaries, func(a, b GroupSummary) int {
+		return cmp.Or(
+			strings.Compare(search.Fold(a.Name), search.Fold(b.Name)),
+			strings.Compare(a.ID, b.ID),
+		)
+	})
+	return summaries
+}
+
+// FindGroup returns the group with the given ID or, failing that, the group
+// with that name, ignoring case.
+func (d *Directory) FindGroup(ref string) (domain.Group, error) {
+	d.refresh()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	i := d.groupIndex(ref)
+	if i < 0 {
+		return domain.Group{}, newError(ErrNotFound, "group '%s' not found", strings.TrimSpace(ref))
+	}
+	return d.groups[i], nil
+}
+
+// GroupMembers returns the contacts in the group, sorted by name.
+func (d *Directory) GroupMembers(ref string) ([]domain.Contact, error) {
+	group, err := d.FindGroup(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := d.QueryContacts(ListQuery{Filter: ListFilter{Group: group.ID}})
+	if err != nil {
+		return nil, err
+	}
+	return page.Contacts, nil
+}
+
+// CreateGroup stores a new, empty group. Group names are unique, ignoring
+// case.
+func (d *Directory) CreateGroup(name string) (domain.Group, error) {
+	name, err := groupName(name)
+	if err != nil {
+		return domain.Group{}, err
+	}
+	if d.groupStore == nil {
+		return domain.Group{}, errGroupsUnsupported
+	}
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return domain.Group{}, err
+	}
+	defer unlock()
+
+	if d.groupNamed(name) >= 0 {
+		return domain.Group{}, newError(ErrAlreadyExists, "group '%s' already exists", name)
+	}
+
+	group := domain.NewGroup(name)
+	group.CreatedAt = d.now().UTC()
+
+	if err := d.commitGroups(append(slices.Clone(d.groups), group), nil); err != nil {
+		return domain.Group{}, err
+	}
+	return group, nil
+}
+
+func (d *Directory) RenameGroup(ref, name string) error {
+	name, err := groupName(name)
+	if err != nil {
+		return err
+	}
+	if d.groupStore == nil {
+		return errGroupsUnsupported
+	}
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := d.groupIndex(ref)
+	if i < 0 {
+		return newError(ErrNotFound, "group '%s' not found", strings.TrimSpace(ref))
+	}
+	if j := d.groupNamed(name); j >= 0 && j != i {
+		return newError(ErrAlreadyExists, "group '%s' already exists", name)
+	}
+
+	groups := slices.Clone(d.groups)
+	groups[i].Name = name
+	return d.commitGroups(groups, nil)
+}
+
+// DeleteGroup removes the group and takes its members out of it. The
+// contacts themselves are kept.
+func (d *Directory) DeleteGroup(ref string) error {
+	if d.groupStore == nil {
+		return errGroupsUnsupported
+	}
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := d.groupIndex(ref)
+	if i < 0 {
+		return newError(ErrNotFound, "group '%s' not found", strings.TrimSpace(ref))
+	}
+	id := d.groups[i].ID
+
+	var contacts []domain.Contact
+	for k, contact := range d.contacts {
+		if !contact.InGroup(id) {
+			continue
+		}
+		if contacts == nil {
+			contacts = cloneContacts(d.contacts)
+		}
+		contacts[k].Groups = slices.DeleteFunc(contacts[k].Groups, func(g string) bool { return g == id })
+	}
+
+	return d.commitGroups(slices.Delete(slices.Clone(d.groups), i, i+1), contacts)
+}
+
+// AddToGroup makes the contacts members of the group. Contacts already in it
+// are left alone.
+func (d *Directory) AddToGroup(ref string, contactIDs ...string) error {
+	return d.setMembership(ref, contactIDs, true)
+}
+
+// RemoveFromGroup takes the contacts out of the group. Contacts not in it are
+// left alone.
+func (d *Directory) RemoveFromGroup(ref string, contactIDs ...string) error {
+	return d.setMembership(ref, contactIDs, false)
+}
+
+func (d *Directory) setMembership(ref string, contactIDs []string, member bool) error {
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := d.groupIndex(ref)
+	if i < 0 {
+		return newError(ErrNotFound, "group '%s' not found", strings.TrimSpace(ref))
+	}
+	id := d.groups[i].ID
+
+	updated := cloneContacts(d.contacts)
+	changed := false
+	for _, contactID := range contactIDs {
+		contactID = strings.TrimSpace(contactID)
+		k := d.indexOf(contactID)
+		if k < 0 {
+			return newError(ErrNotFound, "contact with id '%s' not found", contactID)
+		}
+
+		switch in := updated[k].InGroup(id); {
+		case member && !in:
+			updated[k].Groups = append(updated[k].Groups, id)
+		case !member && in:
+			updated[k].Groups = slices.DeleteFunc(updated[k].Groups, func(g string) bool { return g == id })
+		default:
+			continue
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	return d.commit(updated, func(ctx context.Context) error {
+		return d.storage.Save(slices.Concat(updated, d.trash))
+	})
+}
+
+// ListTags returns every tag in use, sorted and spelled as on the first
+// contact carrying it.
+func (d *Directory) ListTags() []string {
+	d.refresh()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var tags []string
+	for _, contact := range d.contacts {
+		for _, tag := range contact.Tags {
+			if key := search.Fold(tag); !seen[key] {
+				seen[key] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	slices.SortFunc(tags, func(a, b string) int {
+		return strings.Compare(search.Fold(a), search.Fold(b))
+	})
+	return tags
+}
+
+// commitGroups is commit for a change to the groups. contacts is nil unless
+// memberships changed too. Callers must hold the write lock.
+func (d *Directory) commitGroups(groups []domain.Group, contacts []domain.Contact) error {
+	state := d.contacts
+	if contacts != nil {
+		state = contacts
+	}
+
+	err := d.commit(state, func(ctx context.Context) error {
+		// Memberships go first: if saving the groups then fails, the group
+		// is still there rather than referenced by contacts but gone.
+		if contacts != nil {
+			if err := d.storage.Save(slices.Concat(contacts, d.trash)); err != nil {
+				return err
+			}
+		}
+		return d.groupStore.SaveGroups(groups)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.groups = groups
+	return nil
+}
+
+// checkGroups rejects a contact listing groups that do not exist. Callers
+// must hold the lock.
+func (d *Directory) checkGroups(contact domain.Contact) error {
+	var errs []FieldError
+	for i, id := range contact.Groups {
+		if !slices.ContainsFunc(d.groups, func(g domain.Group) bool { return g.ID == id }) {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("groups[%d]", i), Message: fmt.Sprintf("group '%s' does not exist", id)})
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Fields: errs}
+	}
+	return nil
+}
+
+// groupIndex finds a group by ID, then by name. Callers must hold the lock.
+func (d *Directory) groupIndex(ref string) int {
+	ref = strings.TrimSpace(ref)
+	if i := slices.IndexFunc(d.groups, func(g domain.Group) bool { return g.ID == ref }); i >= 0 {
+		return i
+	}
+	return d.groupNamed(ref)
+}
+
+func (d *Directory) groupNamed(name string) int {
+	return slices.IndexFunc(d.groups, func(g domain.Group) bool { return strings.EqualFold(g.Name, name) })
+}
+
+func groupName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return "", invalidField("name", "group name is required", nil)
+	}
+	return name, nil
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/storage"
+)
+
+func memberNames(t *testing.T, dir *Directory, group string) []string {
+	t.Helper()
+
+	members, err := dir.GroupMembers(group)
+	if err != nil {
+		t.Fatalf("Failed to list members of %s: %v", group, err)
+	}
+
+	var names []string
+	for _, contact := range members {
+		names = append(names, contact.Name)
+	}
+	return names
+}
+
+func TestGroups(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+
+	alice, _ := dir.CreateContact(domain.NewContact("Alice Martin", "+33612345678"))
+	bob, _ := dir.CreateContact(domain.NewContact("Bob Stone", "+14155550100"))
+
+	family, err := dir.CreateGroup("  Family ")
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	if family.Name != "Family" || family.ID == "" || family.CreatedAt.IsZero() {
+		t.Errorf("Expected a trimmed, identified and dated group, got %+v", family)
+	}
+	if _, err := dir.CreateGroup("family"); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists for a name differing in case, got %v", err)
+	}
+	if _, err := dir.CreateGroup(" "); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrValidation for an empty name, got %v", err)
+	}
+	work, _ := dir.CreateGroup("Work")
+
+	if err := dir.AddToGroup("family", alice.ID, bob.ID); err != nil {
+		t.Fatalf("Failed to add members: %v", err)
+	}
+	if err := dir.AddToGroup(work.ID, alice.ID, alice.ID); err != nil {
+		t.Fatalf("Failed to add members: %v", err)
+	}
+	if err := dir.AddToGroup("Friends", alice.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown group, got %v", err)
+	}
+	if err := dir.AddToGroup("Work", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown contact, got %v", err)
+	}
+
+	if got := memberNames(t, dir, "Family"); !slices.Equal(got, []string{"Alice Martin", "Bob Stone"}) {
+		t.Errorf("Expected both contacts in Family, got %v", got)
+	}
+	if contact, _ := dir.GetContact(alice.ID); !slices.Equal(contact.Groups, []string{family.ID, work.ID}) {
+		t.Errorf("Expected Alice to be in both groups once, got %v", contact.Groups)
+	}
+
+	summaries := dir.ListGroups()
+	if len(summaries) != 2 || summaries[0].Name != "Family" || summaries[0].Members != 2 || summaries[1].Members != 1 {
+		t.Errorf("Expected Family (2) and Work (1), got %+v", summaries)
+	}
+
+	if err := dir.RemoveFromGroup(family.ID, bob.ID); err != nil {
+		t.Fatalf("Failed to remove member: %v", err)
+	}
+	if got := memberNames(t, dir, family.ID); !slices.Equal(got, []string{"Alice Martin"}) {
+		t.Errorf("Expected only Alice left in Family, got %v", got)
+	}
+
+	if err := dir.RenameGroup("work", "Colleagues"); err != nil {
+		t.Fatalf("Failed to rename group: %v", err)
+	}
+	if err := dir.RenameGroup("Colleagues", "FAMILY"); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists renaming onto another group, got %v", err)
+	}
+	if err := dir.RenameGroup("Colleagues", "colleagues"); err != nil {
+		t.Errorf("Expected a group to be renamed to another case of its name, got %v", err)
+	}
+	if got := memberNames(t, dir, "colleagues"); !slices.Equal(got, []string{"Alice Martin"}) {
+		t.Errorf("Expected renaming to keep members, got %v", got)
+	}
+
+	if err := dir.DeleteGroup("Family"); err != nil {
+		t.Fatalf("Failed to delete group: %v", err)
+	}
+	if _, err := dir.FindGroup("Family"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the deleted group to be gone, got %v", err)
+	}
+	if contact, _ := dir.GetContact(alice.ID); !slices.Equal(contact.Groups, []string{work.ID}) {
+		t.Errorf("Expected deleting a group to remove it from its members, got %v", contact.Groups)
+	}
+	if len(dir.ListContacts()) != 2 {
+		t.Error("Expected deleting a group to keep its contacts")
+	}
+}
+
+func TestGroups_ContactValidation(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+	group, _ := dir.CreateGroup("Family")
+
+	contact := domain.NewContact("Alice Martin", "+33612345678")
+	contact.Groups = []string{group.ID, "missing", group.ID}
+	_, err := dir.CreateContact(contact)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != "groups[1]" {
+		t.Fatalf("Expected a validation error for groups[1], got %v", err)
+	}
+
+	contact.Groups = []string{group.ID, group.ID}
+	created, err := dir.CreateContact(contact)
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	if !slices.Equal(created.Groups, []string{group.ID}) {
+		t.Errorf("Expected repeated groups to be dropped, got %v", created.Groups)
+	}
+}
+
+func TestGroups_Persisted(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "contacts.json")
+
+	dir, err := NewDirectory(storage.NewJSONStorage(filePath))
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	contact, _ := dir.CreateContact(domain.NewContact("Alice Martin", "+33612345678"))
+	if _, err := dir.CreateGroup("Family"); err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	if err := dir.AddToGroup("Family", contact.ID); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+
+	reopened, err := NewDirectory(storage.NewJSONStorage(filePath))
+	if err != nil {
+		t.Fatalf("Failed to reopen directory: %v", err)
+	}
+	if got := memberNames(t, reopened, "Family"); !slices.Equal(got, []string{"Alice Martin"}) {
+		t.Errorf("Expected the group and its member to be reloaded, got %v", got)
+	}
+}
+
+func TestGroups_Unsupported(t *testing.T) {
+	dir, _ := NewDirectory(&contactsOnlyStorage{})
+
+	if _, err := dir.CreateGroup("Family"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported without group storage, got %v", err)
+	}
+}
+
+// contactsOnlyStorage is a Storage that cannot keep groups.
+type contactsOnlyStorage struct {
+	contacts []domain.Contact
+}
+
+func (s *contactsOnlyStorage) Load() ([]domain.Contact, error) {
+	return s.contacts, nil
+}
+
+func (s *contactsOnlyStorage) Save(contacts []domain.Contact) error {
+	s.contacts = contacts
+	return nil
+}
+
+func TestTags(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+
+	contact := domain.NewContact("Alice Martin", "+33612345678")
+	contact.Tags = []string{" vip ", "", "Client  2024", "VIP"}
+	alice, err := dir.CreateContact(contact)
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	if !slices.Equal(alice.Tags, []string{"vip", "Client 2024"}) {
+		t.Errorf("Expected normalized tags, got %v", alice.Tags)
+	}
+
+	contact = domain.NewContact("Bob Stone", "+14155550100")
+	contact.Tags = []string{"Supplier", "a,b"}
+	if _, err := dir.CreateContact(contact); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrValidation for a tag with a comma, got %v", err)
+	}
+	contact.Tags = []string{"supplier", "Vip"}
+	if _, err := dir.CreateContact(contact); err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+
+	if got := dir.ListTags(); !slices.Equal(got, []string{"Client 2024", "supplier", "vip"}) {
+		t.Errorf("Expected every tag once, sorted, got %v", got)
+	}
+
+	page, _ := dir.QueryContacts(ListQuery{Filter: ListFilter{Tag: "VIP"}})
+	if len(page.Contacts) != 2 {
+		t.Errorf("Expected 2 contacts tagged vip, got %d", len(page.Contacts))
+	}
+	page, _ = dir.QueryContacts(ListQuery{Filter: ListFilter{Tag: "client"}})
+	if len(page.Contacts) != 0 {
+		t.Errorf("Expected tags to match whole, got %v", page.Contacts)
+	}
+
+	if matches := dir.SearchContacts("supplier"); len(matches) != 1 || matches[0].Name != "Bob Stone" {
+		t.Errorf("Expected tags to be searchable, got %v", matches)
+	}
+}
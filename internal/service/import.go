@@ -0,0 +1,232 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+// ConflictStrategy tells ImportContacts what to do with an imported contact
+// that is already stored: one with the ID of a stored contact, or with the
+// name and primary phone of one.
+type ConflictStrategy string
+
+const (
+	// ConflictSkip leaves the stored contact as it is.
+	ConflictSkip ConflictStrategy = "skip"
+	// ConflictOverwrite replaces the fields of the stored contact with the
+	// ones the import fills in. Lists, such as phones, are replaced whole.
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	// ConflictMerge combines both as MergeContacts does, the stored values
+	// winning conflicts.
+	ConflictMerge ConflictStrategy = "merge"
+	// ConflictRename stores the imported contact as a new one, numbering its
+	// name, e.g. "Alice Martin (2)".
+	ConflictRename ConflictStrategy = "rename"
+)
+
+// ImportOptions tune ImportContacts.
+type ImportOptions struct {
+	// Strategy settles conflicts with stored contacts, ConflictSkip when
+	// empty.
+	Strategy ConflictStrategy
+	// DryRun checks every contact and reports what the import would do,
+	// without storing anything.
+	DryRun bool
+}
+
+// ImportFailure tells why the contact at Index of an import was not stored.
+type ImportFailure struct {
+	Index int
+	Name  string
+	Err   error
+}
+
+// ImportResult sorts the contacts of an import by what happened to them.
+// Skipped lists contacts that already exist, Failed invalid ones.
+type ImportResult struct {
+	Strategy ConflictStrategy
+	DryRun   bool
+	Created  []domain.Contact
+	Updated  []domain.Contact
+	Skipped  []ImportFailure
+	Failed   []ImportFailure
+}
+
+// ImportContacts adds contacts to the directory in a single storage write.
+// New contacts are created as CreateContact would, with new IDs, and
+// contacts already stored are handled by opts.Strategy. A contact that
+// cannot be stored does not stop the import, but failing to write to the
+// storage stores nothing.
+func (d *Directory) ImportContacts(contacts []domain.Contact, opts ImportOptions) (ImportResult, error) {
+	if opts.Strategy == "" {
+		opts.Strategy = ConflictSkip
+	}
+	switch opts.Strategy {
+	case ConflictSkip, ConflictOverwrite, ConflictMerge, ConflictRename:
+	default:
+		return ImportResult{}, invalidField("strategy", fmt.Sprintf("conflict strategy must be skip, overwrite, merge or rename, got '%s'", opts.Strategy), nil)
+	}
+	result := ImportResult{Strategy: opts.Strategy, DryRun: opts.DryRun}
+
+	if opts.DryRun {
+		// A preview reads a copy of the contacts, so it neither waits for
+		// nor blocks writers.
+		region := d.defaultRegion()
+		d.refresh()
+
+		d.mu.RLock()
+		tx := &Tx{d: d, region: region, contacts: cloneContacts(d.contacts), trash: slices.Clone(d.trash)}
+		d.stageImport(tx, contacts, opts.Strategy, &result)
+		d.mu.RUnlock()
+
+		// Nothing was created, so nothing has an ID yet.
+		for i := range result.Created {
+			result.Created[i].ID = ""
+		}
+		return result, nil
+	}
+
+	err := d.Batch(func(tx *Tx) error {
+		d.stageImport(tx, contacts, opts.Strategy, &result)
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to import contacts: %w", err)
+	}
+	return result, nil
+}
+
+// stageImport stages contacts in tx and sorts them in result. Contacts the
+// import creates are staged, so they are found as conflicts of the following
+// ones. The caller holds the directory lock, for reading at least.
+func (d *Directory) stageImport(tx *Tx, contacts []domain.Contact, strategy ConflictStrategy, result *ImportResult) {
+	for i, imported := range contacts {
+		fail := func(list *[]ImportFailure, err error) {
+			*list = append(*list, ImportFailure{Index: i, Name: imported.Name, Err: err})
+		}
+
+		contact := normalizeContact(imported)
+		if errs := normalizePhones(&contact, tx.region); len(errs) > 0 {
+			fail(&result.Failed, &ValidationError{Fields: append(validateContact(contact), errs...)})
+			continue
+		}
+
+		j := findImported(tx.contacts, contact)
+		switch {
+		case j < 0:
+			contact.ID = domain.NewID()
+			contact.CreatedAt = d.now().UTC()
+			contact.LastAccessedAt = time.Time{}
+			contact.DeletedAt = time.Time{}
+		case strategy == ConflictSkip:
+			fail(&result.Skipped, conflictError(tx.contacts[j]))
+			continue
+		case strategy == ConflictOverwrite:
+			contact = overlayContact(tx.contacts[j], contact)
+		case strategy == ConflictMerge:
+			contact = mergeContacts(tx.contacts[j], contact, nil)
+		case strategy == ConflictRename:
+			contact.ID = domain.NewID()
+			contact.Name = numberedName(tx.contacts, contact.Name)
+			contact.CreatedAt = d.now().UTC()
+			contact.LastAccessedAt = time.Time{}
+			contact.DeletedAt = time.Time{}
+			j = -1
+		}
+
+		contact = normalizeContact(contact)
+		if errs := validateContact(contact); len(errs) > 0 {
+			fail(&result.Failed, &ValidationError{Fields: errs})
+			continue
+		}
+		if err := d.checkGroups(contact); err != nil {
+			fail(&result.Failed, err)
+			continue
+		}
+
+		tx.put(contact)
+		if j < 0 {
+			result.Created = append(result.Created, contact.Clone())
+		} else {
+			result.Updated = append(result.Updated, contact.Clone())
+		}
+	}
+}
+
+func findImported(contacts []domain.Contact, imported domain.Contact) int {
+	for i, contact := range contacts {
+		if imported.ID != "" && contact.ID == imported.ID {
+			return i
+		}
+	}
+	for i, contact := range contacts {
+		if strings.EqualFold(contact.Name, imported.Name) && contact.PrimaryPhone() == imported.PrimaryPhone() {
+			return i
+		}
+	}
+	return -1
+}
+
+func conflictError(existing domain.Contact) error {
+	return newError(ErrAlreadyExists, "contact '%s' with phone '%s' already exists (id: %s)", existing.Name, existing.PrimaryPhone(), existing.ID)
+}
+
+// numberedName returns name followed by the first number no contact is
+// named with yet, e.g. "Alice Martin (2)".
+func numberedName(contacts []domain.Contact, name string) string {
+	for n := 2; ; n++ {
+		numbered := fmt.Sprintf("%s (%d)", name, n)
+		taken := false
+		for _, contact := range contacts {
+			taken = taken || strings.EqualFold(contact.Name, numbered)
+		}
+		if !taken {
+			return numbered
+		}
+	}
+}
+
+// overlayContact returns existing with the fields imported has. Lists, such
+// as phones, are replaced as a whole.
+func overlayContact(existing, imported domain.Contact) domain.Contact {
+	contact := existing.Clone()
+	setString := func(field *string, value string) {
+		if strings.TrimSpace(value) != "" {
+			*field = value
+		}
+	}
+
+	setString(&contact.Name, imported.Name)
+	setString(&contact.FirstName, imported.FirstName)
+	setString(&contact.LastName, imported.LastName)
+	setString(&contact.Organization, imported.Organization)
+	setString(&contact.JobTitle, imported.JobTitle)
+	setString(&contact.Birthday, imported.Birthday)
+	setString(&contact.Notes, imported.Notes)
+
+	if len(imported.Phones) > 0 {
+		contact.Phones = imported.Clone().Phones
+	}
+	if len(imported.Emails) > 0 {
+		contact.Emails = imported.Clone().Emails
+	}
+	if len(imported.Addresses) > 0 {
+		contact.Addresses = imported.Clone().Addresses
+	}
+	if len(imported.Tags) > 0 {
+		contact.Tags = imported.Clone().Tags
+	}
+	if len(imported.Groups) > 0 {
+		contact.Groups = imported.Clone().Groups
+	}
+	contact.Favorite = contact.Favorite || imported.Favorite
+
+	if imported.Name == "" && (imported.FirstName != "" || imported.LastName != "") {
+		contact.Name = domain.JoinName(contact.FirstName, contact.LastName)
+	}
+	return contact
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/storage"
+)
+
+func TestImportContacts(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+	alice, _ := dir.CreateContact(domain.NewContact("Alice Martin", "+33612345678"))
+
+	imported := domain.NewContact("Bob Stone", "+14155550100")
+	imported.ID = "from-another-directory"
+
+	result, err := dir.ImportContacts([]domain.Contact{
+		domain.NewContact("Alice Martin", "+33612345678"),
+		imported,
+		domain.NewContact("Bad Phone", "not a number"),
+		{ID: alice.ID, Organization: "Acme"},
+	}, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Failed to import contacts: %v", err)
+	}
+
+	if result.Strategy != ConflictSkip {
+		t.Errorf("Expected conflicts to be skipped by default, got %s", result.Strategy)
+	}
+	if got := contactNames(result.Created); !slices.Equal(got, []string{"Bob Stone"}) {
+		t.Errorf("Expected Bob to be created, got %v", got)
+	}
+	if result.Created[0].ID == imported.ID || result.Created[0].CreatedAt.IsZero() {
+		t.Errorf("Expected a new ID and creation time, got %+v", result.Created[0])
+	}
+
+	var skipped []int
+	for _, skip := range result.Skipped {
+		skipped = append(skipped, skip.Index)
+		if !errors.Is(skip.Err, ErrAlreadyExists) {
+			t.Errorf("Expected ErrAlreadyExists for a skipped contact, got %v", skip.Err)
+		}
+	}
+	if !slices.Equal(skipped, []int{0, 3}) {
+		t.Errorf("Expected Alice to be skipped by name and by ID, got %v", skipped)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Index != 2 || !errors.Is(result.Failed[0].Err, ErrValidation) {
+		t.Errorf("Expected the contact with a bad phone to fail validation, got %+v", result.Failed)
+	}
+
+	if stored, _ := dir.GetContact(alice.ID); stored.Organization != "" {
+		t.Errorf("Expected Alice to be left as she was, got %+v", stored)
+	}
+	if got := contactNames(dir.SearchContacts("bob")); !slices.Equal(got, []string{"Bob Stone"}) {
+		t.Errorf("Expected the search index to hold the imported contacts, got %v", got)
+	}
+	if len(dir.ListContacts()) != 2 {
+		t.Errorf("Expected 2 contacts after the import, got %d", len(dir.ListContacts()))
+	}
+}
+
+func TestImportContacts_Strategies(t *testing.T) {
+	stored := domain.Contact{
+		Name:         "Alice Martin",
+		Organization: "Acme",
+		Phones:       []domain.Phone{{Number: "+33612345678"}},
+		Emails:       []domain.Email{{Address: "alice@acme.com"}},
+		Notes:        "Met at the conference",
+	}
+	imported := domain.Contact{
+		Name:         "alice martin",
+		Organization: "Globex",
+		JobTitle:     "Engineer",
+		Phones:       []domain.Phone{{Number: "+33 6 12 34 56 78"}},
+		Emails:       []domain.Email{{Address: "alice@globex.com"}},
+	}
+
+	tests := []struct {
+		strategy ConflictStrategy
+		check    func(t *testing.T, result ImportResult, contacts []domain.Contact)
+	}{
+		{ConflictOverwrite, func(t *testing.T, result ImportResult, contacts []domain.Contact) {
+			alice := contacts[0]
+			if len(result.Updated) != 2 || len(contacts) != 1 {
+				t.Fatalf("Expected Alice to be updated twice, got %+v", result)
+			}
+			if alice.Name != "alice martin" || alice.Organization != "Globex" || alice.JobTitle != "Engineer" || alice.Notes != "Met at the conference" {
+				t.Errorf("Expected the imported fields over the stored ones, got %+v", alice)
+			}
+			if len(alice.Emails) != 1 || alice.PrimaryEmail() != "alice@globex.com" {
+				t.Errorf("Expected the imported emails to replace the stored ones, got %+v", alice.Emails)
+			}
+		}},
+		{ConflictMerge, func(t *testing.T, result ImportResult, contacts []domain.Contact) {
+			alice := contacts[0]
+			if len(result.Updated) != 2 || len(contacts) != 1 {
+				t.Fatalf("Expected Alice to be updated twice, got %+v", result)
+			}
+			if alice.Name != "Alice Martin" || alice.Organization != "Acme" || alice.JobTitle != "Engineer" {
+				t.Errorf("Expected the stored fields to win and the missing ones to be filled, got %+v", alice)
+			}
+			if len(alice.Emails) != 2 || len(alice.Phones) != 1 {
+				t.Errorf("Expected both emails and a single phone, got %+v %+v", alice.Emails, alice.Phones)
+			}
+		}},
+		{ConflictRename, func(t *testing.T, result ImportResult, contacts []domain.Contact) {
+			if got := contactNames(result.Created); !slices.Equal(got, []string{"alice martin (2)", "alice martin (3)"}) {
+				t.Errorf("Expected numbered copies, got %v", got)
+			}
+			if len(contacts) != 3 || contacts[0].Organization != "Acme" {
+				t.Errorf("Expected the stored contact untouched next to the copies, got %+v", contacts)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			store := &recordStorage{}
+			dir, _ := NewDirectory(store)
+			if _, err := dir.CreateContact(stored); err != nil {
+				t.Fatalf("Failed to create contact: %v", err)
+			}
+			store.calls = nil
+
+			// The same contact twice: the second conflicts with the outcome
+			// of the first.
+			result, err := dir.ImportContacts([]domain.Contact{imported, imported}, ImportOptions{Strategy: tt.strategy})
+			if err != nil {
+				t.Fatalf("Failed to import contacts: %v", err)
+			}
+			if len(result.Created)+len(result.Updated) != 2 || len(result.Skipped)+len(result.Failed) != 0 {
+				t.Errorf("Expected both contacts to be stored, got %+v", result)
+			}
+			if !slices.Equal(store.calls, []string{"save"}) {
+				t.Errorf("Expected a single storage write, got %v", store.calls)
+			}
+			tt.check(t, result, dir.ListContacts())
+		})
+	}
+
+	dir, _ := NewDirectory(newMockStorage())
+	if _, err := dir.ImportContacts([]domain.Contact{imported}, ImportOptions{Strategy: "replace"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for an unknown strategy, got %v", err)
+	}
+}
+
+func TestImportContacts_FailedWrite(t *testing.T) {
+	dir, _ := NewDirectory(&failingStorage{})
+
+	_, err := dir.ImportContacts([]domain.Contact{
+		domain.NewContact("Alice Martin", "+33612345678"),
+		domain.NewContact("Bob Stone", "+14155550100"),
+	}, ImportOptions{})
+	if err == nil {
+		t.Fatal("Expected an error when the storage fails")
+	}
+	if contacts := dir.ListContacts(); len(contacts) != 0 {
+		t.Errorf("Expected a failed import to store nothing, got %v", contactNames(contacts))
+	}
+	if matches := dir.SearchContacts("alice"); len(matches) != 0 {
+		t.Errorf("Expected a failed import to leave the index alone, got %v", contactNames(matches))
+	}
+}
+
+func TestImportContacts_DryRun(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+	alice, _ := dir.CreateContact(domain.NewContact("Alice Martin", "+33612345678"))
+
+	contacts := []domain.Contact{
+		domain.NewContact("Bob Stone", "+14155550100"),
+		domain.NewContact("bob stone", "+1 415 555 0100"),
+		domain.NewContact("Alice Martin", "+33 6 12 34 56 78"),
+		{ID: alice.ID, JobTitle: "Engineer"},
+		{Name: "Carol White", Birthday: "yesterday"},
+		{Name: "Carol White", Groups: []string{"missing"}},
+	}
+	opts := ImportOptions{Strategy: ConflictOverwrite, DryRun: true}
+	result, err := dir.ImportContacts(contacts, opts)
+	if err != nil {
+		t.Fatalf("Failed to preview the import: %v", err)
+	}
+
+	if !result.DryRun || len(result.Created) != 1 || result.Created[0].ID != "" || result.Created[0].PrimaryPhone() != "+14155550100" {
+		t.Errorf("Expected Bob to be created, normalized and without an ID, got %+v", result.Created)
+	}
+	if got := contactNames(result.Updated); !slices.Equal(got, []string{"bob stone", "Alice Martin", "Alice Martin"}) {
+		t.Errorf("Expected the second Bob and Alice twice to be updated, got %v", got)
+	}
+	if result.Updated[2].JobTitle != "Engineer" {
+		t.Errorf("Expected Alice to get her job title, got %+v", result.Updated[2])
+	}
+
+	var failed []int
+	for _, failure := range result.Failed {
+		failed = append(failed, failure.Index)
+	}
+	if !slices.Equal(failed, []int{4, 5}) {
+		t.Errorf("Expected the bad birthday and the unknown group to fail, got %v", failed)
+	}
+
+	if contacts := dir.ListContacts(); len(contacts) != 1 || contacts[0].JobTitle != "" {
+		t.Errorf("Expected a dry run to store nothing, got %+v", contacts)
+	}
+
+	opts.DryRun = false
+	real, err := dir.ImportContacts(contacts, opts)
+	if err != nil {
+		t.Fatalf("Failed to import contacts: %v", err)
+	}
+	if len(real.Created) != len(result.Created) || len(real.Updated) != len(result.Updated) ||
+		len(real.Skipped) != len(result.Skipped) || len(real.Failed) != len(result.Failed) {
+		t.Errorf("Expected the import to do what the dry run reported, got %+v", real)
+	}
+}
+
+func TestImportContacts_DryRunTakesNoLock(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "contacts.json")
+	dir, _ := NewDirectory(storage.NewJSONStorage(filePath))
+	dir.CreateContact(domain.NewContact("Alice Martin", "+33612345678"))
+
+	// Another process is writing.
+	other := storage.NewJSONStorage(filePath)
+	if err := other.Lock(); err != nil {
+		t.Fatalf("Failed to lock storage: %v", err)
+	}
+	defer other.Unlock()
+
+	done := make(chan ImportResult)
+	go func() {
+		result, err := dir.ImportContacts([]domain.Contact{domain.NewContact("Bob Stone", "+14155550100")}, ImportOptions{DryRun: true})
+		if err != nil {
+			t.Errorf("Failed to preview the import: %v", err)
+		}
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		if len(result.Created) != 1 {
+			t.Errorf("Expected Bob to be created, got %+v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a dry run not to wait for the storage lock")
+	}
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/search"
+)
+
+type SortField string
+
+const (
+	SortName    SortField = "name"
+	SortPhone   SortField = "phone"
+	SortCreated SortField = "created"
+	// SortAccessed sorts by the time contacts were last looked up. Contacts
+	// never looked up come first, or last in descending order.
+	SortAccessed SortField = "accessed"
+)
+
+// ListQuery selects a page of contacts. Pages are addressed either by Offset
+// or by the Cursor returned with the previous page, which keeps working when
+// contacts are added or removed in between. A Limit of 0 returns every
+// matching contact.
+type ListQuery struct {
+	Filter ListFilter
+	Sort   SortField
+	Desc   bool
+	Offset int
+	Limit  int
+	Cursor string
+}
+
+// ListFilter keeps the contacts matching every non-empty field. Text fields
+// match case-insensitive substrings; Phone matches digits in any number,
+// ignoring formatting, and must hold some. Tag matches a whole tag, ignoring case, and Group a
+// group ID or name. Favorite keeps favorites only.
+type ListFilter struct {
+	Name          string
+	Phone         string
+	Email         string
+	Organization  string
+	Tag           string
+	Group         string
+	Favorite      bool
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+type ContactPage struct {
+	Contacts []domain.Contact
+	// Total counts the contacts matching the filter, across all pages.
+	Total  int
+	Offset int
+	Limit  int
+	// NextCursor fetches the following page, it is empty on the last one.
+	NextCursor string
+}
+
+// cursor records the position of the last contact of a page.
+type cursor struct {
+	Sort SortField `json:"s"`
+	Desc bool      `json:"d,omitempty"`
+	Key  string    `json:"k"`
+	ID   string    `json:"i"`
+}
+
+// QueryContacts returns the page of contacts selected by query, sorted by
+// name unless another field is given.
+func (d *Directory) QueryContacts(query ListQuery) (ContactPage, error) {
+	if query.Sort == "" {
+		query.Sort = SortName
+	}
+	if err := validateListQuery(query); err != nil {
+		return ContactPage{}, err
+	}
+
+	d.refresh()
+
+	d.mu.RLock()
+	// Groups may be given by name. An unknown group is left as is and
+	// matches no contact.
+	if i := d.groupIndex(query.Filter.Group); query.Filter.Group != "" && i >= 0 {
+		query.Filter.Group = d.groups[i].ID
+	}
+	var matches []domain.Contact
+	for _, contact := range d.contacts {
+		if query.Filter.matches(contact) {
+			matches = append(matches, contact)
+		}
+	}
+	d.mu.RUnlock()
+
+	type entry struct {
+		key     string
+		contact domain.Contact
+	}
+	entries := make([]entry, len(matches))
+	for i, contact := range matches {
+		entries[i] = entry{key: sortKey(contact, query.Sort), contact: contact}
+	}
+
+	compare := func(key, id string, e entry) int {
+		c := strings.Compare(e.key, key)
+		if c == 0 {
+			c = strings.Compare(e.contact.ID, id)
+		}
+		if query.Desc {
+			c = -c
+		}
+		return c
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return compare(b.key, b.contact.ID, a)
+	})
+
+	start := min(query.Offset, len(entries))
+	if query.Cursor != "" {
+		after, _ := decodeCursor(query.Cursor)
+		start, _ = slices.BinarySearchFunc(entries, after, func(e entry, c cursor) int {
+			if compare(c.Key, c.ID, e) <= 0 {
+				return -1
+			}
+			return 1
+		})
+	}
+
+	end := len(entries)
+	if query.Limit > 0 {
+		end = min(start+query.Limit, len(entries))
+	}
+
+	page := ContactPage{
+		Contacts: make([]domain.Contact, 0, end-start),
+		Total:    len(entries),
+		Offset:   start,
+		Limit:    query.Limit,
+	}
+	for _, e := range entries[start:end] {
+		page.Contacts = append(page.Contacts, e.contact.Clone())
+	}
+
+	if end < len(entries) {
+		last := entries[end-1]
+		page.NextCursor = encodeCursor(cursor{Sort: query.Sort, Desc: query.Desc, Key: last.key, ID: last.contact.ID})
+	}
+
+	return page, nil
+}
+
+func validateListQuery(query ListQuery) error {
+	var errs []FieldError
+
+	switch query.Sort {
+	case SortName, SortPhone, SortCreated, SortAccessed:
+	default:
+		errs = append(errs, FieldError{Field: "sort", Message: "sort must be name, phone, created or accessed"})
+	}
+	if query.Limit < 0 {
+		errs = append(errs, FieldError{Field: "limit", Message: "limit must not be negative"})
+	}
+	if query.Offset < 0 {
+		errs = append(errs, FieldError{Field: "offset", Message: "offset must not be negative"})
+	}
+	// Without digits, the phone filter would match every number.
+	if query.Filter.Phone != "" && onlyDigits(query.Filter.Phone) == "" {
+		errs = append(errs, FieldError{Field: "phone", Message: "phone filter must contain digits"})
+	}
+
+	if query.Cursor != "" {
+		c, err := decodeCursor(query.Cursor)
+		switch {
+		case err != nil:
+			errs = append(errs, FieldError{Field: "cursor", Message: "cursor is invalid", Err: err})
+		case c.Sort != query.Sort || c.Desc != query.Desc:
+			errs = append(errs, FieldError{Field: "cursor", Message: "cursor was issued for another sort order"})
+		case query.Offset != 0:
+			errs = append(errs, FieldError{Field: "offset", Message: "offset cannot be combined with a cursor"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Fields: errs}
+	}
+	return nil
+}
+
+func (f ListFilter) matches(contact domain.Contact) bool {
+	if f.Name != "" && !containsFold(contact.Name, f.Name) {
+		return false
+	}
+	if f.Organization != "" && !containsFold(contact.Organization, f.Organization) {
+		return false
+	}
+
+	if f.Phone != "" {
+		digits := onlyDigits(f.Phone)
+		if !slices.ContainsFunc(contact.Phones, func(p domain.Phone) bool {
+			return strings.Contains(onlyDigits(p.Number), digits)
+		}) {
+			return false
+		}
+	}
+
+	if f.Email != "" && !slices.ContainsFunc(contact.Emails, func(e domain.Email) bool {
+		return containsFold(e.Address, f.Email)
+	}) {
+		return false
+	}
+
+	if f.Tag != "" && !slices.ContainsFunc(contact.Tags, func(tag string) bool {
+		return search.Fold(tag) == search.Fold(strings.TrimSpace(f.Tag))
+	}) {
+		return false
+	}
+	if f.Group != "" && !contact.InGroup(f.Group) {
+		return false
+	}
+	if f.Favorite && !contact.Favorite {
+		return false
+	}
+
+	if !f.CreatedAfter.IsZero() && !contact.CreatedAt.After(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !contact.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+
+	return true
+}
+
+func sortKey(contact domain.Contact, field SortField) string {
+	switch field {
+	case SortPhone:
+		return contact.PrimaryPhone()
+	case SortCreated:
+		return timeKey(contact.CreatedAt)
+	case SortAccessed:
+		return timeKey(contact.LastAccessedAt)
+	default:
+		return search.Fold(contact.Name)
+	}
+}
+
+// timeKey formats t to sort chronologically as text, the zero time first.
+func timeKey(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000000000")
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+// containsFold reports whether substr is within s, ignoring case and accents.
+func containsFold(s, substr string) bool {
+	return strings.Contains(search.Fold(s), search.Fold(strings.TrimSpace(substr)))
+}
+
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+func newListDirectory(t *testing.T) *Directory {
+	t.Helper()
+
+	var contacts []domain.Contact
+	for _, c := range []struct{ name, phone, org string }{
+		{"Carol White", "+33612345678", "Acme"},
+		{"alice Smith", "+14155550100", "Globex"},
+		{"Bob Jones", "+442071234567", "Acme"},
+		{"Dave Brown", "+33187654321", ""},
+		{"Eve Black", "+14155550199", "Initech"},
+	} {
+		contact := domain.NewContact(c.name, c.phone)
+		contact.Organization = c.org
+		contacts = append(contacts, contact)
+	}
+
+	return newSeededDirectory(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), contacts)
+}
+
+func pageNames(page ContactPage) []string {
+	names := make([]string, len(page.Contacts))
+	for i, contact := range page.Contacts {
+		names[i] = contact.Name
+	}
+	return names
+}
+
+func TestQueryContacts_Sort(t *testing.T) {
+	dir := newListDirectory(t)
+
+	tests := []struct {
+		query ListQuery
+		want  []string
+	}{
+		{ListQuery{}, []string{"alice Smith", "Bob Jones", "Carol White", "Dave Brown", "Eve Black"}},
+		{ListQuery{Desc: true}, []string{"Eve Black", "Dave Brown", "Carol White", "Bob Jones", "alice Smith"}},
+		{ListQuery{Sort: SortPhone}, []string{"alice Smith", "Eve Black", "Dave Brown", "Carol White", "Bob Jones"}},
+		{ListQuery{Sort: SortCreated, Desc: true}, []string{"Eve Black", "Dave Brown", "Bob Jones", "alice Smith", "Carol White"}},
+	}
+
+	for _, tt := range tests {
+		page, err := dir.QueryContacts(tt.query)
+		if err != nil {
+			t.Fatalf("Expected no error for %+v, got %v", tt.query, err)
+		}
+		if got := pageNames(page); !slices.Equal(got, tt.want) {
+			t.Errorf("Expected %v for %+v, got %v", tt.want, tt.query, got)
+		}
+	}
+}
+
+func TestQueryContacts_Filter(t *testing.T) {
+	dir := newListDirectory(t)
+
+	tests := []struct {
+		filter ListFilter
+		want   []string
+	}{
+		{ListFilter{Name: "o"}, []string{"Bob Jones", "Carol White", "Dave Brown"}},
+		{ListFilter{Organization: "acme"}, []string{"Bob Jones", "Carol White"}},
+		{ListFilter{Phone: "+33"}, []string{"Carol White", "Dave Brown"}},
+		{ListFilter{Phone: "415 555"}, []string{"alice Smith", "Eve Black"}},
+		{ListFilter{Name: "o", Phone: "+33"}, []string{"Carol White", "Dave Brown"}},
+		{ListFilter{CreatedAfter: time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC)}, []string{"Bob Jones", "Dave Brown", "Eve Black"}},
+		{ListFilter{
+			CreatedAfter:  time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC),
+			CreatedBefore: time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC),
+		}, []string{"Bob Jones"}},
+	}
+
+	for _, tt := range tests {
+		page, err := dir.QueryContacts(ListQuery{Filter: tt.filter})
+		if err != nil {
+			t.Fatalf("Expected no error for %+v, got %v", tt.filter, err)
+		}
+		if got := pageNames(page); !slices.Equal(got, tt.want) {
+			t.Errorf("Expected %v for %+v, got %v", tt.want, tt.filter, got)
+		}
+		if page.Total != len(tt.want) {
+			t.Errorf("Expected total %d for %+v, got %d", len(tt.want), tt.filter, page.Total)
+		}
+	}
+}
+
+func TestQueryContacts_Offset(t *testing.T) {
+	dir := newListDirectory(t)
+
+	page, err := dir.QueryContacts(ListQuery{Offset: 2, Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := pageNames(page); !slices.Equal(got, []string{"Carol White", "Dave Brown"}) {
+		t.Errorf("Expected the third and fourth contacts, got %v", got)
+	}
+	if page.Total != 5 || page.Offset != 2 || page.Limit != 2 {
+		t.Errorf("Expected total 5, offset 2 and limit 2, got %+v", page)
+	}
+	if page.NextCursor == "" {
+		t.Error("Expected a cursor for the next page")
+	}
+
+	page, _ = dir.QueryContacts(ListQuery{Offset: 10, Limit: 2})
+	if len(page.Contacts) != 0 || page.NextCursor != "" {
+		t.Errorf("Expected an empty last page past the end, got %+v", page)
+	}
+}
+
+func TestQueryContacts_Cursor(t *testing.T) {
+	dir := newListDirectory(t)
+
+	query := ListQuery{Sort: SortCreated, Limit: 2}
+	first, _ := dir.QueryContacts(query)
+	if got := pageNames(first); !slices.Equal(got, []string{"Carol White", "alice Smith"}) {
+		t.Fatalf("Expected the two oldest contacts, got %v", got)
+	}
+
+	// Removing a contact from the first page must not shift the next one.
+	if err := dir.DeleteContact(first.Contacts[0].ID); err != nil {
+		t.Fatalf("Expected no error deleting contact, got %v", err)
+	}
+
+	var names []string
+	query.Cursor = first.NextCursor
+	for query.Cursor != "" {
+		page, err := dir.QueryContacts(query)
+		if err != nil {
+			t.Fatalf("Expected no error following cursor, got %v", err)
+		}
+		names = append(names, pageNames(page)...)
+		query.Cursor = page.NextCursor
+	}
+
+	if want := []string{"Bob Jones", "Dave Brown", "Eve Black"}; !slices.Equal(names, want) {
+		t.Errorf("Expected %v after the first page, got %v", want, names)
+	}
+}
+
+func TestQueryContacts_Invalid(t *testing.T) {
+	dir := newListDirectory(t)
+	page, _ := dir.QueryContacts(ListQuery{Limit: 1})
+
+	tests := []struct {
+		query ListQuery
+		field string
+	}{
+		{ListQuery{Sort: "age"}, "sort"},
+		{ListQuery{Limit: -1}, "limit"},
+		{ListQuery{Offset: -1}, "offset"},
+		{ListQuery{Filter: ListFilter{Phone: "abc"}}, "phone"},
+		{ListQuery{Cursor: "not a cursor"}, "cursor"},
+		{ListQuery{Cursor: page.NextCursor, Desc: true, Limit: 1}, "cursor"},
+		{ListQuery{Cursor: page.NextCursor, Offset: 1, Limit: 1}, "offset"},
+	}
+
+	for _, tt := range tests {
+		_, err := dir.QueryContacts(tt.query)
+
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("Expected a validation error for %+v, got %v", tt.query, err)
+			continue
+		}
+		if validationErr.Fields[0].Field != tt.field {
+			t.Errorf("Expected field %q for %+v, got %q", tt.field, tt.query, validationErr.Fields[0].Field)
+		}
+	}
+}
@@ -0,0 +1,722 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/phone"
+	"github.com/LaulauChau/go-directory/internal/search"
+)
+
+// QueryError reports a malformed search query. Pos is the offset in runes in
+// Query where the problem was found.
+type QueryError struct {
+	Query   string
+	Pos     int
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Pos+1, e.Message)
+}
+
+// queryFields lists the fields a query term can name, with their aliases.
+var queryFields = map[string]string{
+	"id":           "id",
+	"name":         "name",
+	"first":        "first",
+	"last":         "last",
+	"org":          "org",
+	"organization": "org",
+	"company":      "org",
+	"title":        "title",
+	"job":          "title",
+	"phone":        "phone",
+	"tel":          "phone",
+	"email":        "email",
+	"address":      "address",
+	"city":         "city",
+	"country":      "country",
+	"notes":        "notes",
+	"tag":          "tag",
+	"group":        "group",
+	"birthday":     "birthday",
+	"created":      "created",
+}
+
+// dateFields can be compared with <, <=, >, >= and ranges.
+var dateFields = map[string]bool{"birthday": true, "created": true}
+
+// createdLayout formats creation times so that they sort as strings.
+const createdLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// queryEnv holds what matching needs besides the contact itself.
+type queryEnv struct {
+	// words maps each bare word of the query to the index scores of the
+	// contacts it matches.
+	words map[string]map[string]float64
+	// groups maps group IDs to their names.
+	groups map[string]string
+}
+
+type queryNode interface {
+	match(contact domain.Contact, env *queryEnv) bool
+}
+
+type andNode []queryNode
+
+func (n andNode) match(contact domain.Contact, env *queryEnv) bool {
+	for _, child := range n {
+		if !child.match(contact, env) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode []queryNode
+
+func (n orNode) match(contact domain.Contact, env *queryEnv) bool {
+	for _, child := range n {
+		if child.match(contact, env) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct{ node queryNode }
+
+func (n notNode) match(contact domain.Contact, env *queryEnv) bool {
+	return !n.node.match(contact, env)
+}
+
+// wordNode is a bare word, matched through the search index like a plain
+// search so it tolerates typos.
+type wordNode string
+
+func (n wordNode) match(contact domain.Contact, env *queryEnv) bool {
+	return env.words[string(n)][contact.ID] > 0
+}
+
+// fieldNode is a "field:value" term. Values may use "*" as a wildcard; date
+// fields also accept comparisons and "from..to" ranges.
+type fieldNode struct {
+	field    string
+	value    string
+	op       string
+	from, to string
+}
+
+func (n fieldNode) match(contact domain.Contact, env *queryEnv) bool {
+	switch n.field {
+	case "id":
+		return contact.ID == n.value
+	case "name":
+		return matchText(n.value, contact.Name, contact.FirstName, contact.LastName)
+	case "first":
+		return matchText(n.value, contact.FirstName)
+	case "last":
+		return matchText(n.value, contact.LastName)
+	case "org":
+		return matchText(n.value, contact.Organization)
+	case "title":
+		return matchText(n.value, contact.JobTitle)
+	case "notes":
+		return matchText(n.value, contact.Notes)
+	case "email":
+		return slices.ContainsFunc(contact.Emails, func(e domain.Email) bool {
+			return matchText(n.value, e.Address)
+		})
+	case "address", "city", "country":
+		return slices.ContainsFunc(contact.Addresses, func(a domain.Address) bool {
+			switch n.field {
+			case "city":
+				return matchText(n.value, a.City)
+			case "country":
+				return matchText(n.value, a.Country)
+			}
+			return matchText(n.value, a.Street, a.City, a.Region, a.PostalCode, a.Country)
+		})
+	case "phone":
+		return slices.ContainsFunc(contact.Phones, func(p domain.Phone) bool {
+			return matchPhone(n.value, p.Number)
+		})
+	case "tag":
+		return slices.ContainsFunc(contact.Tags, func(tag string) bool {
+			return matchWhole(n.value, tag)
+		})
+	case "group":
+		return slices.ContainsFunc(contact.Groups, func(id string) bool {
+			name, ok := env.groups[id]
+			return ok && matchWhole(n.value, name)
+		})
+	case "birthday":
+		return contact.Birthday != "" && n.compare(contact.Birthday)
+	case "created":
+		return !contact.CreatedAt.IsZero() && n.compare(contact.CreatedAt.UTC().Format(createdLayout))
+	}
+	return false
+}
+
+// compare checks a date field against the term. Values are compared as
+// fixed-width ISO strings; n.from and n.to hold the first and last instant of
+// the term's date, so "created:2025-01-01" means that whole day.
+func (n fieldNode) compare(value string) bool {
+	switch n.op {
+	case ">":
+		return value > n.to
+	case ">=":
+		return value >= n.from
+	case "<":
+		return value < n.from
+	case "<=":
+		return value <= n.to
+	default:
+		return value >= n.from && value <= n.to
+	}
+}
+
+// matchText reports whether any of values contains pattern, or, when the
+// pattern has wildcards, whether a value or one of its words matches it.
+func matchText(pattern string, values ...string) bool {
+	for _, value := range values {
+		value = search.Fold(value)
+		if !strings.Contains(pattern, "*") {
+			if strings.Contains(value, pattern) {
+				return true
+			}
+			continue
+		}
+		if wildcardMatch(pattern, value) || slices.ContainsFunc(strings.Fields(value), func(word string) bool {
+			return wildcardMatch(pattern, word)
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWhole reports whether value, as a whole, is pattern, ignoring case and
+// accents.
+func matchWhole(pattern, value string) bool {
+	return wildcardMatch(pattern, search.Fold(value))
+}
+
+// matchPhone compares a phone pattern with a stored number. Patterns starting
+// with "+" are anchored on the country code, others match the digits
+// anywhere, with or without the national trunk prefix.
+func matchPhone(pattern, number string) bool {
+	if strings.HasPrefix(pattern, "+") {
+		if !strings.Contains(pattern, "*") {
+			return number == pattern
+		}
+		return wildcardMatch(pattern, number)
+	}
+
+	candidates := []string{onlyDigits(number)}
+	if parsed, err := phone.Parse(number, ""); err == nil {
+		candidates = append(candidates, parsed.National)
+	}
+	trimmed := strings.TrimLeft(pattern, "0")
+
+	for _, candidate := range candidates {
+		if strings.Contains(pattern, "*") {
+			if wildcardMatch(pattern, candidate) || wildcardMatch(trimmed, candidate) {
+				return true
+			}
+		} else if strings.Contains(candidate, pattern) || (trimmed != "" && strings.Contains(candidate, trimmed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardMatch matches value against pattern, where "*" stands for any
+// sequence of characters.
+func wildcardMatch(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+
+	last := len(parts) - 1
+	for _, part := range parts[1:last] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, parts[last])
+}
+
+// queryParser turns a query into a tree of nodes:
+//
+//	query := and { "OR" and }
+//	and   := unary { ["AND"] unary }
+//	unary := ("-" | "NOT") unary | "(" query ")" | term
+//	term  := word | "quoted phrase" | field ":" value
+type queryParser struct {
+	text  string
+	pos   int
+	words []string
+}
+
+func parseQuery(text string) (queryNode, []string, error) {
+	p := &queryParser{text: text}
+	p.skipSpaces()
+	if p.pos == len(p.text) {
+		return nil, nil, p.errorf(0, "query is empty")
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.pos < len(p.text) {
+		return nil, nil, p.errorf(p.pos, "unexpected ')'")
+	}
+	return node, p.words, nil
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	var nodes orNode
+	for {
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+
+		if !p.keyword("OR") {
+			break
+		}
+		if p.atEnd() {
+			return nil, p.errorf(p.pos, "missing term after OR")
+		}
+	}
+
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var nodes andNode
+	for {
+		p.skipSpaces()
+		if p.atEnd() || p.peek() == ')' || p.peekKeyword("OR") {
+			break
+		}
+		p.keyword("AND")
+
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	switch len(nodes) {
+	case 0:
+		if p.peekKeyword("OR") {
+			return nil, p.errorf(p.pos, "missing term before OR")
+		}
+		return nil, p.errorf(p.pos, "missing term")
+	case 1:
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	p.skipSpaces()
+	start := p.pos
+
+	if p.keyword("NOT") || p.consume('-') {
+		if p.atEnd() || p.peekSpace() {
+			return nil, p.errorf(start, "missing term after '%s'", strings.TrimSpace(p.text[start:p.pos]))
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+	}
+
+	if p.consume('(') {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if !p.consume(')') {
+			return nil, p.errorf(start, "missing ')' to close this '('")
+		}
+		return node, nil
+	}
+
+	return p.parseTerm()
+}
+
+func (p *queryParser) parseTerm() (queryNode, error) {
+	start := p.pos
+
+	if p.peek() == '"' {
+		phrase, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return p.word(start, phrase)
+	}
+
+	raw := p.bare()
+	name, value, found := strings.Cut(raw, ":")
+	if !found || !isFieldName(name) {
+		return p.word(start, raw)
+	}
+
+	field, ok := queryFields[strings.ToLower(name)]
+	if !ok {
+		return nil, p.errorf(start, "unknown field '%s'%s", name, suggestField(name))
+	}
+
+	valueStart := start + len(name) + 1
+	if value == "" && p.peek() == '"' {
+		var err error
+		if value, err = p.quoted(); err != nil {
+			return nil, err
+		}
+	}
+	if strings.TrimSpace(value) == "" {
+		return nil, p.errorf(valueStart, "missing value after '%s:'", name)
+	}
+
+	return p.fieldTerm(field, value, valueStart)
+}
+
+// word records a bare word or phrase, which is looked up in the index.
+func (p *queryParser) word(start int, text string) (queryNode, error) {
+	if len(search.Tokens(text)) == 0 {
+		return nil, p.errorf(start, "'%s' has no letters or digits to search for", text)
+	}
+	p.words = append(p.words, text)
+	return wordNode(text), nil
+}
+
+func (p *queryParser) fieldTerm(field, value string, pos int) (queryNode, error) {
+	node := fieldNode{field: field}
+
+	if dateFields[field] {
+		for _, op := range []string{">=", "<=", ">", "<"} {
+			if rest, ok := strings.CutPrefix(value, op); ok {
+				node.op, value = op, rest
+				break
+			}
+		}
+
+		from, to, isRange := strings.Cut(value, "..")
+		if isRange && node.op != "" {
+			return nil, p.errorf(pos, "a range cannot be combined with '%s'", node.op)
+		}
+		if !isRange {
+			to = from
+		}
+
+		var err error
+		if node.from, _, err = dateBounds(field, from); err != nil {
+			return nil, p.errorf(pos, "%v", err)
+		}
+		if _, node.to, err = dateBounds(field, to); err != nil {
+			return nil, p.errorf(pos, "%v", err)
+		}
+		if node.from > node.to {
+			return nil, p.errorf(pos, "range starts after it ends")
+		}
+		return node, nil
+	}
+
+	if strings.ContainsAny(value[:1], "<>") {
+		return nil, p.errorf(pos, "field '%s' cannot be compared, only birthday and created can", field)
+	}
+
+	switch field {
+	case "id":
+		node.value = value
+	case "phone":
+		node.value = strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) || r == '+' || r == '*' {
+				return r
+			}
+			return -1
+		}, value)
+		if onlyDigits(node.value) == "" {
+			return nil, p.errorf(pos, "phone value '%s' has no digits", value)
+		}
+	default:
+		node.value = search.Fold(value)
+	}
+	return node, nil
+}
+
+// dateBounds returns the first and last instants a date term covers, in the
+// form the field is compared in.
+func dateBounds(field, value string) (string, string, error) {
+	if field == "birthday" {
+		if _, err := time.Parse(time.DateOnly, value); err != nil {
+			return "", "", fmt.Errorf("'%s' is not a date, use YYYY-MM-DD", value)
+		}
+		return value, value, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		t = t.UTC()
+		return t.Format(createdLayout), t.Format(createdLayout), nil
+	}
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return "", "", fmt.Errorf("'%s' is not a date, use YYYY-MM-DD or an RFC 3339 time", value)
+	}
+	end := day.Add(24*time.Hour - time.Nanosecond)
+	return day.Format(createdLayout), end.Format(createdLayout), nil
+}
+
+func suggestField(name string) string {
+	best, bestDistance := "", 3
+	for field := range queryFields {
+		if d := search.Distance(strings.ToLower(name), field, 2); d < bestDistance || d == bestDistance && field < best {
+			best, bestDistance = field, d
+		}
+	}
+	if best != "" {
+		return fmt.Sprintf(", did you mean '%s'?", best)
+	}
+
+	fields := make([]string, 0, len(queryFields))
+	for field, canonical := range queryFields {
+		if field == canonical {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+	return " (fields: " + strings.Join(fields, ", ") + ")"
+}
+
+func isFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// bare reads an unquoted term, up to a space or a parenthesis. A quoted
+// value right after "field:" is left for the caller.
+func (p *queryParser) bare() string {
+	start := p.pos
+	for !p.atEnd() {
+		r := p.peek()
+		if unicode.IsSpace(r) || r == '(' || r == ')' || r == '"' && p.pos > start && p.text[p.pos-1] == ':' {
+			break
+		}
+		p.pos += p.width()
+	}
+	return p.text[start:p.pos]
+}
+
+func (p *queryParser) quoted() (string, error) {
+	start := p.pos
+	p.pos++
+	end := strings.IndexByte(p.text[p.pos:], '"')
+	if end < 0 {
+		return "", p.errorf(start, "missing closing quote")
+	}
+	value := p.text[p.pos : p.pos+end]
+	p.pos += end + 1
+	return value, nil
+}
+
+func (p *queryParser) keyword(word string) bool {
+	if !p.peekKeyword(word) {
+		return false
+	}
+	p.pos += len(word)
+	p.skipSpaces()
+	return true
+}
+
+// peekKeyword reports whether an uppercase operator keyword comes next.
+func (p *queryParser) peekKeyword(word string) bool {
+	p.skipSpaces()
+	rest := p.text[p.pos:]
+	if !strings.HasPrefix(rest, word) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(rest[len(word):])
+	return r == utf8.RuneError || unicode.IsSpace(r) || r == '(' || r == '-'
+}
+
+func (p *queryParser) consume(c byte) bool {
+	if p.atEnd() || p.text[p.pos] != c {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+// peek returns the rune at p.pos, decoded so that the bytes of a multi-byte
+// character are never mistaken for spaces or operators.
+func (p *queryParser) peek() rune {
+	if p.atEnd() {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(p.text[p.pos:])
+	return r
+}
+
+// width returns the length in bytes of the rune at p.pos.
+func (p *queryParser) width() int {
+	_, size := utf8.DecodeRuneInString(p.text[p.pos:])
+	return size
+}
+
+func (p *queryParser) peekSpace() bool {
+	return unicode.IsSpace(p.peek())
+}
+
+func (p *queryParser) skipSpaces() {
+	for !p.atEnd() && p.peekSpace() {
+		p.pos += p.width()
+	}
+}
+
+func (p *queryParser) atEnd() bool {
+	return p.pos >= len(p.text)
+}
+
+// errorf reports a problem at the byte offset pos, counted in runes in the
+// QueryError.
+func (p *queryParser) errorf(pos int, format string, args ...any) error {
+	return &QueryError{Query: p.text, Pos: utf8.RuneCountInString(p.text[:pos]), Message: fmt.Sprintf(format, args...)}
+}
+
+// Search returns the contacts matching query, which combines plain words
+// with field terms:
+//
+//	alice                      any field, as with SearchContacts
+//	name:alice "new york"      a field, or a quoted phrase
+//	phone:+33*  email:*@acme.com
+//	created:>2025-01-01  birthday:1990-01-01..1990-12-31
+//	tag:vip  group:family      a whole tag or group name
+//	-org:acme  NOT org:acme    exclude
+//	(city:paris OR city:lyon) engineer
+//
+// Matches are ranked by their words when the query has some, and sorted by
+// name otherwise. A malformed query fails with a ValidationError for the
+// field "q" wrapping a *QueryError.
+//
+// A search narrowed down to a single contact counts as looking it up, as with
+// ViewContact, and updates its LastAccessedAt. A search finding several
+// contacts updates none: it does not tell which one was wanted, and marking
+// them all would fill the recent contacts with whatever a broad search
+// matched.
+func (d *Directory) Search(query string) ([]domain.Contact, error) {
+	matches, err := d.search(query)
+	if err == nil && len(matches) == 1 {
+		d.noteAccess(&matches[0])
+	}
+	return matches, err
+}
+
+func (d *Directory) search(query string) ([]domain.Contact, error) {
+	root, words, err := parseQuery(query)
+	if err != nil {
+		return nil, invalidField("q", err.Error(), err)
+	}
+	if _, plain := root.(wordNode); plain || isPlainWords(root) {
+		return d.SearchContacts(query), nil
+	}
+
+	d.refresh()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	env := &queryEnv{
+		words:  make(map[string]map[string]float64, len(words)),
+		groups: make(map[string]string, len(d.groups)),
+	}
+	for _, group := range d.groups {
+		env.groups[group.ID] = group.Name
+	}
+	for _, word := range words {
+		scores := make(map[string]float64)
+		for _, result := range d.index.Search(word) {
+			scores[result.ID] = result.Score
+		}
+		env.words[word] = scores
+	}
+
+	type match struct {
+		contact domain.Contact
+		score   float64
+	}
+	var matches []match
+	for _, contact := range d.contacts {
+		if !root.match(contact, env) {
+			continue
+		}
+		score := 0.0
+		for _, scores := range env.words {
+			score += scores[contact.ID]
+		}
+		matches = append(matches, match{contact: contact, score: score})
+	}
+
+	slices.SortFunc(matches, func(a, b match) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return cmp.Or(
+			strings.Compare(search.Fold(a.contact.Name), search.Fold(b.contact.Name)),
+			strings.Compare(a.contact.ID, b.contact.ID),
+		)
+	})
+
+	contacts := make([]domain.Contact, len(matches))
+	for i, m := range matches {
+		contacts[i] = m.contact.Clone()
+	}
+	return contacts, nil
+}
+
+// isPlainWords reports whether node only ANDs bare words, i.e. the query is
+// a plain search.
+func isPlainWords(node queryNode) bool {
+	and, ok := node.(andNode)
+	if !ok {
+		return false
+	}
+	for _, child := range and {
+		if _, ok := child.(wordNode); !ok {
+			return false
+		}
+	}
+	return true
+}
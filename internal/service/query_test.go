@@ -0,0 +1,211 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+func newQueryDirectory(t *testing.T) *Directory {
+	t.Helper()
+
+	contacts := []domain.Contact{
+		{Name: "Alice Martin", Organization: "Acme", JobTitle: "Engineer", Birthday: "1990-04-01",
+			Phones: []domain.Phone{{Number: "+33612345678"}}, Emails: []domain.Email{{Address: "alice@acme.com"}},
+			Addresses: []domain.Address{{City: "Paris", Country: "France"}}},
+		{Name: "Bob Stone", Organization: "Globex", Notes: "Vendor for printers",
+			Phones: []domain.Phone{{Number: "+14155550100"}}, Addresses: []domain.Address{{City: "Lyon", Country: "France"}}},
+		{Name: "Élodie Durand", Organization: "Acme", Birthday: "1985-11-20",
+			Phones: []domain.Phone{{Number: "+33187654321"}}, Emails: []domain.Email{{Address: "elodie@globex.com"}}},
+		{Name: "Alicia Keys", Phones: []domain.Phone{{Number: "+442071234567"}}},
+	}
+	contacts[0].Tags = []string{"VIP", "client"}
+	contacts[1].Tags = []string{"supplier"}
+
+	dir := newSeededDirectory(t, time.Date(2024, 12, 30, 10, 0, 0, 0, time.UTC), contacts)
+
+	stored := dir.ListContacts()
+	last := stored[len(stored)-1]
+	if _, err := dir.CreateGroup("Family"); err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	if err := dir.AddToGroup("Family", last.ID); err != nil {
+		t.Fatalf("Failed to add %s to a group: %v", last.Name, err)
+	}
+
+	return dir
+}
+
+func TestDirectory_Search(t *testing.T) {
+	dir := newQueryDirectory(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"alice", []string{"Alice Martin", "Alicia Keys"}},
+		{"name:alice", []string{"Alice Martin"}},
+		{"name:ali*", []string{"Alice Martin", "Alicia Keys"}},
+		{"name:elodie", []string{"Élodie Durand"}},
+		{"org:acme -name:alice", []string{"Élodie Durand"}},
+		{"org:acme NOT org:globex", []string{"Alice Martin", "Élodie Durand"}},
+		{"phone:+33*", []string{"Alice Martin", "Élodie Durand"}},
+		{"phone:0612345678", []string{"Alice Martin"}},
+		{"phone:+14155550100", []string{"Bob Stone"}},
+		{"email:*@globex.com", []string{"Élodie Durand"}},
+		{"city:paris OR city:lyon", []string{"Alice Martin", "Bob Stone"}},
+		{"(city:paris OR city:lyon) vendor", []string{"Bob Stone"}},
+		{"country:france enginer", []string{"Alice Martin"}},
+		{`notes:"vendor for"`, []string{"Bob Stone"}},
+		{"created:>2025-01-01", []string{"Alicia Keys", "Élodie Durand"}},
+		{"created:2025-01-01", []string{"Bob Stone"}},
+		{"created:<=2025-01-01", []string{"Alice Martin", "Bob Stone"}},
+		{"created:2024-12-31..2025-01-01", []string{"Alice Martin", "Bob Stone"}},
+		{"birthday:<1990-01-01", []string{"Élodie Durand"}},
+		{"title:engineer AND org:acme", []string{"Alice Martin"}},
+		{"tag:vip", []string{"Alice Martin"}},
+		{"tag:supp*", []string{"Bob Stone"}},
+		{"tag:sup", nil},
+		{"group:family OR tag:supplier", []string{"Alicia Keys", "Bob Stone"}},
+		{"ali* -group:family", []string{"Alice Martin"}},
+		{"org:initech", nil},
+	}
+
+	for _, tt := range tests {
+		matches, err := dir.Search(tt.query)
+		if err != nil {
+			t.Errorf("Expected no error for %q, got %v", tt.query, err)
+			continue
+		}
+
+		var names []string
+		for _, contact := range matches {
+			names = append(names, contact.Name)
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("Expected %v for %q, got %v", tt.want, tt.query, names)
+		}
+	}
+}
+
+func TestDirectory_Search_Errors(t *testing.T) {
+	dir := newQueryDirectory(t)
+
+	tests := []struct {
+		query   string
+		pos     int
+		message string
+	}{
+		{"", 0, "query is empty"},
+		{"nmae:alice", 0, "unknown field 'nmae', did you mean 'name'?"},
+		{"name:", 5, "missing value after 'name:'"},
+		{`name:"alice`, 5, "missing closing quote"},
+		{"(org:acme OR city:paris", 0, "missing ')' to close this '('"},
+		{"org:acme)", 8, "unexpected ')'"},
+		{"org:acme OR", 11, "missing term after OR"},
+		{"OR org:acme", 0, "missing term before OR"},
+		{"alice -", 6, "missing term after '-'"},
+		{"created:>yesterday", 8, "'yesterday' is not a date, use YYYY-MM-DD or an RFC 3339 time"},
+		{"created:2025-02-01..2025-01-01", 8, "range starts after it ends"},
+		{"name:>alice", 5, "field 'name' cannot be compared, only birthday and created can"},
+		{"phone:abc", 6, "phone value 'abc' has no digits"},
+	}
+
+	for _, tt := range tests {
+		_, err := dir.Search(tt.query)
+
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected a validation error for %q, got %v", tt.query, err)
+			continue
+		}
+
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("Expected a QueryError for %q, got %v", tt.query, err)
+			continue
+		}
+		if queryErr.Pos != tt.pos || queryErr.Message != tt.message {
+			t.Errorf("Expected %q at %d for %q, got %q at %d", tt.message, tt.pos, tt.query, queryErr.Message, queryErr.Pos)
+		}
+	}
+}
+
+// TestDirectory_Search_Accents covers values whose UTF-8 encoding holds the
+// bytes 0x85 and 0xA0, which are spaces when read as Latin-1: Å is C3 85, à is
+// C3 A0 and ễ is E1 BB 85.
+func TestDirectory_Search_Accents(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+	for _, contact := range []domain.Contact{
+		{Name: "Åsa Berg", Organization: "Café à Paris", Tags: []string{"x"}},
+		{Name: "Åsa Lind"},
+		{Name: "Nguyễn Văn An", Organization: "Globex"},
+	} {
+		contact.Phones = []domain.Phone{{Number: "+12025550100"}}
+		if _, err := dir.CreateContact(contact); err != nil {
+			t.Fatalf("Failed to create %s: %v", contact.Name, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"name:Åsa", []string{"Åsa Berg", "Åsa Lind"}},
+		{"name:asa", []string{"Åsa Berg", "Åsa Lind"}},
+		{"name:nguyễn", []string{"Nguyễn Văn An"}},
+		{"org:à", []string{"Åsa Berg"}},
+		{`org:"café à"`, []string{"Åsa Berg"}},
+		{"Åsa -tag:x", []string{"Åsa Lind"}},
+		{"nguyễn OR org:à", []string{"Nguyễn Văn An", "Åsa Berg"}},
+		{"(name:Åsa) -name:berg", []string{"Åsa Lind"}},
+	}
+
+	for _, tt := range tests {
+		matches, err := dir.Search(tt.query)
+		if err != nil {
+			t.Errorf("Expected no error for %q, got %v", tt.query, err)
+			continue
+		}
+		if names := contactNames(matches); !slices.Equal(names, tt.want) {
+			t.Errorf("Expected %v for %q, got %v", tt.want, tt.query, names)
+		}
+	}
+
+	_, err := dir.Search("name:Åsa nöm:x")
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) || queryErr.Pos != 9 {
+		t.Errorf("Expected an error at rune 9, got %v", err)
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"+33*", "+33612345678", true},
+		{"+33*", "+44612345678", false},
+		{"*@acme.com", "alice@acme.com", true},
+		{"a*b*c", "axxbyyc", true},
+		{"ab*ba", "aba", false},
+		{"exact", "exact", true},
+		{"exact", "exactly", false},
+	}
+
+	for _, tt := range tests {
+		if got := wildcardMatch(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("Expected wildcardMatch(%q, %q) = %v, got %v", tt.pattern, tt.value, tt.want, got)
+		}
+	}
+}
+
+func TestQueryError_Message(t *testing.T) {
+	_, err := newQueryDirectory(t).Search("name:alice foo:bar")
+	if err == nil || !strings.Contains(err.Error(), "position 12: unknown field 'foo'") {
+		t.Errorf("Expected the position in the message, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/search"
+	"github.com/LaulauChau/go-directory/internal/storage"
+)
+
+// errSnapshotsUnsupported is returned by the snapshot methods when the
+// storage cannot keep snapshots.
+var errSnapshotsUnsupported = fmt.Errorf("this storage cannot keep snapshots: %w", errors.ErrUnsupported)
+
+// snapshotTimeFormat names the snapshots created without a name.
+const snapshotTimeFormat = "20060102-150405"
+
+// maxSnapshotName is the longest snapshot name accepted.
+const maxSnapshotName = 64
+
+// SnapshotDiff lists how the contacts changed since a snapshot was taken.
+// Contacts in the trash are left out on both sides.
+type SnapshotDiff struct {
+	Name      string
+	CreatedAt time.Time
+
+	// Added were created or restored since, Removed were deleted since.
+	Added   []domain.Contact
+	Removed []domain.Contact
+	Changed []ContactChange
+}
+
+// ContactChange is a contact as it was in a snapshot and as it is now.
+type ContactChange struct {
+	Before  domain.Contact
+	After   domain.Contact
+	Changes []domain.FieldChange
+}
+
+// Empty reports whether nothing changed since the snapshot.
+func (d SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// CreateSnapshot stores a copy of every contact, those in the trash
+// included, and every group under name. An empty name is replaced by the
+// current time.
+func (d *Directory) CreateSnapshot(name string) (domain.Snapshot, error) {
+	if d.snapshotStore == nil {
+		return domain.Snapshot{}, errSnapshotsUnsupported
+	}
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	defer unlock()
+
+	return d.createSnapshot(name)
+}
+
+// Snapshots returns the stored snapshots, newest first.
+func (d *Directory) Snapshots() ([]domain.Snapshot, error) {
+	if d.snapshotStore == nil {
+		return nil, errSnapshotsUnsupported
+	}
+
+	snapshots, err := d.snapshotStore.ListSnapshots()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	slices.Reverse(snapshots)
+	return snapshots, nil
+}
+
+// DiffSnapshot compares the contacts in the snapshot with the current ones.
+func (d *Directory) DiffSnapshot(name string) (SnapshotDiff, error) {
+	snapshot, err := d.loadSnapshot(name)
+	if err != nil {
+		return SnapshotDiff{}, err
+	}
+
+	d.refresh()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	diff := SnapshotDiff{Name: snapshot.Name, CreatedAt: snapshot.CreatedAt}
+	current := make(map[string]domain.Contact, len(d.contacts))
+	for _, contact := range d.contacts {
+		current[contact.ID] = contact
+	}
+	for _, before := range snapshot.Live() {
+		after, ok := current[before.ID]
+		delete(current, before.ID)
+
+		if !ok {
+			diff.Removed = append(diff.Removed, before)
+		} else if changes := domain.CompareContacts(before, after); len(changes) > 0 {
+			diff.Changed = append(diff.Changed, ContactChange{Before: before, After: after.Clone(), Changes: changes})
+		}
+	}
+	for _, contact := range d.contacts {
+		if _, added := current[contact.ID]; added {
+			diff.Added = append(diff.Added, contact.Clone())
+		}
+	}
+	return diff, nil
+}
+
+// RestoreSnapshot puts every contact and group back as they were in the
+// snapshot, trash included. The current state is first saved in a snapshot
+// of its own, which is returned so the restore can be undone.
+func (d *Directory) RestoreSnapshot(name string) (domain.Snapshot, error) {
+	snapshot, err := d.loadSnapshot(name)
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	defer unlock()
+
+	backup, err := d.createSnapshot("before-restore-" + d.now().UTC().Format(snapshotTimeFormat))
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+
+	live := snapshot.Live()
+	var trash []domain.Contact
+	for _, contact := range snapshot.Contacts {
+		if !contact.DeletedAt.IsZero() {
+			trash = append(trash, contact)
+		}
+	}
+
+	err = d.commit(live, func(ctx context.Context) error {
+		// Groups go first: if saving the contacts then fails, no contact
+		// refers to a group that is not there.
+		if d.groupStore != nil {
+			if err := d.groupStore.SaveGroups(snapshot.Groups); err != nil {
+				return err
+			}
+		}
+		return d.storage.Save(snapshot.Contacts)
+	})
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	d.trash = trash
+	if d.groupStore != nil {
+		d.groups = snapshot.Groups
+	}
+	d.index = search.New(live)
+
+	return backup, nil
+}
+
+// createSnapshot stores the current state. Callers must hold the write lock.
+func (d *Directory) createSnapshot(name string) (domain.Snapshot, error) {
+	now := d.now().UTC()
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = now.Format(snapshotTimeFormat)
+	}
+	if err := validateSnapshotName(name); err != nil {
+		return domain.Snapshot{}, err
+	}
+
+	snapshot := domain.Snapshot{
+		Name:      name,
+		CreatedAt: now,
+		Actor:     d.actor,
+		Contacts:  cloneContacts(slices.Concat(d.contacts, d.trash)),
+		Groups:    slices.Clone(d.groups),
+	}
+	if err := d.snapshotStore.SaveSnapshot(snapshot); err != nil {
+		if errors.Is(err, storage.ErrSnapshotExists) {
+			return domain.Snapshot{}, newError(ErrAlreadyExists, "snapshot '%s' already exists", name)
+		}
+		return domain.Snapshot{}, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+func (d *Directory) loadSnapshot(name string) (domain.Snapshot, error) {
+	if d.snapshotStore == nil {
+		return domain.Snapshot{}, errSnapshotsUnsupported
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return domain.Snapshot{}, invalidField("name", "snapshot name is required", nil)
+	}
+	if err := validateSnapshotName(name); err != nil {
+		return domain.Snapshot{}, err
+	}
+
+	snapshot, err := d.snapshotStore.LoadSnapshot(name)
+	if errors.Is(err, storage.ErrSnapshotNotFound) {
+		return domain.Snapshot{}, newError(ErrNotFound, "snapshot '%s' not found", name)
+	}
+	if err != nil {
+		return domain.Snapshot{}, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// validateSnapshotName accepts names usable as file names everywhere:
+// letters, digits, dots, dashes and underscores, not starting with a dot.
+func validateSnapshotName(name string) error {
+	valid := len(name) <= maxSnapshotName && !strings.HasPrefix(name, ".") &&
+		!strings.ContainsFunc(name, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._-", r))
+		})
+	if !valid {
+		return invalidField("name", fmt.Sprintf("snapshot name must be at most %d letters, digits, '.', '-' or '_', not starting with '.'", maxSnapshotName), nil)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/storage"
+)
+
+func newSnapshotDirectory(t *testing.T) *Directory {
+	dir, err := NewDirectory(storage.NewJSONStorage(filepath.Join(t.TempDir(), "contacts.json")))
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	return dir
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	dir := newSnapshotDirectory(t)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	dir.now = func() time.Time { return now }
+	dir.SetActor("alice")
+
+	john, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101"))
+	dir.DeleteContact(john.ID)
+
+	named, err := dir.CreateSnapshot("before-import")
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if len(named.Contacts) != 2 || named.Actor != "alice" || !named.CreatedAt.Equal(now) {
+		t.Errorf("Expected both contacts, trash included, got %+v", named)
+	}
+
+	now = now.Add(time.Hour)
+	stamped, err := dir.CreateSnapshot(" ")
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if stamped.Name != "20250601-130000" {
+		t.Errorf("Expected the snapshot to be named after the time, got %s", stamped.Name)
+	}
+
+	if _, err := dir.CreateSnapshot("before-import"); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	for _, name := range []string{"../contacts", ".hidden", "with space"} {
+		if _, err := dir.CreateSnapshot(name); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected ErrValidation for %q, got %v", name, err)
+		}
+	}
+
+	snapshots, err := dir.Snapshots()
+	if err != nil {
+		t.Fatalf("Failed to list snapshots: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Name != stamped.Name || snapshots[1].Name != "before-import" {
+		t.Errorf("Expected both snapshots newest first, got %+v", snapshots)
+	}
+
+	unsupported, _ := NewDirectory(newMockStorage())
+	if _, err := unsupported.CreateSnapshot("backup"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestDiffSnapshot(t *testing.T) {
+	dir := newSnapshotDirectory(t)
+
+	john, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	jane, _ := dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101"))
+	dir.CreateContact(domain.NewContact("Ann Lee", "+12025550102"))
+	dir.CreateSnapshot("before")
+
+	diff, err := dir.DiffSnapshot("before")
+	if err != nil {
+		t.Fatalf("Failed to diff snapshot: %v", err)
+	}
+	if !diff.Empty() {
+		t.Errorf("Expected no changes, got %+v", diff)
+	}
+
+	renamed := john
+	renamed.Name = "Johnny Doe"
+	dir.UpdateContact(renamed)
+	dir.DeleteContact(jane.ID)
+	dir.CreateContact(domain.NewContact("Bob Roe", "+12025550103"))
+
+	diff, err = dir.DiffSnapshot("before")
+	if err != nil {
+		t.Fatalf("Failed to diff snapshot: %v", err)
+	}
+	if names := contactNames(diff.Added); !slices.Equal(names, []string{"Bob Roe"}) {
+		t.Errorf("Expected Bob Roe added, got %v", names)
+	}
+	if names := contactNames(diff.Removed); !slices.Equal(names, []string{"Jane Doe"}) {
+		t.Errorf("Expected Jane Doe removed, got %v", names)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Before.Name != "John Doe" || diff.Changed[0].After.Name != "Johnny Doe" ||
+		diff.Changed[0].Changes[0] != (domain.FieldChange{Field: "name", Before: "John Doe", After: "Johnny Doe"}) {
+		t.Errorf("Expected John Doe renamed, got %+v", diff.Changed)
+	}
+
+	if _, err := dir.DiffSnapshot("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := dir.DiffSnapshot(""); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrValidation, got %v", err)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "contacts.json")
+	dir, _ := NewDirectory(storage.NewJSONStorage(filePath))
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	dir.now = func() time.Time { return now }
+
+	group, _ := dir.CreateGroup("Family")
+	contact := domain.NewContact("John Doe", "+12025550100")
+	contact.Groups = []string{group.ID}
+	john, _ := dir.CreateContact(contact)
+	jane, _ := dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101"))
+	dir.DeleteContact(jane.ID)
+	dir.CreateSnapshot("before")
+
+	dir.DeleteGroup(group.ID)
+	dir.DeleteContact(john.ID)
+	dir.EmptyTrash()
+	dir.CreateContact(domain.NewContact("Bob Roe", "+12025550103"))
+
+	backup, err := dir.RestoreSnapshot("before")
+	if err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+	if backup.Name != "before-restore-20250601-120000" || len(backup.Contacts) != 1 {
+		t.Errorf("Expected the state before the restore to be kept, got %+v", backup)
+	}
+
+	check := func(dir *Directory) {
+		t.Helper()
+		contacts := dir.ListContacts()
+		if names := contactNames(contacts); !slices.Equal(names, []string{"John Doe"}) {
+			t.Fatalf("Expected John Doe back, got %v", names)
+		}
+		if !slices.Equal(contacts[0].Groups, []string{group.ID}) {
+			t.Errorf("Expected John Doe back in Family, got %v", contacts[0].Groups)
+		}
+		if groups := dir.ListGroups(); len(groups) != 1 || groups[0].Name != "Family" {
+			t.Errorf("Expected Family back, got %+v", groups)
+		}
+		if trash := dir.Trash(); len(trash) != 1 || trash[0].ID != jane.ID {
+			t.Errorf("Expected Jane Doe back in the trash, got %v", contactNames(trash))
+		}
+		if matches := dir.SearchContacts("bob"); len(matches) != 0 {
+			t.Errorf("Expected Bob Roe to be gone, got %v", contactNames(matches))
+		}
+	}
+	check(dir)
+
+	reopened, err := NewDirectory(storage.NewJSONStorage(filePath))
+	if err != nil {
+		t.Fatalf("Failed to reopen directory: %v", err)
+	}
+	check(reopened)
+
+	now = now.Add(time.Minute)
+	if _, err := dir.RestoreSnapshot(backup.Name); err != nil {
+		t.Fatalf("Failed to undo the restore: %v", err)
+	}
+	if names := contactNames(dir.ListContacts()); !slices.Equal(names, []string{"Bob Roe"}) {
+		t.Errorf("Expected the restore to be undone, got %v", names)
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+// DefaultTrashRetention is how long deleted contacts stay in the trash
+// unless SetTrashRetention says otherwise.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// SetTrashRetention sets how long deleted contacts stay in the trash before
+// PurgeExpired removes them for good. Zero keeps them until they are purged
+// by hand.
+func (d *Directory) SetTrashRetention(retention time.Duration) error {
+	if retention < 0 {
+		return invalidField("retention", "trash retention cannot be negative", nil)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.retention = retention
+	return nil
+}
+
+// TrashRetention returns how long deleted contacts stay in the trash, zero
+// when they stay until purged by hand.
+func (d *Directory) TrashRetention() time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.retention
+}
+
+// Trash returns the deleted contacts, most recently deleted first.
+func (d *Directory) Trash() []domain.Contact {
+	d.refresh()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	trash := cloneContacts(d.trash)
+	slices.SortStableFunc(trash, func(a, b domain.Contact) int {
+		return b.DeletedAt.Compare(a.DeletedAt)
+	})
+	return trash
+}
+
+// FindInTrash returns the deleted contact whose name matches exactly,
+// ignoring case. Like FindByName, it fails when several deleted contacts
+// share that name.
+func (d *Directory) FindInTrash(name string) (*domain.Contact, error) {
+	name = strings.TrimSpace(name)
+
+	d.refresh()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var found *domain.Contact
+	for _, contact := range d.trash {
+		if !strings.EqualFold(contact.Name, name) {
+			continue
+		}
+		if found != nil {
+			return nil, newError(ErrConflict, "several deleted contacts are named '%s', use their id instead", name)
+		}
+		contact = contact.Clone()
+		found = &contact
+	}
+
+	if found == nil {
+		return nil, newError(ErrNotFound, "no deleted contact named '%s' in the trash", name)
+	}
+	return found, nil
+}
+
+// RestoreContact takes the contact out of the trash and returns it as
+// stored. Groups deleted in the meantime are dropped from it. It fails when
+// a contact with the same name and phone was created since.
+func (d *Directory) RestoreContact(id string) (domain.Contact, error) {
+	id = strings.TrimSpace(id)
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return domain.Contact{}, err
+	}
+	defer unlock()
+
+	i := d.trashIndex(id)
+	if i < 0 {
+		return domain.Contact{}, newError(ErrNotFound, "contact with id '%s' not found in the trash", id)
+	}
+
+	contact := d.trash[i].Clone()
+	contact.DeletedAt = time.Time{}
+	contact.Groups = slices.DeleteFunc(contact.Groups, func(group string) bool {
+		return !slices.ContainsFunc(d.groups, func(g domain.Group) bool { return g.ID == group })
+	})
+	if d.contactExists(contact.Name, contact.PrimaryPhone()) {
+		return domain.Contact{}, newError(ErrAlreadyExists, "contact '%s' with phone '%s' already exists", contact.Name, contact.PrimaryPhone())
+	}
+
+	updated := append(cloneContacts(d.contacts), contact)
+	err = d.commit(updated, func(ctx context.Context) error {
+		return d.records.Update(ctx, contact)
+	})
+	if err != nil {
+		return domain.Contact{}, err
+	}
+	d.trash = slices.Delete(slices.Clone(d.trash), i, i+1)
+	d.index.Add(contact)
+
+	return contact.Clone(), nil
+}
+
+// PurgeContact removes the contact from the trash for good.
+func (d *Directory) PurgeContact(id string) error {
+	id = strings.TrimSpace(id)
+
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := d.trashIndex(id)
+	if i < 0 {
+		return newError(ErrNotFound, "contact with id '%s' not found in the trash", id)
+	}
+
+	entries := d.purgeEntries(d.trash[i : i+1])
+	err = d.commit(d.contacts, func(ctx context.Context) error {
+		return d.records.Delete(ctx, id)
+	})
+	if err != nil {
+		return err
+	}
+	d.trash = slices.Delete(slices.Clone(d.trash), i, i+1)
+	d.appendAudit(entries)
+
+	return nil
+}
+
+// EmptyTrash removes every deleted contact for good and returns how many
+// there were.
+func (d *Directory) EmptyTrash() (int, error) {
+	return d.purge(func(domain.Contact) bool { return true })
+}
+
+// PurgeExpired removes for good the contacts deleted longer ago than the
+// trash retention, and returns how many there were.
+func (d *Directory) PurgeExpired() (int, error) {
+	retention := d.TrashRetention()
+	if retention == 0 {
+		return 0, nil
+	}
+
+	now := d.now()
+	return d.purge(func(contact domain.Contact) bool {
+		return !now.Before(contact.DeletedAt.Add(retention))
+	})
+}
+
+// purge removes the deleted contacts matching expired in a single write.
+func (d *Directory) purge(expired func(domain.Contact) bool) (int, error) {
+	unlock, err := d.lockForWrite()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	var purged, kept []domain.Contact
+	for _, contact := range d.trash {
+		if expired(contact) {
+			purged = append(purged, contact)
+		} else {
+			kept = append(kept, contact)
+		}
+	}
+	if len(purged) == 0 {
+		return 0, nil
+	}
+
+	entries := d.purgeEntries(purged)
+	err = d.commit(d.contacts, func(ctx context.Context) error {
+		return d.storage.Save(slices.Concat(d.contacts, kept))
+	})
+	if err != nil {
+		return 0, err
+	}
+	d.trash = kept
+	d.appendAudit(entries)
+
+	return len(purged), nil
+}
+
+// trashIndex finds a deleted contact by ID. Callers must hold the lock.
+func (d *Directory) trashIndex(id string) int {
+	return slices.IndexFunc(d.trash, func(c domain.Contact) bool { return c.ID == id })
+}
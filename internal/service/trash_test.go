@@ -0,0 +1,250 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/storage"
+)
+
+func TestDeleteContact_MovesToTrash(t *testing.T) {
+	store := &auditStorage{}
+	dir, _ := NewDirectory(store)
+
+	john, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101"))
+
+	if err := dir.DeleteContact(john.ID); err != nil {
+		t.Fatalf("Failed to delete contact: %v", err)
+	}
+
+	if names := contactNames(dir.ListContacts()); !slices.Equal(names, []string{"Jane Doe"}) {
+		t.Errorf("Expected the deleted contact to be hidden, got %v", names)
+	}
+	if matches := dir.SearchContacts("john"); len(matches) != 0 {
+		t.Errorf("Expected search to skip the trash, got %v", contactNames(matches))
+	}
+	if _, err := dir.GetContact(john.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if len(store.contacts) != 2 {
+		t.Errorf("Expected the deleted contact to stay stored, got %d contacts", len(store.contacts))
+	}
+
+	trash := dir.Trash()
+	if len(trash) != 1 || trash[0].ID != john.ID || trash[0].DeletedAt.IsZero() {
+		t.Fatalf("Expected John Doe in the trash with a deletion time, got %+v", trash)
+	}
+
+	restored, err := dir.RestoreContact(john.ID)
+	if err != nil {
+		t.Fatalf("Failed to restore contact: %v", err)
+	}
+	if !restored.DeletedAt.IsZero() || restored.PrimaryPhone() != "+12025550100" {
+		t.Errorf("Expected the contact as it was, got %+v", restored)
+	}
+	if matches := dir.SearchContacts("john"); len(matches) != 1 {
+		t.Errorf("Expected the restored contact to be found, got %v", contactNames(matches))
+	}
+	if len(dir.Trash()) != 0 {
+		t.Errorf("Expected an empty trash, got %v", contactNames(dir.Trash()))
+	}
+
+	want := []string{"create John Doe", "create Jane Doe", "delete John Doe", "restore John Doe"}
+	if got := auditActions(store.entries); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestRestoreContact(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+
+	if _, err := dir.RestoreContact("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	t.Run("DeletedGroup", func(t *testing.T) {
+		group, _ := dir.CreateGroup("Family")
+		contact := domain.NewContact("Ann Lee", "+12025550102")
+		contact.Groups = []string{group.ID}
+		ann, _ := dir.CreateContact(contact)
+		dir.DeleteContact(ann.ID)
+		dir.DeleteGroup(group.ID)
+
+		restored, err := dir.RestoreContact(ann.ID)
+		if err != nil {
+			t.Fatalf("Failed to restore contact: %v", err)
+		}
+		if len(restored.Groups) != 0 {
+			t.Errorf("Expected the deleted group to be dropped, got %v", restored.Groups)
+		}
+	})
+
+	t.Run("Recreated", func(t *testing.T) {
+		bob, _ := dir.CreateContact(domain.NewContact("Bob Roe", "+12025550103"))
+		dir.DeleteContact(bob.ID)
+		dir.CreateContact(domain.NewContact("Bob Roe", "+12025550103"))
+
+		if _, err := dir.RestoreContact(bob.ID); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("Expected ErrAlreadyExists, got %v", err)
+		}
+		if _, err := dir.FindInTrash("bob roe"); err != nil {
+			t.Errorf("Expected the contact to stay in the trash, got %v", err)
+		}
+	})
+}
+
+func TestFindInTrash(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+
+	first, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	second, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550101"))
+	dir.DeleteContact(first.ID)
+
+	found, err := dir.FindInTrash("john doe")
+	if err != nil || found.ID != first.ID {
+		t.Fatalf("Expected the deleted John Doe, got %v, %v", found, err)
+	}
+
+	dir.DeleteContact(second.ID)
+	if _, err := dir.FindInTrash("John Doe"); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	if _, err := dir.FindInTrash("Jane Doe"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	store := &auditStorage{}
+	dir, _ := NewDirectory(store)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	dir.now = func() time.Time { return now }
+
+	if err := dir.SetTrashRetention(-time.Hour); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrValidation, got %v", err)
+	}
+	dir.SetTrashRetention(7 * 24 * time.Hour)
+
+	old, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	recent, _ := dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101"))
+	dir.CreateContact(domain.NewContact("Ann Lee", "+12025550102"))
+	dir.DeleteContact(old.ID)
+	now = now.Add(5 * 24 * time.Hour)
+	dir.DeleteContact(recent.ID)
+	now = now.Add(2 * 24 * time.Hour)
+
+	purged, err := dir.PurgeExpired()
+	if err != nil {
+		t.Fatalf("Failed to purge the trash: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 contact purged, got %d", purged)
+	}
+	if names := contactNames(dir.Trash()); !slices.Equal(names, []string{"Jane Doe"}) {
+		t.Errorf("Expected Jane Doe left in the trash, got %v", names)
+	}
+	if len(store.contacts) != 2 {
+		t.Errorf("Expected 2 contacts stored, got %d", len(store.contacts))
+	}
+	if last := store.entries[len(store.entries)-1]; last.Action != domain.AuditPurge || last.ContactID != old.ID {
+		t.Errorf("Expected the purge to be recorded, got %+v", last)
+	}
+
+	dir.SetTrashRetention(0)
+	now = now.Add(365 * 24 * time.Hour)
+	if purged, _ := dir.PurgeExpired(); purged != 0 {
+		t.Errorf("Expected no retention to keep the trash, got %d purged", purged)
+	}
+}
+
+func TestPurgeContact(t *testing.T) {
+	dir, _ := NewDirectory(newMockStorage())
+
+	john, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	jane, _ := dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101"))
+	ann, _ := dir.CreateContact(domain.NewContact("Ann Lee", "+12025550102"))
+
+	if err := dir.PurgeContact(john.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected only the trash to be purged, got %v", err)
+	}
+
+	dir.DeleteContacts(john.ID, jane.ID, ann.ID)
+	if err := dir.PurgeContact(john.ID); err != nil {
+		t.Fatalf("Failed to purge contact: %v", err)
+	}
+	if _, err := dir.RestoreContact(john.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a purged contact to be gone, got %v", err)
+	}
+
+	emptied, err := dir.EmptyTrash()
+	if err != nil || emptied != 2 {
+		t.Errorf("Expected 2 contacts purged, got %d, %v", emptied, err)
+	}
+	if len(dir.Trash()) != 0 {
+		t.Errorf("Expected an empty trash, got %v", contactNames(dir.Trash()))
+	}
+}
+
+func TestTrash_Persisted(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "contacts.json")
+	dir, _ := NewDirectory(storage.NewJSONStorage(filePath))
+
+	john, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	dir.CreateContact(domain.NewContact("Jane Doe", "+12025550101"))
+	dir.DeleteContact(john.ID)
+
+	reopened, err := NewDirectory(storage.NewJSONStorage(filePath))
+	if err != nil {
+		t.Fatalf("Failed to reopen directory: %v", err)
+	}
+	if names := contactNames(reopened.ListContacts()); !slices.Equal(names, []string{"Jane Doe"}) {
+		t.Errorf("Expected Jane Doe listed, got %v", names)
+	}
+	if trash := reopened.Trash(); len(trash) != 1 || trash[0].ID != john.ID {
+		t.Errorf("Expected John Doe in the trash, got %v", contactNames(trash))
+	}
+}
+
+func TestWrites_IgnoreDeletedAt(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "contacts.json")
+	dir, _ := NewDirectory(storage.NewJSONStorage(filePath))
+	deletedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	contact := domain.NewContact("John Doe", "+12025550100")
+	contact.DeletedAt = deletedAt
+	john, err := dir.CreateContact(contact)
+	if err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+	if !john.DeletedAt.IsZero() {
+		t.Errorf("Expected DeletedAt to be cleared on create, got %v", john.DeletedAt)
+	}
+
+	john.Organization = "Acme"
+	john.DeletedAt = deletedAt
+	if err := dir.UpdateContact(john); err != nil {
+		t.Fatalf("Failed to update contact: %v", err)
+	}
+
+	imported := domain.NewContact("Jane Doe", "+12025550101")
+	imported.DeletedAt = deletedAt
+	if _, err := dir.ImportContacts([]domain.Contact{imported}, ImportOptions{}); err != nil {
+		t.Fatalf("Failed to import contacts: %v", err)
+	}
+
+	reopened, err := NewDirectory(storage.NewJSONStorage(filePath))
+	if err != nil {
+		t.Fatalf("Failed to reopen directory: %v", err)
+	}
+	if names := contactNames(reopened.ListContacts()); !slices.Equal(names, []string{"John Doe", "Jane Doe"}) {
+		t.Errorf("Expected both contacts to stay live, got %v", names)
+	}
+	if trash := reopened.Trash(); len(trash) != 0 {
+		t.Errorf("Expected an empty trash, got %v", contactNames(trash))
+	}
+}
@@ -1,15 +1,47 @@
 package storage
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/LaulauChau/go-directory/internal/domain"
 )
 
+const (
+	backupSuffix = ".bak"
+	lockSuffix   = ".lock"
+	auditSuffix  = ".audit.jsonl"
+
+	snapshotsSuffix = ".snapshots"
+)
+
+// document is the content of the JSON file.
+type document struct {
+	Contacts []domain.Contact `json:"contacts"`
+	Groups   []domain.Group   `json:"groups,omitempty"`
+}
+
 type JSONStorage struct {
 	filePath string
+
+	mu       sync.Mutex
+	lockFile *os.File
+
+	// version caches the hash Version last computed and the file it was
+	// computed for.
+	versionMu   sync.Mutex
+	versionFile os.FileInfo
+	version     string
 }
 
 func NewJSONStorage(filePath string) *JSONStorage {
@@ -18,39 +50,450 @@ func NewJSONStorage(filePath string) *JSONStorage {
 	}
 }
 
+// Load reads the contacts. Contacts stored before contacts had an ID are
+// given one under the lock, see LoadLocked.
 func (s *JSONStorage) Load() ([]domain.Contact, error) {
-	if _, err := os.Stat(s.filePath); os.IsNotExist(err) {
-		return make([]domain.Contact, 0), nil
+	doc, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(doc.Contacts, func(c domain.Contact) bool { return c.ID == "" }) {
+		return doc.Contacts, nil
 	}
 
-	data, err := os.ReadFile(s.filePath)
+	if err := s.Lock(); err != nil {
+		return nil, err
+	}
+	defer s.Unlock()
+	return s.LoadLocked()
+}
+
+// LoadLocked is Load for a caller holding the lock. It saves an ID for the
+// contacts stored before contacts had one; since the file is read under the
+// lock, processes opening the same file agree on the IDs: the first one
+// assigns them, the others read them.
+func (s *JSONStorage) LoadLocked() ([]domain.Contact, error) {
+	doc, err := s.load()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
 
-	if len(data) == 0 {
-		return make([]domain.Contact, 0), nil
+	if assignMissingIDs(doc.Contacts) {
+		if err := s.write(doc); err != nil {
+			return nil, fmt.Errorf("failed to save migrated contacts: %w", err)
+		}
 	}
 
-	var contacts []domain.Contact
-	err = json.Unmarshal(data, &contacts)
+	return doc.Contacts, nil
+}
+
+func (s *JSONStorage) LoadGroups() ([]domain.Group, error) {
+	doc, err := s.load()
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+		return nil, err
+	}
+	return doc.Groups, nil
+}
+
+func (s *JSONStorage) load() (document, error) {
+	doc, err := readDocument(s.filePath)
+	if err == nil {
+		return doc, nil
+	}
+
+	backupPath := s.filePath + backupSuffix
+	if _, statErr := os.Stat(backupPath); statErr != nil {
+		return document{}, err
 	}
 
-	return contacts, nil
+	backup, backupErr := readDocument(backupPath)
+	if backupErr != nil {
+		return document{}, err
+	}
+
+	log.Printf("warning: %s is unreadable (%v), loaded %d contact(s) from backup %s", s.filePath, err, len(backup.Contacts), backupPath)
+	return backup, nil
 }
 
+// Save replaces the contacts, keeping the groups stored with them.
 func (s *JSONStorage) Save(contacts []domain.Contact) error {
-	data, err := json.MarshalIndent(contacts, "", "  ")
+	doc, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	doc.Contacts = contacts
+	return s.write(doc)
+}
+
+// SaveGroups replaces the groups, keeping the contacts stored with them.
+func (s *JSONStorage) SaveGroups(groups []domain.Group) error {
+	doc, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	doc.Groups = groups
+	return s.write(doc)
+}
+
+func (s *JSONStorage) write(doc document) error {
+	if doc.Contacts == nil {
+		doc.Contacts = make([]domain.Contact, 0)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
 	}
 
-	err = os.WriteFile(s.filePath, data, 0600)
+	if err := s.backup(); err != nil {
+		return fmt.Errorf("failed to back up file: %w", err)
+	}
+
+	err = writeFileAtomic(s.filePath, data, 0600)
 	if err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
 	return nil
 }
+
+// AppendAudit appends entries to the audit log next to the file, one JSON
+// object per line. Lines already written are never rewritten.
+func (s *JSONStorage) AppendAudit(entries []domain.AuditEntry) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit entry: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	f, err := os.OpenFile(s.filePath+auditSuffix, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// LoadAudit reads the audit log next to the file. A line cut short by a
+// crash is skipped.
+func (s *JSONStorage) LoadAudit() ([]domain.AuditEntry, error) {
+	data, err := os.ReadFile(s.filePath + auditSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	var entries []domain.AuditEntry
+	for line := range bytes.Lines(data) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var entry domain.AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// SaveSnapshot writes the snapshot to its own file in the snapshots
+// directory next to the file. Snapshots are never overwritten.
+func (s *JSONStorage) SaveSnapshot(snapshot domain.Snapshot) error {
+	path, err := s.snapshotPath(snapshot.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%w: '%s'", ErrSnapshotExists, snapshot.Name)
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create snapshots directory: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+func (s *JSONStorage) LoadSnapshot(name string) (domain.Snapshot, error) {
+	path, err := s.snapshotPath(name)
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+
+	snapshot, err := readSnapshot(path)
+	if os.IsNotExist(err) {
+		return domain.Snapshot{}, fmt.Errorf("%w: '%s'", ErrSnapshotNotFound, name)
+	}
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// ListSnapshots reads every snapshot in the snapshots directory, oldest
+// first. A file that cannot be read is skipped with a warning.
+func (s *JSONStorage) ListSnapshots() ([]domain.Snapshot, error) {
+	dir := s.filePath + snapshotsSuffix
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots directory: %w", err)
+	}
+
+	var snapshots []domain.Snapshot
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		snapshot, err := readSnapshot(filepath.Join(dir, file.Name()))
+		if err != nil {
+			log.Printf("warning: skipping snapshot %s: %v", file.Name(), err)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	slices.SortStableFunc(snapshots, func(a, b domain.Snapshot) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// snapshotPath returns the file holding the snapshot named name, which must
+// be usable as a file name.
+func (s *JSONStorage) snapshotPath(name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid snapshot name '%s'", name)
+	}
+	return filepath.Join(s.filePath+snapshotsSuffix, name+".json"), nil
+}
+
+func readSnapshot(path string) (domain.Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+
+	var snapshot domain.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return domain.Snapshot{}, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+func (s *JSONStorage) Lock() error {
+	s.mu.Lock()
+
+	f, err := os.OpenFile(s.filePath+lockSuffix, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		s.mu.Unlock()
+		return fmt.Errorf("failed to lock file: %w", err)
+	}
+
+	s.lockFile = f
+	return nil
+}
+
+// Unlock releases the lock taken by Lock. s.mu is held from Lock to Unlock,
+// and guards s.lockFile.
+func (s *JSONStorage) Unlock() error {
+	if s.mu.TryLock() {
+		s.mu.Unlock()
+		return errors.New("storage is not locked")
+	}
+	defer s.mu.Unlock()
+
+	f := s.lockFile
+	s.lockFile = nil
+
+	err := unlockFile(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unlock file: %w", err)
+	}
+
+	return nil
+}
+
+// Version returns a hash of the file content, or an empty string when the
+// file does not exist yet. The file is only hashed again when it was replaced
+// or its size or modification time changed, so checking for changes made by
+// other processes does not read the whole file on every call.
+func (s *JSONStorage) Version() (string, error) {
+	info, err := os.Stat(s.filePath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	s.versionMu.Lock()
+	defer s.versionMu.Unlock()
+
+	if cached := s.versionFile; cached != nil && os.SameFile(cached, info) &&
+		cached.Size() == info.Size() && cached.ModTime().Equal(info.ModTime()) {
+		return s.version, nil
+	}
+
+	data, err := os.ReadFile(s.filePath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	s.versionFile, s.version = info, hex.EncodeToString(sum[:])
+	return s.version, nil
+}
+
+// backup copies the current file to its .bak sibling. A corrupt file is not
+// copied so that it never replaces the last good backup.
+func (s *JSONStorage) backup() error {
+	data, err := os.ReadFile(s.filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 || !json.Valid(data) {
+		return nil
+	}
+
+	return writeFileAtomic(s.filePath+backupSuffix, data, 0600)
+}
+
+// assignMissingIDs gives an ID to contacts saved before contacts had one and
+// reports whether any contact changed.
+func assignMissingIDs(contacts []domain.Contact) bool {
+	changed := false
+	for i := range contacts {
+		if contacts[i].ID == "" {
+			contacts[i].ID = domain.NewID()
+			changed = true
+		}
+	}
+	return changed
+}
+
+func readContacts(path string) ([]domain.Contact, error) {
+	doc, err := readDocument(path)
+	return doc.Contacts, err
+}
+
+// readDocument reads the file at path, which holds either a document or, when
+// written before groups existed, a bare array of contacts.
+func readDocument(path string) (document, error) {
+	empty := document{Contacts: make([]domain.Contact, 0)}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return empty, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return document{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return empty, nil
+	}
+
+	var doc document
+	if data[0] == '[' {
+		err = json.Unmarshal(data, &doc.Contacts)
+	} else {
+		err = json.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return document{}, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	if doc.Contacts == nil {
+		doc.Contacts = make([]domain.Contact, 0)
+	}
+	return doc, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory,
+// fsyncs it and renames it over path, so readers see either the old or the
+// new content but never a partial write.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes the rename to disk. Some platforms cannot fsync a
+// directory, and the rename has already succeeded, so this is best effort.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
@@ -3,7 +3,10 @@ package storage
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/LaulauChau/go-directory/internal/domain"
 )
@@ -85,8 +88,403 @@ func TestSaveAndLoad(t *testing.T) {
 		if contact.Name != loadedContacts[i].Name {
 			t.Errorf("Expected name %s, got %s", contact.Name, loadedContacts[i].Name)
 		}
-		if contact.Phone != loadedContacts[i].Phone {
-			t.Errorf("Expected phone %s, got %s", contact.Phone, loadedContacts[i].Phone)
+		if contact.PrimaryPhone() != loadedContacts[i].PrimaryPhone() {
+			t.Errorf("Expected phone %s, got %s", contact.PrimaryPhone(), loadedContacts[i].PrimaryPhone())
 		}
 	}
 }
+
+func TestSave_CreatesBackup(t *testing.T) {
+	filePath := createTempFile(t)
+	storage := NewJSONStorage(filePath)
+
+	first := []domain.Contact{domain.NewContact("John Doe", "1234567890")}
+	if err := storage.Save(first); err != nil {
+		t.Fatalf("Failed to save contacts: %v", err)
+	}
+
+	if _, err := os.Stat(filePath + backupSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup after first save, got %v", err)
+	}
+
+	second := append(first, domain.NewContact("Jane Smith", "0987654321"))
+	if err := storage.Save(second); err != nil {
+		t.Fatalf("Failed to save contacts: %v", err)
+	}
+
+	backup, err := readContacts(filePath + backupSuffix)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+
+	if len(backup) != 1 {
+		t.Errorf("Expected backup to hold previous version with 1 contact, got %d", len(backup))
+	}
+}
+
+func TestSave_LeavesNoTempFiles(t *testing.T) {
+	filePath := createTempFile(t)
+	storage := NewJSONStorage(filePath)
+
+	for i := 0; i < 3; i++ {
+		if err := storage.Save([]domain.Contact{domain.NewContact("John Doe", "1234567890")}); err != nil {
+			t.Fatalf("Failed to save contacts: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(filePath))
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+
+	for _, entry := range entries {
+		if entry.Name() != filepath.Base(filePath) && entry.Name() != filepath.Base(filePath)+backupSuffix {
+			t.Errorf("Unexpected file left behind: %s", entry.Name())
+		}
+	}
+}
+
+func TestLoad_CorruptFileFallsBackToBackup(t *testing.T) {
+	filePath := createTempFile(t)
+	storage := NewJSONStorage(filePath)
+
+	contacts := []domain.Contact{domain.NewContact("John Doe", "1234567890")}
+	if err := storage.Save(contacts); err != nil {
+		t.Fatalf("Failed to save contacts: %v", err)
+	}
+	if err := storage.Save(contacts); err != nil {
+		t.Fatalf("Failed to save contacts: %v", err)
+	}
+
+	if err := os.WriteFile(filePath, []byte(`[{"name": "John`), 0600); err != nil {
+		t.Fatalf("Failed to corrupt file: %v", err)
+	}
+
+	loaded, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Expected fallback to backup, got %v", err)
+	}
+
+	if len(loaded) != 1 || loaded[0].Name != "John Doe" {
+		t.Errorf("Expected contact from backup, got %v", loaded)
+	}
+
+	if err := storage.Save(loaded); err != nil {
+		t.Fatalf("Failed to save contacts: %v", err)
+	}
+
+	backup, err := readContacts(filePath + backupSuffix)
+	if err != nil {
+		t.Errorf("Expected corrupt file not to overwrite backup, got %v", err)
+	}
+	if len(backup) != 1 {
+		t.Errorf("Expected backup to keep 1 contact, got %d", len(backup))
+	}
+}
+
+func TestLoad_CorruptFileWithoutBackup(t *testing.T) {
+	filePath := createTempFile(t)
+
+	if err := os.WriteFile(filePath, []byte("not json"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	storage := NewJSONStorage(filePath)
+	if _, err := storage.Load(); err == nil {
+		t.Error("Expected error loading corrupt file without backup")
+	}
+}
+
+func TestVersion_ChangesOnSave(t *testing.T) {
+	filePath := createTempFile(t)
+	storage := NewJSONStorage(filePath)
+
+	version, err := storage.Version()
+	if err != nil {
+		t.Fatalf("Failed to get version: %v", err)
+	}
+	if version != "" {
+		t.Errorf("Expected empty version for non-existent file, got %s", version)
+	}
+
+	if err := storage.Save([]domain.Contact{domain.NewContact("John Doe", "1234567890")}); err != nil {
+		t.Fatalf("Failed to save contacts: %v", err)
+	}
+
+	saved, err := storage.Version()
+	if err != nil {
+		t.Fatalf("Failed to get version: %v", err)
+	}
+	if saved == version {
+		t.Error("Expected version to change after save")
+	}
+}
+
+func TestVersion_DetectsChangesFromOtherProcess(t *testing.T) {
+	filePath := createTempFile(t)
+	storage := NewJSONStorage(filePath)
+	if err := storage.Save([]domain.Contact{domain.NewContact("John Doe", "1234567890")}); err != nil {
+		t.Fatalf("Failed to save contacts: %v", err)
+	}
+
+	version, err := storage.Version()
+	if err != nil {
+		t.Fatalf("Failed to get version: %v", err)
+	}
+	if again, _ := storage.Version(); again != version {
+		t.Errorf("Expected the version of an unchanged file to stay %s, got %s", version, again)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+
+	// Another process replaces the file with content of the same size and
+	// the same modification time.
+	other := NewJSONStorage(filePath)
+	if err := other.Save([]domain.Contact{domain.NewContact("Jane Doe", "1234567890")}); err != nil {
+		t.Fatalf("Failed to save contacts: %v", err)
+	}
+	if err := os.Chtimes(filePath, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Failed to set file time: %v", err)
+	}
+	replaced, err := storage.Version()
+	if err != nil {
+		t.Fatalf("Failed to get version: %v", err)
+	}
+	if replaced == version {
+		t.Error("Expected version to change when the file is replaced")
+	}
+
+	// Another process rewrites the file in place.
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if err := os.WriteFile(filePath, append(data, '\n'), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if rewritten, _ := storage.Version(); rewritten == replaced {
+		t.Error("Expected version to change when the file is rewritten")
+	}
+}
+
+func TestJSONStorage(t *testing.T) {
+	testStorage(t, func(t *testing.T) Storage {
+		return NewJSONStorage(createTempFile(t))
+	})
+}
+
+func TestJSONStorage_Groups(t *testing.T) {
+	testGroupStore(t, func(t *testing.T) Storage {
+		return NewJSONStorage(createTempFile(t))
+	})
+}
+
+func TestJSONStorage_Audit(t *testing.T) {
+	testAuditStore(t, func(t *testing.T) Storage {
+		return NewJSONStorage(createTempFile(t))
+	})
+}
+
+func TestLoadAudit_SkipsTruncatedLine(t *testing.T) {
+	filePath := createTempFile(t)
+	storage := NewJSONStorage(filePath)
+
+	entry := domain.AuditEntry{Action: domain.AuditDelete, ContactID: "contact-1"}
+	if err := storage.AppendAudit([]domain.AuditEntry{entry}); err != nil {
+		t.Fatalf("Failed to append to the audit log: %v", err)
+	}
+	f, err := os.OpenFile(filePath+auditSuffix, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open the audit log: %v", err)
+	}
+	f.WriteString(`{"action":"create","contactId":"cont`)
+	f.Close()
+
+	entries, err := storage.LoadAudit()
+	if err != nil {
+		t.Fatalf("Failed to load the audit log: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ContactID != "contact-1" {
+		t.Errorf("Expected the complete entry only, got %+v", entries)
+	}
+}
+
+func TestJSONStorage_Snapshots(t *testing.T) {
+	testSnapshotStore(t, func(t *testing.T) Storage {
+		return NewJSONStorage(createTempFile(t))
+	})
+}
+
+func TestJSONStorage_Records(t *testing.T) {
+	testRecordStore(t, func(t *testing.T) RecordStore {
+		return Records(NewJSONStorage(createTempFile(t)))
+	})
+}
+
+func TestLoad_AssignsMissingIDs(t *testing.T) {
+	filePath := createTempFile(t)
+
+	legacy := `[{"name": "John Doe", "phone": "1234567890"}, {"name": "John Doe", "phone": "0987654321"}]`
+	if err := os.WriteFile(filePath, []byte(legacy), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	storage := NewJSONStorage(filePath)
+	contacts, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Failed to load contacts: %v", err)
+	}
+
+	if contacts[0].ID == "" || contacts[0].ID == contacts[1].ID {
+		t.Errorf("Expected distinct IDs, got '%s' and '%s'", contacts[0].ID, contacts[1].ID)
+	}
+
+	reloaded, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Failed to load contacts: %v", err)
+	}
+
+	if reloaded[0].ID != contacts[0].ID || reloaded[1].ID != contacts[1].ID {
+		t.Error("Expected assigned IDs to be persisted")
+	}
+}
+
+func TestLoad_AssignsMissingIDsOnce(t *testing.T) {
+	filePath := createTempFile(t)
+
+	legacy := `[{"name": "John Doe", "phone": "1234567890"}, {"name": "Jane Doe", "phone": "0987654321"}]`
+	if err := os.WriteFile(filePath, []byte(legacy), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// Each storage stands for a process opening the same legacy file.
+	results := make([][]domain.Contact, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			contacts, err := NewJSONStorage(filePath).Load()
+			if err != nil {
+				t.Errorf("Failed to load contacts: %v", err)
+			}
+			results[i] = contacts
+		}()
+	}
+	wg.Wait()
+
+	stored, err := NewJSONStorage(filePath).Load()
+	if err != nil {
+		t.Fatalf("Failed to load contacts: %v", err)
+	}
+	for _, contacts := range results {
+		if len(contacts) != 2 || contacts[0].ID != stored[0].ID || contacts[1].ID != stored[1].ID {
+			t.Errorf("Expected the IDs on disk, got %+v", contacts)
+		}
+	}
+}
+
+func TestLoad_AssignsMissingIDsWhileLocked(t *testing.T) {
+	filePath := createTempFile(t)
+
+	legacy := `[{"name": "John Doe", "phone": "1234567890"}]`
+	if err := os.WriteFile(filePath, []byte(legacy), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	storage := NewJSONStorage(filePath)
+	if err := storage.Lock(); err != nil {
+		t.Fatalf("Failed to lock storage: %v", err)
+	}
+
+	// Another goroutine loading meanwhile waits for the lock to migrate.
+	loaded := make(chan []domain.Contact)
+	go func() {
+		contacts, err := storage.Load()
+		if err != nil {
+			t.Errorf("Failed to load contacts: %v", err)
+		}
+		loaded <- contacts
+	}()
+
+	contacts, err := storage.LoadLocked()
+	if err != nil {
+		t.Fatalf("Failed to load contacts: %v", err)
+	}
+	if len(contacts) != 1 || contacts[0].ID == "" {
+		t.Errorf("Expected an ID to be assigned, got %+v", contacts)
+	}
+
+	select {
+	case <-loaded:
+		t.Error("Expected Load to wait for the lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := storage.Unlock(); err != nil {
+		t.Fatalf("Failed to unlock storage: %v", err)
+	}
+	if other := <-loaded; len(other) != 1 || other[0].ID != contacts[0].ID {
+		t.Errorf("Expected the ID assigned under the lock, got %+v", other)
+	}
+
+	if err := storage.Unlock(); err == nil {
+		t.Error("Expected an error unlocking a storage that is not locked")
+	}
+}
+
+func TestLoad_LegacyFlatFormat(t *testing.T) {
+	filePath := createTempFile(t)
+
+	legacy := `[{"id": "1", "name": "John Doe", "phone": "1234567890"}]`
+	if err := os.WriteFile(filePath, []byte(legacy), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	contacts, err := NewJSONStorage(filePath).Load()
+	if err != nil {
+		t.Fatalf("Failed to load contacts: %v", err)
+	}
+
+	contact := contacts[0]
+	if contact.FirstName != "John" || contact.LastName != "Doe" {
+		t.Errorf("Expected name split into 'John' and 'Doe', got '%s' and '%s'", contact.FirstName, contact.LastName)
+	}
+
+	expected := []domain.Phone{{Type: domain.PhoneMobile, Number: "1234567890"}}
+	if !reflect.DeepEqual(contact.Phones, expected) {
+		t.Errorf("Expected phones %v, got %v", expected, contact.Phones)
+	}
+}
+
+func TestLoad_ContactsArrayFormat(t *testing.T) {
+	filePath := createTempFile(t)
+
+	legacy := `[{"id": "1", "name": "John Doe", "phones": [{"type": "mobile", "number": "+11234567890"}]}]`
+	if err := os.WriteFile(filePath, []byte(legacy), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	storage := NewJSONStorage(filePath)
+	groups, err := storage.LoadGroups()
+	if err != nil {
+		t.Fatalf("Failed to load groups: %v", err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("Expected no groups in a file written before groups, got %v", groups)
+	}
+
+	if err := storage.SaveGroups([]domain.Group{{ID: "g1", Name: "Family"}}); err != nil {
+		t.Fatalf("Failed to save groups: %v", err)
+	}
+
+	contacts, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Failed to load contacts: %v", err)
+	}
+	if len(contacts) != 1 || contacts[0].Name != "John Doe" {
+		t.Errorf("Expected the contacts to survive the new format, got %v", contacts)
+	}
+}
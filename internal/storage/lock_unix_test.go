@@ -0,0 +1,46 @@
+//go:build unix
+
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLock_SerializesAccess(t *testing.T) {
+	filePath := createTempFile(t)
+	first := NewJSONStorage(filePath)
+	second := NewJSONStorage(filePath)
+
+	if err := first.Lock(); err != nil {
+		t.Fatalf("Failed to lock storage: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if err := second.Lock(); err != nil {
+			t.Errorf("Failed to lock storage: %v", err)
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected second lock to block while the first is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := first.Unlock(); err != nil {
+		t.Fatalf("Failed to unlock storage: %v", err)
+	}
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected second lock to be acquired after unlock")
+	}
+
+	if err := second.Unlock(); err != nil {
+		t.Errorf("Failed to unlock storage: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Open returns the storage described by location, either
+// "sqlite://<path>" or "json://<path>". Paths may be relative, so
+// "sqlite:///var/lib/contacts.db" is absolute and "sqlite://contacts.db" is
+// not.
+func Open(location string) (Storage, error) {
+	scheme, path, ok := strings.Cut(location, "://")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("invalid store '%s', expected <scheme>://<path>", location)
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store path: %w", err)
+	}
+
+	switch scheme {
+	case "json":
+		return NewJSONStorage(path), nil
+	case "sqlite":
+		return NewSQLiteStorage(path)
+	default:
+		return nil, fmt.Errorf("unsupported store scheme '%s'", scheme)
+	}
+}
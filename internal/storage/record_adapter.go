@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+// Records returns s itself when it supports record operations, and otherwise
+// an adapter implementing them with a full Load and Save per call.
+func Records(s Storage) RecordStore {
+	if records, ok := s.(RecordStore); ok {
+		return records
+	}
+	return &recordAdapter{storage: s}
+}
+
+type recordAdapter struct {
+	storage Storage
+}
+
+func (a *recordAdapter) Get(ctx context.Context, id string) (domain.Contact, error) {
+	contacts, err := a.storage.Load()
+	if err != nil {
+		return domain.Contact{}, err
+	}
+
+	i := indexOf(contacts, id)
+	if i < 0 || !contacts[i].DeletedAt.IsZero() {
+		return domain.Contact{}, fmt.Errorf("%w: '%s'", ErrNotFound, id)
+	}
+
+	return contacts[i], nil
+}
+
+func (a *recordAdapter) Insert(ctx context.Context, contact domain.Contact) error {
+	contacts, err := a.storage.Load()
+	if err != nil {
+		return err
+	}
+
+	if indexOf(contacts, contact.ID) >= 0 {
+		return fmt.Errorf("%w: '%s'", ErrExists, contact.ID)
+	}
+
+	return a.storage.Save(append(slices.Clone(contacts), contact))
+}
+
+func (a *recordAdapter) Update(ctx context.Context, contact domain.Contact) error {
+	contacts, err := a.storage.Load()
+	if err != nil {
+		return err
+	}
+
+	i := indexOf(contacts, contact.ID)
+	if i < 0 {
+		return fmt.Errorf("%w: '%s'", ErrNotFound, contact.ID)
+	}
+
+	updated := slices.Clone(contacts)
+	updated[i] = contact
+	return a.storage.Save(updated)
+}
+
+func (a *recordAdapter) Delete(ctx context.Context, id string) error {
+	contacts, err := a.storage.Load()
+	if err != nil {
+		return err
+	}
+
+	i := indexOf(contacts, id)
+	if i < 0 {
+		return fmt.Errorf("%w: '%s'", ErrNotFound, id)
+	}
+
+	return a.storage.Save(slices.Delete(slices.Clone(contacts), i, i+1))
+}
+
+func (a *recordAdapter) Query(ctx context.Context, query Query) ([]domain.Contact, error) {
+	contacts, err := a.storage.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.ToLower(query.Name)
+	matches := make([]domain.Contact, 0)
+	for _, contact := range contacts {
+		if !contact.DeletedAt.IsZero() && !query.IncludeDeleted {
+			continue
+		}
+		if strings.Contains(strings.ToLower(contact.Name), name) {
+			matches = append(matches, contact)
+		}
+	}
+
+	return paginate(matches, query.Offset, query.Limit), nil
+}
+
+func indexOf(contacts []domain.Contact, id string) int {
+	return slices.IndexFunc(contacts, func(contact domain.Contact) bool {
+		return contact.ID == id
+	})
+}
+
+func paginate(contacts []domain.Contact, offset, limit int) []domain.Contact {
+	offset = max(offset, 0)
+	if offset >= len(contacts) {
+		return contacts[:0]
+	}
+	contacts = contacts[offset:]
+
+	if limit > 0 && limit < len(contacts) {
+		contacts = contacts[:limit]
+	}
+	return contacts
+}
@@ -0,0 +1,830 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteDriver is the database/sql driver name used to open SQLite databases.
+// The pure-Go modernc.org/sqlite driver, imported here, registers itself under
+// this name.
+const SQLiteDriver = "sqlite"
+
+var migrations = []string{
+	`CREATE TABLE contacts (
+		id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		name  TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		phone TEXT NOT NULL
+	);
+	CREATE INDEX idx_contacts_phone ON contacts (phone);`,
+
+	// Contacts are keyed by a generated UUID so that names no longer have to
+	// be unique. Existing rows keep their order and get a random v4 UUID.
+	`CREATE TABLE contacts_v2 (
+		seq   INTEGER PRIMARY KEY AUTOINCREMENT,
+		id    TEXT NOT NULL UNIQUE,
+		name  TEXT NOT NULL,
+		phone TEXT NOT NULL
+	);
+	INSERT INTO contacts_v2 (seq, id, name, phone)
+	SELECT id,
+		lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+		substr(lower(hex(randomblob(2))), 2) || '-' ||
+		substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' ||
+		lower(hex(randomblob(6))),
+		name, phone
+	FROM contacts;
+	DROP TABLE contacts;
+	ALTER TABLE contacts_v2 RENAME TO contacts;
+	CREATE INDEX idx_contacts_name ON contacts (name COLLATE NOCASE);
+	CREATE INDEX idx_contacts_phone ON contacts (phone);`,
+
+	// Contacts gain structured names and details, and may have several typed
+	// phones, emails and addresses, stored in child tables keyed by position.
+	`ALTER TABLE contacts ADD COLUMN first_name TEXT NOT NULL DEFAULT '';
+	ALTER TABLE contacts ADD COLUMN last_name TEXT NOT NULL DEFAULT '';
+	ALTER TABLE contacts ADD COLUMN organization TEXT NOT NULL DEFAULT '';
+	ALTER TABLE contacts ADD COLUMN job_title TEXT NOT NULL DEFAULT '';
+	ALTER TABLE contacts ADD COLUMN birthday TEXT NOT NULL DEFAULT '';
+	ALTER TABLE contacts ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+	CREATE TABLE contact_phones (
+		contact_id TEXT NOT NULL,
+		position   INTEGER NOT NULL,
+		type       TEXT NOT NULL,
+		number     TEXT NOT NULL,
+		PRIMARY KEY (contact_id, position)
+	);
+	CREATE INDEX idx_contact_phones_number ON contact_phones (number);
+	CREATE TABLE contact_emails (
+		contact_id TEXT NOT NULL,
+		position   INTEGER NOT NULL,
+		type       TEXT NOT NULL,
+		address    TEXT NOT NULL,
+		PRIMARY KEY (contact_id, position)
+	);
+	CREATE INDEX idx_contact_emails_address ON contact_emails (address COLLATE NOCASE);
+	CREATE TABLE contact_addresses (
+		contact_id  TEXT NOT NULL,
+		position    INTEGER NOT NULL,
+		type        TEXT NOT NULL,
+		street      TEXT NOT NULL,
+		city        TEXT NOT NULL,
+		region      TEXT NOT NULL,
+		postal_code TEXT NOT NULL,
+		country     TEXT NOT NULL,
+		PRIMARY KEY (contact_id, position)
+	);
+	INSERT INTO contact_phones (contact_id, position, type, number)
+	SELECT id, 0, 'mobile', phone FROM contacts WHERE phone <> '';
+	DROP INDEX idx_contacts_phone;
+	ALTER TABLE contacts DROP COLUMN phone;`,
+	// Phones keep the form shown to users next to their E.164 number.
+	`ALTER TABLE contact_phones ADD COLUMN display TEXT NOT NULL DEFAULT '';`,
+	// Creation time as RFC 3339, empty for contacts created before.
+	`ALTER TABLE contacts ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
+	CREATE INDEX idx_contacts_created_at ON contacts (created_at);`,
+	// Contacts gain tags, and named groups they can belong to.
+	`CREATE TABLE contact_tags (
+		contact_id TEXT NOT NULL,
+		position   INTEGER NOT NULL,
+		tag        TEXT NOT NULL,
+		PRIMARY KEY (contact_id, position)
+	);
+	CREATE INDEX idx_contact_tags_tag ON contact_tags (tag COLLATE NOCASE);
+	CREATE TABLE contact_groups (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		id         TEXT NOT NULL UNIQUE,
+		name       TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE group_members (
+		contact_id TEXT NOT NULL,
+		position   INTEGER NOT NULL,
+		group_id   TEXT NOT NULL,
+		PRIMARY KEY (contact_id, position)
+	);
+	CREATE INDEX idx_group_members_group_id ON group_members (group_id);`,
+	// Favorites, and when each contact was last looked up.
+	`ALTER TABLE contacts ADD COLUMN favorite INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE contacts ADD COLUMN last_accessed_at TEXT NOT NULL DEFAULT '';`,
+	// An append-only log of the changes made to contacts, holding the
+	// contact before and after each change as JSON.
+	`CREATE TABLE audit_log (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		time       TEXT NOT NULL,
+		actor      TEXT NOT NULL,
+		action     TEXT NOT NULL,
+		contact_id TEXT NOT NULL,
+		before     TEXT NOT NULL DEFAULT '',
+		after      TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX idx_audit_log_contact_id ON audit_log (contact_id);`,
+	// When a contact was moved to the trash, empty for the others.
+	`ALTER TABLE contacts ADD COLUMN deleted_at TEXT NOT NULL DEFAULT '';`,
+	// Named copies of the whole directory, each held as JSON.
+	`CREATE TABLE snapshots (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		name       TEXT NOT NULL UNIQUE,
+		created_at TEXT NOT NULL,
+		data       TEXT NOT NULL
+	);`,
+}
+
+const contactColumns = `id, name, first_name, last_name, organization, job_title, birthday, notes, created_at,
+	favorite, last_accessed_at, deleted_at`
+
+// detailBatchSize bounds the number of IDs bound in a single IN clause.
+const detailBatchSize = 500
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// A single connection keeps PRAGMA data_version meaningful and serializes
+	// writers within the process.
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStorage{db: db}
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStorage) Load() ([]domain.Contact, error) {
+	return queryContacts(context.Background(), s.db, `SELECT `+contactColumns+` FROM contacts ORDER BY seq`)
+}
+
+// Save brings the tables in line with contacts, touching only the contacts
+// that were added, changed or removed.
+func (s *SQLiteStorage) Save(contacts []domain.Contact) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := queryContacts(ctx, tx, `SELECT `+contactColumns+` FROM contacts`)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]domain.Contact, len(current))
+	for _, contact := range current {
+		existing[contact.ID] = contact
+	}
+
+	for _, contact := range contacts {
+		stored, ok := existing[contact.ID]
+		delete(existing, contact.ID)
+
+		switch {
+		case !ok:
+			err = insertContact(ctx, tx, contact)
+		case !reflect.DeepEqual(stored, contact):
+			err = updateContact(ctx, tx, contact)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save contact '%s': %w", contact.Name, err)
+		}
+	}
+
+	for id := range existing {
+		if err := deleteContact(ctx, tx, id); err != nil {
+			return fmt.Errorf("failed to delete contact '%s': %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) LoadGroups() ([]domain.Group, error) {
+	groups := make([]domain.Group, 0)
+	err := scanRows(context.Background(), s.db, `SELECT id, name, created_at FROM contact_groups ORDER BY seq`, nil,
+		func(rows *sql.Rows) error {
+			var (
+				group     domain.Group
+				createdAt string
+				err       error
+			)
+			if err := rows.Scan(&group.ID, &group.Name, &createdAt); err != nil {
+				return err
+			}
+			if group.CreatedAt, err = parseTime(createdAt); err != nil {
+				return err
+			}
+			groups = append(groups, group)
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load groups: %w", err)
+	}
+	return groups, nil
+}
+
+// SaveGroups replaces every group. Memberships are saved with the contacts.
+func (s *SQLiteStorage) SaveGroups(groups []domain.Group) error {
+	return s.inTx(context.Background(), func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM contact_groups`); err != nil {
+			return fmt.Errorf("failed to save groups: %w", err)
+		}
+		for _, group := range groups {
+			_, err := tx.Exec(`INSERT INTO contact_groups (id, name, created_at) VALUES (?, ?, ?)`,
+				group.ID, group.Name, formatTime(group.CreatedAt))
+			if err != nil {
+				return fmt.Errorf("failed to save group '%s': %w", group.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStorage) AppendAudit(entries []domain.AuditEntry) error {
+	return s.inTx(context.Background(), func(tx *sql.Tx) error {
+		for _, entry := range entries {
+			before, err := marshalAuditContact(entry.Before)
+			if err != nil {
+				return err
+			}
+			after, err := marshalAuditContact(entry.After)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(`INSERT INTO audit_log (time, actor, action, contact_id, before, after) VALUES (?, ?, ?, ?, ?, ?)`,
+				formatTime(entry.Time), entry.Actor, string(entry.Action), entry.ContactID, before, after)
+			if err != nil {
+				return fmt.Errorf("failed to append to audit log: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStorage) LoadAudit() ([]domain.AuditEntry, error) {
+	var entries []domain.AuditEntry
+	err := scanRows(context.Background(), s.db, `SELECT time, actor, action, contact_id, before, after FROM audit_log ORDER BY seq`, nil,
+		func(rows *sql.Rows) error {
+			var (
+				entry                     domain.AuditEntry
+				at, action, before, after string
+				err                       error
+			)
+			if err := rows.Scan(&at, &entry.Actor, &action, &entry.ContactID, &before, &after); err != nil {
+				return err
+			}
+			if entry.Time, err = parseTime(at); err != nil {
+				return err
+			}
+			entry.Action = domain.AuditAction(action)
+			if entry.Before, err = unmarshalAuditContact(before); err != nil {
+				return err
+			}
+			if entry.After, err = unmarshalAuditContact(after); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit log: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *SQLiteStorage) SaveSnapshot(snapshot domain.Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	return s.inTx(context.Background(), func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM snapshots WHERE name = ?)`, snapshot.Name).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check snapshot: %w", err)
+		}
+		if exists {
+			return fmt.Errorf("%w: '%s'", ErrSnapshotExists, snapshot.Name)
+		}
+
+		_, err = tx.Exec(`INSERT INTO snapshots (name, created_at, data) VALUES (?, ?, ?)`,
+			snapshot.Name, formatTime(snapshot.CreatedAt), string(data))
+		if err != nil {
+			return fmt.Errorf("failed to save snapshot: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStorage) LoadSnapshot(name string) (domain.Snapshot, error) {
+	snapshots, err := s.querySnapshots(`SELECT data FROM snapshots WHERE name = ?`, name)
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	if len(snapshots) == 0 {
+		return domain.Snapshot{}, fmt.Errorf("%w: '%s'", ErrSnapshotNotFound, name)
+	}
+	return snapshots[0], nil
+}
+
+func (s *SQLiteStorage) ListSnapshots() ([]domain.Snapshot, error) {
+	return s.querySnapshots(`SELECT data FROM snapshots ORDER BY seq`)
+}
+
+func (s *SQLiteStorage) querySnapshots(query string, args ...any) ([]domain.Snapshot, error) {
+	var snapshots []domain.Snapshot
+	err := scanRows(context.Background(), s.db, query, args, func(rows *sql.Rows) error {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		var snapshot domain.Snapshot
+		if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+			return err
+		}
+		snapshots = append(snapshots, snapshot)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+func (s *SQLiteStorage) Get(ctx context.Context, id string) (domain.Contact, error) {
+	contacts, err := queryContacts(ctx, s.db, `SELECT `+contactColumns+` FROM contacts WHERE id = ? AND deleted_at = ''`, id)
+	if err != nil {
+		return domain.Contact{}, fmt.Errorf("failed to get contact: %w", err)
+	}
+	if len(contacts) == 0 {
+		return domain.Contact{}, fmt.Errorf("%w: '%s'", ErrNotFound, id)
+	}
+
+	return contacts[0], nil
+}
+
+func (s *SQLiteStorage) Insert(ctx context.Context, contact domain.Contact) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM contacts WHERE id = ?)`, contact.ID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to insert contact: %w", err)
+		}
+		if exists {
+			return fmt.Errorf("%w: '%s'", ErrExists, contact.ID)
+		}
+
+		if err := insertContact(ctx, tx, contact); err != nil {
+			return fmt.Errorf("failed to insert contact: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStorage) Update(ctx context.Context, contact domain.Contact) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return updateContact(ctx, tx, contact)
+	})
+}
+
+func (s *SQLiteStorage) Delete(ctx context.Context, id string) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return deleteContact(ctx, tx, id)
+	})
+}
+
+func (s *SQLiteStorage) Query(ctx context.Context, query Query) ([]domain.Contact, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	return queryContacts(ctx, s.db,
+		`SELECT `+contactColumns+` FROM contacts
+		WHERE instr(lower(name), lower(?)) > 0 AND (deleted_at = '' OR ?)
+		ORDER BY seq LIMIT ? OFFSET ?`,
+		query.Name, query.IncludeDeleted, limit, max(query.Offset, 0))
+}
+
+// Version reports PRAGMA data_version, which changes whenever another
+// connection, possibly in another process, commits to the database.
+func (s *SQLiteStorage) Version() (string, error) {
+	var version int64
+	if err := s.db.QueryRow(`PRAGMA data_version`).Scan(&version); err != nil {
+		return "", fmt.Errorf("failed to read data version: %w", err)
+	}
+	return strconv.FormatInt(version, 10), nil
+}
+
+func (s *SQLiteStorage) init() error {
+	pragmas := []string{
+		`PRAGMA journal_mode = WAL`,
+		`PRAGMA busy_timeout = 5000`,
+		`PRAGMA foreign_keys = ON`,
+	}
+	for _, pragma := range pragmas {
+		if _, err := s.db.Exec(pragma); err != nil {
+			return fmt.Errorf("failed to configure database: %w", err)
+		}
+	}
+
+	return s.migrate()
+}
+
+// migrate applies every migration newer than the schema version recorded in
+// PRAGMA user_version, each in its own transaction.
+func (s *SQLiteStorage) migrate() error {
+	var current int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %d: %w", version, err)
+		}
+
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", version, err)
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", version, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func insertContact(ctx context.Context, tx *sql.Tx, contact domain.Contact) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO contacts (`+contactColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		contact.ID, contact.Name, contact.FirstName, contact.LastName,
+		contact.Organization, contact.JobTitle, contact.Birthday, contact.Notes, formatTime(contact.CreatedAt),
+		contact.Favorite, formatTime(contact.LastAccessedAt), formatTime(contact.DeletedAt))
+	if err != nil {
+		return err
+	}
+
+	return insertDetails(ctx, tx, contact)
+}
+
+func updateContact(ctx context.Context, tx *sql.Tx, contact domain.Contact) error {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE contacts SET name = ?, first_name = ?, last_name = ?, organization = ?,
+			job_title = ?, birthday = ?, notes = ?, created_at = ?, favorite = ?, last_accessed_at = ?, deleted_at = ?
+			WHERE id = ?`,
+		contact.Name, contact.FirstName, contact.LastName, contact.Organization,
+		contact.JobTitle, contact.Birthday, contact.Notes, formatTime(contact.CreatedAt),
+		contact.Favorite, formatTime(contact.LastAccessedAt), formatTime(contact.DeletedAt), contact.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update contact: %w", err)
+	}
+
+	if err := expectAffected(result, fmt.Errorf("%w: '%s'", ErrNotFound, contact.ID)); err != nil {
+		return err
+	}
+
+	if err := deleteDetails(ctx, tx, contact.ID); err != nil {
+		return err
+	}
+	return insertDetails(ctx, tx, contact)
+}
+
+func deleteContact(ctx context.Context, tx *sql.Tx, id string) error {
+	if err := deleteDetails(ctx, tx, id); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM contacts WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete contact: %w", err)
+	}
+
+	return expectAffected(result, fmt.Errorf("%w: '%s'", ErrNotFound, id))
+}
+
+func insertDetails(ctx context.Context, tx *sql.Tx, contact domain.Contact) error {
+	for i, phone := range contact.Phones {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO contact_phones (contact_id, position, type, number, display) VALUES (?, ?, ?, ?, ?)`,
+			contact.ID, i, phone.Type, phone.Number, phone.Display)
+		if err != nil {
+			return fmt.Errorf("failed to save phone: %w", err)
+		}
+	}
+
+	for i, email := range contact.Emails {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO contact_emails (contact_id, position, type, address) VALUES (?, ?, ?, ?)`,
+			contact.ID, i, email.Type, email.Address)
+		if err != nil {
+			return fmt.Errorf("failed to save email: %w", err)
+		}
+	}
+
+	for i, address := range contact.Addresses {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO contact_addresses (contact_id, position, type, street, city, region, postal_code, country)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			contact.ID, i, address.Type, address.Street, address.City, address.Region, address.PostalCode, address.Country)
+		if err != nil {
+			return fmt.Errorf("failed to save address: %w", err)
+		}
+	}
+
+	for i, tag := range contact.Tags {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO contact_tags (contact_id, position, tag) VALUES (?, ?, ?)`,
+			contact.ID, i, tag)
+		if err != nil {
+			return fmt.Errorf("failed to save tag: %w", err)
+		}
+	}
+
+	for i, groupID := range contact.Groups {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO group_members (contact_id, position, group_id) VALUES (?, ?, ?)`,
+			contact.ID, i, groupID)
+		if err != nil {
+			return fmt.Errorf("failed to save group membership: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func deleteDetails(ctx context.Context, tx *sql.Tx, id string) error {
+	for _, table := range []string{"contact_phones", "contact_emails", "contact_addresses", "contact_tags", "group_members"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE contact_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// queryContacts runs a query selecting contactColumns and attaches the
+// phones, emails and addresses of every contact it returns.
+func queryContacts(ctx context.Context, q queryer, query string, args ...any) ([]domain.Contact, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contacts: %w", err)
+	}
+	defer rows.Close()
+
+	contacts := make([]domain.Contact, 0)
+	for rows.Next() {
+		var (
+			contact                          domain.Contact
+			createdAt, lastAccessed, deleted string
+		)
+		err := rows.Scan(&contact.ID, &contact.Name, &contact.FirstName, &contact.LastName,
+			&contact.Organization, &contact.JobTitle, &contact.Birthday, &contact.Notes, &createdAt,
+			&contact.Favorite, &lastAccessed, &deleted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		if contact.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		if contact.LastAccessedAt, err = parseTime(lastAccessed); err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		if contact.DeletedAt, err = parseTime(deleted); err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+
+		if contact.FirstName == "" && contact.LastName == "" {
+			contact.FirstName, contact.LastName = domain.SplitName(contact.Name)
+		}
+		contacts = append(contacts, contact)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read contacts: %w", err)
+	}
+	rows.Close()
+
+	byID := make(map[string]*domain.Contact, len(contacts))
+	ids := make([]any, 0, len(contacts))
+	for i := range contacts {
+		byID[contacts[i].ID] = &contacts[i]
+		ids = append(ids, contacts[i].ID)
+	}
+
+	for start := 0; start < len(ids); start += detailBatchSize {
+		batch := ids[start:min(start+detailBatchSize, len(ids))]
+		if err := attachDetails(ctx, q, byID, batch); err != nil {
+			return nil, err
+		}
+	}
+
+	return contacts, nil
+}
+
+func attachDetails(ctx context.Context, q queryer, byID map[string]*domain.Contact, ids []any) error {
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	err := scanRows(ctx, q, `SELECT contact_id, type, number, display FROM contact_phones
+		WHERE contact_id IN (`+in+`) ORDER BY contact_id, position`, ids,
+		func(rows *sql.Rows) error {
+			var id string
+			var phone domain.Phone
+			if err := rows.Scan(&id, &phone.Type, &phone.Number, &phone.Display); err != nil {
+				return err
+			}
+			byID[id].Phones = append(byID[id].Phones, phone)
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("failed to load phones: %w", err)
+	}
+
+	err = scanRows(ctx, q, `SELECT contact_id, type, address FROM contact_emails
+		WHERE contact_id IN (`+in+`) ORDER BY contact_id, position`, ids,
+		func(rows *sql.Rows) error {
+			var id string
+			var email domain.Email
+			if err := rows.Scan(&id, &email.Type, &email.Address); err != nil {
+				return err
+			}
+			byID[id].Emails = append(byID[id].Emails, email)
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("failed to load emails: %w", err)
+	}
+
+	err = scanRows(ctx, q, `SELECT contact_id, type, street, city, region, postal_code, country FROM contact_addresses
+		WHERE contact_id IN (`+in+`) ORDER BY contact_id, position`, ids,
+		func(rows *sql.Rows) error {
+			var id string
+			var address domain.Address
+			err := rows.Scan(&id, &address.Type, &address.Street, &address.City,
+				&address.Region, &address.PostalCode, &address.Country)
+			if err != nil {
+				return err
+			}
+			byID[id].Addresses = append(byID[id].Addresses, address)
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("failed to load addresses: %w", err)
+	}
+
+	err = scanRows(ctx, q, `SELECT contact_id, tag FROM contact_tags
+		WHERE contact_id IN (`+in+`) ORDER BY contact_id, position`, ids,
+		func(rows *sql.Rows) error {
+			var id, tag string
+			if err := rows.Scan(&id, &tag); err != nil {
+				return err
+			}
+			byID[id].Tags = append(byID[id].Tags, tag)
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("failed to load tags: %w", err)
+	}
+
+	err = scanRows(ctx, q, `SELECT contact_id, group_id FROM group_members
+		WHERE contact_id IN (`+in+`) ORDER BY contact_id, position`, ids,
+		func(rows *sql.Rows) error {
+			var id, groupID string
+			if err := rows.Scan(&id, &groupID); err != nil {
+				return err
+			}
+			byID[id].Groups = append(byID[id].Groups, groupID)
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("failed to load group memberships: %w", err)
+	}
+
+	return nil
+}
+
+func scanRows(ctx context.Context, q queryer, query string, args []any, scan func(rows *sql.Rows) error) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func expectAffected(result sql.Result, errNone error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return errNone
+	}
+	return nil
+}
+
+// timeLayout is RFC 3339 with a fixed number of fractional digits, so UTC
+// times sort chronologically as text.
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// formatTime stores t in UTC. The zero time is stored as an empty string.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// marshalAuditContact stores the contact of an audit entry as JSON, and a
+// missing one as an empty string.
+func marshalAuditContact(contact *domain.Contact) (string, error) {
+	if contact == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(contact)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	return string(data), nil
+}
+
+func unmarshalAuditContact(value string) (*domain.Contact, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var contact domain.Contact
+	if err := json.Unmarshal([]byte(value), &contact); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit entry: %w", err)
+	}
+	return &contact, nil
+}
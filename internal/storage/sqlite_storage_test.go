@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+func newTestSQLiteStorage(t *testing.T, path string) *SQLiteStorage {
+	t.Helper()
+
+	storage, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open SQLite storage: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	return storage
+}
+
+func TestSQLiteStorage(t *testing.T) {
+	testStorage(t, func(t *testing.T) Storage {
+		return newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "contacts.db"))
+	})
+}
+
+func TestSQLiteStorage_Groups(t *testing.T) {
+	testGroupStore(t, func(t *testing.T) Storage {
+		return newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "contacts.db"))
+	})
+}
+
+func TestSQLiteStorage_Audit(t *testing.T) {
+	testAuditStore(t, func(t *testing.T) Storage {
+		return newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "contacts.db"))
+	})
+}
+
+func TestSQLiteStorage_Snapshots(t *testing.T) {
+	testSnapshotStore(t, func(t *testing.T) Storage {
+		return newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "contacts.db"))
+	})
+}
+
+func TestSQLiteStorage_Records(t *testing.T) {
+	testRecordStore(t, func(t *testing.T) RecordStore {
+		store := newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "contacts.db"))
+		if Records(store) != RecordStore(store) {
+			t.Fatal("Expected SQLiteStorage to implement RecordStore natively")
+		}
+		return store
+	})
+}
+
+func TestSQLiteStorage_Migrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.db")
+
+	storage := newTestSQLiteStorage(t, path)
+	contacts := []domain.Contact{domain.NewContact("John Doe", "1234567890")}
+	if err := storage.Save(contacts); err != nil {
+		t.Fatalf("Failed to save contacts: %v", err)
+	}
+	storage.Close()
+
+	reopened := newTestSQLiteStorage(t, path)
+
+	var version int
+	if err := reopened.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		t.Fatalf("Failed to read schema version: %v", err)
+	}
+	if version != len(migrations) {
+		t.Errorf("Expected schema version %d, got %d", len(migrations), version)
+	}
+
+	assertContacts(t, reopened, contacts)
+}
+
+func TestSQLiteStorage_VersionTracksOtherConnections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.db")
+	first := newTestSQLiteStorage(t, path)
+	second := newTestSQLiteStorage(t, path)
+
+	before, err := first.Version()
+	if err != nil {
+		t.Fatalf("Failed to get version: %v", err)
+	}
+
+	if err := second.Save([]domain.Contact{domain.NewContact("John Doe", "1234567890")}); err != nil {
+		t.Fatalf("Failed to save contacts: %v", err)
+	}
+
+	after, err := first.Version()
+	if err != nil {
+		t.Fatalf("Failed to get version: %v", err)
+	}
+	if before == after {
+		t.Error("Expected version to change after another connection saved")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	jsonStore, err := Open("json://" + filepath.Join(dir, "contacts.json"))
+	if err != nil {
+		t.Fatalf("Failed to open JSON store: %v", err)
+	}
+	if _, ok := jsonStore.(*JSONStorage); !ok {
+		t.Errorf("Expected *JSONStorage, got %T", jsonStore)
+	}
+
+	sqliteStore, err := Open("sqlite://" + filepath.Join(dir, "contacts.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite store: %v", err)
+	}
+	if s, ok := sqliteStore.(*SQLiteStorage); !ok {
+		t.Errorf("Expected *SQLiteStorage, got %T", sqliteStore)
+	} else {
+		s.Close()
+	}
+
+	for _, location := range []string{"contacts.json", "ftp://contacts", "sqlite://"} {
+		if _, err := Open(location); err == nil {
+			t.Errorf("Expected error opening '%s'", location)
+		}
+	}
+}
+
+func TestSQLiteStorage_MigratesNameKeyedSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.db")
+
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec(migrations[0]); err != nil {
+		t.Fatalf("Failed to create version 1 schema: %v", err)
+	}
+	if _, err := db.Exec(`PRAGMA user_version = 1`); err != nil {
+		t.Fatalf("Failed to set schema version: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO contacts (name, phone) VALUES ('John Doe', '1'), ('Jane Smith', '2')`); err != nil {
+		t.Fatalf("Failed to insert contacts: %v", err)
+	}
+	db.Close()
+
+	storage := newTestSQLiteStorage(t, path)
+	contacts, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Failed to load contacts: %v", err)
+	}
+
+	if len(contacts) != 2 || contacts[0].Name != "John Doe" || contacts[1].Name != "Jane Smith" {
+		t.Fatalf("Expected migrated contacts in order, got %v", contacts)
+	}
+
+	if len(contacts[0].ID) != 36 || contacts[0].ID == contacts[1].ID {
+		t.Errorf("Expected distinct UUIDs, got '%s' and '%s'", contacts[0].ID, contacts[1].ID)
+	}
+
+	if contacts[0].PrimaryPhone() != "1" || contacts[1].PrimaryPhone() != "2" {
+		t.Errorf("Expected phones moved to contact_phones, got %v and %v", contacts[0].Phones, contacts[1].Phones)
+	}
+
+	if contacts[0].FirstName != "John" || contacts[0].LastName != "Doe" {
+		t.Errorf("Expected name parts 'John' and 'Doe', got '%s' and '%s'", contacts[0].FirstName, contacts[0].LastName)
+	}
+}
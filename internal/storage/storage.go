@@ -1,8 +1,84 @@
 package storage
 
-import "github.com/LaulauChau/go-directory/internal/domain"
+import (
+	"context"
+	"errors"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+var (
+	ErrNotFound = errors.New("contact not found")
+	ErrExists   = errors.New("contact already exists")
+
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	ErrSnapshotExists   = errors.New("snapshot already exists")
+)
 
 type Storage interface {
 	Load() ([]domain.Contact, error)
 	Save(contacts []domain.Contact) error
 }
+
+// RecordStore is implemented by storages that can read and write single
+// contacts without rewriting the whole set. Contacts are keyed by ID. Get and
+// Query skip the contacts in the trash, those with a DeletedAt, while Update
+// and Delete apply to any contact.
+type RecordStore interface {
+	Get(ctx context.Context, id string) (domain.Contact, error)
+	Insert(ctx context.Context, contact domain.Contact) error
+	Update(ctx context.Context, contact domain.Contact) error
+	Delete(ctx context.Context, id string) error
+	Query(ctx context.Context, query Query) ([]domain.Contact, error)
+}
+
+// Query selects contacts whose name contains Name, case-insensitively. A
+// zero Limit returns every match after Offset. IncludeDeleted also selects
+// the contacts in the trash.
+type Query struct {
+	Name           string
+	Offset         int
+	Limit          int
+	IncludeDeleted bool
+}
+
+// GroupStore is implemented by storages that keep contact groups next to the
+// contacts. Membership is saved with the contacts themselves.
+type GroupStore interface {
+	LoadGroups() ([]domain.Group, error)
+	SaveGroups(groups []domain.Group) error
+}
+
+// AuditStore is implemented by storages that keep an append-only log of the
+// changes made to the contacts. Entries are loaded oldest first.
+type AuditStore interface {
+	AppendAudit(entries []domain.AuditEntry) error
+	LoadAudit() ([]domain.AuditEntry, error)
+}
+
+// SnapshotStore is implemented by storages that can keep copies of the whole
+// directory under unique names. Snapshots are listed oldest first.
+type SnapshotStore interface {
+	SaveSnapshot(snapshot domain.Snapshot) error
+	LoadSnapshot(name string) (domain.Snapshot, error)
+	ListSnapshots() ([]domain.Snapshot, error)
+}
+
+// Locker is implemented by storages shared between processes. Lock blocks
+// until the caller holds exclusive access for a read-modify-write cycle.
+type Locker interface {
+	Lock() error
+	Unlock() error
+}
+
+// LockedLoader is implemented by Lockers whose Load takes the lock, to
+// migrate what it reads. LoadLocked loads for a caller already holding it.
+type LockedLoader interface {
+	LoadLocked() ([]domain.Contact, error)
+}
+
+// Versioner is implemented by storages that can report whether their content
+// changed since it was last loaded or saved.
+type Versioner interface {
+	Version() (string, error)
+}
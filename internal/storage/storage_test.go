@@ -0,0 +1,474 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+// testStorage runs the behaviour every Storage implementation must share.
+func testStorage(t *testing.T, newStorage func(t *testing.T) Storage) {
+	t.Run("LoadEmpty", func(t *testing.T) {
+		contacts, err := newStorage(t).Load()
+		if err != nil {
+			t.Errorf("Expected no error loading empty storage, got %v", err)
+		}
+
+		if len(contacts) != 0 {
+			t.Errorf("Expected empty contacts, got %d contacts", len(contacts))
+		}
+	})
+
+	t.Run("SaveAndLoad", func(t *testing.T) {
+		storage := newStorage(t)
+		contacts := []domain.Contact{
+			domain.NewContact("John Doe", "1234567890"),
+			domain.NewContact("Jane Smith", "0987654321"),
+		}
+
+		if err := storage.Save(contacts); err != nil {
+			t.Fatalf("Failed to save contacts: %v", err)
+		}
+
+		assertContacts(t, storage, contacts)
+	})
+
+	t.Run("SaveAndLoadDetails", func(t *testing.T) {
+		storage := newStorage(t)
+		deleted := domain.NewContact("Bob Martin", "5555555555")
+		deleted.DeletedAt = time.Date(2025, 7, 1, 8, 0, 0, 0, time.UTC)
+		contacts := []domain.Contact{richContact(), domain.NewContact("Jane Smith", "0987654321"), deleted}
+
+		if err := storage.Save(contacts); err != nil {
+			t.Fatalf("Failed to save contacts: %v", err)
+		}
+
+		loaded, err := storage.Load()
+		if err != nil {
+			t.Fatalf("Failed to load contacts: %v", err)
+		}
+
+		if !reflect.DeepEqual(loaded, contacts) {
+			t.Errorf("Expected %+v, got %+v", contacts, loaded)
+		}
+	})
+
+	t.Run("SaveReplacesContent", func(t *testing.T) {
+		storage := newStorage(t)
+		contacts := []domain.Contact{
+			domain.NewContact("John Doe", "1234567890"),
+			domain.NewContact("Jane Smith", "0987654321"),
+			domain.NewContact("Bob Martin", "5555555555"),
+		}
+
+		if err := storage.Save(contacts); err != nil {
+			t.Fatalf("Failed to save contacts: %v", err)
+		}
+
+		john := contacts[0]
+		john.SetPrimaryPhone("1111111111")
+		updated := []domain.Contact{
+			john,
+			contacts[2],
+			domain.NewContact("Alice Brown", "2222222222"),
+		}
+
+		if err := storage.Save(updated); err != nil {
+			t.Fatalf("Failed to save contacts: %v", err)
+		}
+
+		assertContacts(t, storage, updated)
+	})
+
+	t.Run("SaveEmpty", func(t *testing.T) {
+		storage := newStorage(t)
+
+		if err := storage.Save([]domain.Contact{domain.NewContact("John Doe", "1234567890")}); err != nil {
+			t.Fatalf("Failed to save contacts: %v", err)
+		}
+
+		if err := storage.Save([]domain.Contact{}); err != nil {
+			t.Fatalf("Failed to save contacts: %v", err)
+		}
+
+		assertContacts(t, storage, nil)
+	})
+}
+
+// testGroupStore runs the behaviour every GroupStore implementation must
+// share.
+func testGroupStore(t *testing.T, newStore func(t *testing.T) Storage) {
+	t.Run("LoadEmpty", func(t *testing.T) {
+		groups, err := newStore(t).(GroupStore).LoadGroups()
+		if err != nil {
+			t.Errorf("Expected no error loading empty groups, got %v", err)
+		}
+		if len(groups) != 0 {
+			t.Errorf("Expected no groups, got %v", groups)
+		}
+	})
+
+	t.Run("SaveAndLoad", func(t *testing.T) {
+		store := newStore(t)
+		groups := []domain.Group{
+			{ID: "group-1", Name: "Family", CreatedAt: time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)},
+			{ID: "group-2", Name: "Work"},
+		}
+
+		if err := store.(GroupStore).SaveGroups(groups); err != nil {
+			t.Fatalf("Failed to save groups: %v", err)
+		}
+		loaded, err := store.(GroupStore).LoadGroups()
+		if err != nil {
+			t.Fatalf("Failed to load groups: %v", err)
+		}
+		if !reflect.DeepEqual(loaded, groups) {
+			t.Errorf("Expected %+v, got %+v", groups, loaded)
+		}
+
+		if err := store.(GroupStore).SaveGroups(groups[1:]); err != nil {
+			t.Fatalf("Failed to save groups: %v", err)
+		}
+		loaded, _ = store.(GroupStore).LoadGroups()
+		if !reflect.DeepEqual(loaded, groups[1:]) {
+			t.Errorf("Expected %+v after removing a group, got %+v", groups[1:], loaded)
+		}
+	})
+
+	t.Run("KeptApartFromContacts", func(t *testing.T) {
+		store := newStore(t)
+		contacts := []domain.Contact{richContact()}
+		groups := []domain.Group{{ID: "group-1", Name: "Family"}}
+
+		if err := store.Save(contacts); err != nil {
+			t.Fatalf("Failed to save contacts: %v", err)
+		}
+		if err := store.(GroupStore).SaveGroups(groups); err != nil {
+			t.Fatalf("Failed to save groups: %v", err)
+		}
+		if err := store.Save(contacts); err != nil {
+			t.Fatalf("Failed to save contacts: %v", err)
+		}
+
+		loaded, err := store.(GroupStore).LoadGroups()
+		if err != nil {
+			t.Fatalf("Failed to load groups: %v", err)
+		}
+		if !reflect.DeepEqual(loaded, groups) {
+			t.Errorf("Expected saving contacts to keep the groups, got %+v", loaded)
+		}
+		assertContacts(t, store, contacts)
+	})
+}
+
+// testAuditStore runs the behaviour every AuditStore implementation must
+// share.
+func testAuditStore(t *testing.T, newStore func(t *testing.T) Storage) {
+	t.Run("LoadEmpty", func(t *testing.T) {
+		entries, err := newStore(t).(AuditStore).LoadAudit()
+		if err != nil {
+			t.Errorf("Expected no error loading an empty audit log, got %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Expected no entries, got %v", entries)
+		}
+	})
+
+	t.Run("AppendAndLoad", func(t *testing.T) {
+		store := newStore(t)
+		before := richContact()
+		after := before.Clone()
+		after.Organization = "Acme"
+		at := time.Date(2025, 3, 1, 9, 30, 0, 120000000, time.UTC)
+
+		created := []domain.AuditEntry{{Time: at, Actor: "alice", Action: domain.AuditCreate, ContactID: before.ID, After: &before}}
+		changed := []domain.AuditEntry{
+			{Time: at.Add(time.Minute), Actor: "bob", Action: domain.AuditUpdate, ContactID: before.ID, Before: &before, After: &after},
+			{Time: at.Add(time.Hour), Action: domain.AuditDelete, ContactID: before.ID, Before: &after},
+		}
+		if err := store.(AuditStore).AppendAudit(created); err != nil {
+			t.Fatalf("Failed to append to the audit log: %v", err)
+		}
+		if err := store.Save([]domain.Contact{after}); err != nil {
+			t.Fatalf("Failed to save contacts: %v", err)
+		}
+		if err := store.(AuditStore).AppendAudit(changed); err != nil {
+			t.Fatalf("Failed to append to the audit log: %v", err)
+		}
+
+		loaded, err := store.(AuditStore).LoadAudit()
+		if err != nil {
+			t.Fatalf("Failed to load the audit log: %v", err)
+		}
+		want := append(created, changed...)
+		if !reflect.DeepEqual(loaded, want) {
+			t.Errorf("Expected %+v, got %+v", want, loaded)
+		}
+		assertContacts(t, store, []domain.Contact{after})
+	})
+}
+
+func testSnapshotStore(t *testing.T, newStore func(t *testing.T) Storage) {
+	store := newStore(t).(SnapshotStore)
+
+	snapshots, err := store.ListSnapshots()
+	if err != nil || len(snapshots) != 0 {
+		t.Errorf("Expected no snapshots, got %v, %v", snapshots, err)
+	}
+	if _, err := store.LoadSnapshot("missing"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("Expected ErrSnapshotNotFound, got %v", err)
+	}
+
+	deleted := domain.NewContact("John Doe", "1234567890")
+	deleted.DeletedAt = time.Date(2025, 3, 2, 8, 0, 0, 0, time.UTC)
+	at := time.Date(2025, 3, 1, 9, 30, 0, 120000000, time.UTC)
+	first := domain.Snapshot{
+		Name:      "before-import",
+		CreatedAt: at,
+		Actor:     "alice",
+		Contacts:  []domain.Contact{richContact(), deleted},
+		Groups:    []domain.Group{{ID: "group-1", Name: "Family", CreatedAt: at}},
+	}
+	second := domain.Snapshot{Name: "empty", CreatedAt: at.Add(time.Hour), Contacts: []domain.Contact{}}
+
+	for _, snapshot := range []domain.Snapshot{first, second} {
+		if err := store.SaveSnapshot(snapshot); err != nil {
+			t.Fatalf("Failed to save snapshot %s: %v", snapshot.Name, err)
+		}
+	}
+	if err := store.SaveSnapshot(domain.Snapshot{Name: "empty", CreatedAt: at}); !errors.Is(err, ErrSnapshotExists) {
+		t.Errorf("Expected ErrSnapshotExists, got %v", err)
+	}
+
+	loaded, err := store.LoadSnapshot("before-import")
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, first) {
+		t.Errorf("Expected %+v, got %+v", first, loaded)
+	}
+
+	snapshots, err = store.ListSnapshots()
+	if err != nil {
+		t.Fatalf("Failed to list snapshots: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Name != "before-import" || snapshots[1].Name != "empty" {
+		t.Errorf("Expected both snapshots oldest first, got %+v", snapshots)
+	}
+}
+
+func richContact() domain.Contact {
+	return domain.Contact{
+		ID:           domain.NewID(),
+		Name:         "Ada Lovelace",
+		FirstName:    "Ada",
+		LastName:     "Lovelace",
+		Organization: "Analytical Engines Ltd",
+		JobTitle:     "Programmer",
+		Phones: []domain.Phone{
+			{Type: domain.PhoneMobile, Number: "+447911123456", Display: "+44 7911 123456"},
+			{Type: domain.PhoneWork, Number: "0144556677"},
+		},
+		Emails: []domain.Email{
+			{Type: "work", Address: "ada@engines.example"},
+			{Type: "home", Address: "ada@home.example"},
+		},
+		Addresses: []domain.Address{
+			{Type: "work", Street: "12 St James's Square", City: "London", PostalCode: "SW1Y 4JH", Country: "United Kingdom"},
+		},
+		Birthday:  "1815-12-10",
+		Notes:     "Prefers email",
+		Tags:      []string{"vip", "mathematics"},
+		Groups:    []string{"group-1", "group-2"},
+		Favorite:  true,
+		CreatedAt: time.Date(2025, 3, 1, 9, 30, 0, 120000000, time.UTC),
+
+		LastAccessedAt: time.Date(2025, 6, 12, 14, 5, 0, 0, time.UTC),
+	}
+}
+
+func assertContacts(t *testing.T, storage Storage, expected []domain.Contact) {
+	t.Helper()
+
+	loaded, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Failed to load contacts: %v", err)
+	}
+
+	if len(loaded) != len(expected) {
+		t.Fatalf("Expected %d contacts, got %d", len(expected), len(loaded))
+	}
+
+	for i, contact := range expected {
+		if contact.ID != loaded[i].ID {
+			t.Errorf("Expected ID %s, got %s", contact.ID, loaded[i].ID)
+		}
+		if contact.Name != loaded[i].Name {
+			t.Errorf("Expected name %s, got %s", contact.Name, loaded[i].Name)
+		}
+		if contact.PrimaryPhone() != loaded[i].PrimaryPhone() {
+			t.Errorf("Expected phone %s, got %s", contact.PrimaryPhone(), loaded[i].PrimaryPhone())
+		}
+	}
+}
+
+// testRecordStore runs the behaviour every RecordStore implementation must
+// share.
+func testRecordStore(t *testing.T, newStore func(t *testing.T) RecordStore) {
+	ctx := context.Background()
+
+	t.Run("InsertAndGet", func(t *testing.T) {
+		store := newStore(t)
+		john := domain.NewContact("John Doe", "1234567890")
+
+		if err := store.Insert(ctx, john); err != nil {
+			t.Fatalf("Failed to insert contact: %v", err)
+		}
+
+		contact, err := store.Get(ctx, john.ID)
+		if err != nil {
+			t.Fatalf("Failed to get contact: %v", err)
+		}
+		if !reflect.DeepEqual(contact, john) {
+			t.Errorf("Expected %v, got %v", john, contact)
+		}
+
+		if err := store.Insert(ctx, john); !errors.Is(err, ErrExists) {
+			t.Errorf("Expected ErrExists inserting duplicate ID, got %v", err)
+		}
+
+		namesake := domain.NewContact("John Doe", "0987654321")
+		if err := store.Insert(ctx, namesake); err != nil {
+			t.Errorf("Expected no error inserting a namesake, got %v", err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		store := newStore(t)
+		john := domain.NewContact("John Doe", "1234567890")
+		insertAll(t, store, john, domain.NewContact("Jane Smith", "0987654321"))
+
+		john.Name = "John A. Doe"
+		john.SetPrimaryPhone("5555555555")
+		if err := store.Update(ctx, john); err != nil {
+			t.Fatalf("Failed to update contact: %v", err)
+		}
+
+		contact, err := store.Get(ctx, john.ID)
+		if err != nil {
+			t.Fatalf("Failed to get contact: %v", err)
+		}
+		if !reflect.DeepEqual(contact, john) {
+			t.Errorf("Expected %v, got %v", john, contact)
+		}
+
+		err = store.Update(ctx, domain.NewContact("Non Existent", "1"))
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound updating missing contact, got %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		store := newStore(t)
+		john := domain.NewContact("John Doe", "1234567890")
+		insertAll(t, store, john)
+
+		if err := store.Delete(ctx, john.ID); err != nil {
+			t.Fatalf("Failed to delete contact: %v", err)
+		}
+
+		if _, err := store.Get(ctx, john.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound after delete, got %v", err)
+		}
+
+		if err := store.Delete(ctx, john.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound deleting missing contact, got %v", err)
+		}
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		store := newStore(t)
+		insertAll(t, store,
+			domain.NewContact("John Doe", "1"),
+			domain.NewContact("Jane Doe", "2"),
+			domain.NewContact("Bob Martin", "3"),
+			domain.NewContact("Johnny Doe", "4"),
+		)
+
+		matches, err := store.Query(ctx, Query{Name: "doe"})
+		if err != nil {
+			t.Fatalf("Failed to query contacts: %v", err)
+		}
+		if len(matches) != 3 {
+			t.Errorf("Expected 3 matches, got %d", len(matches))
+		}
+
+		page, err := store.Query(ctx, Query{Name: "doe", Offset: 1, Limit: 1})
+		if err != nil {
+			t.Fatalf("Failed to query contacts: %v", err)
+		}
+		if len(page) != 1 || page[0].Name != "Jane Doe" {
+			t.Errorf("Expected page with 'Jane Doe', got %v", page)
+		}
+
+		empty, err := store.Query(ctx, Query{Offset: 10})
+		if err != nil {
+			t.Fatalf("Failed to query contacts: %v", err)
+		}
+		if len(empty) != 0 {
+			t.Errorf("Expected no contacts past the end, got %d", len(empty))
+		}
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		store := newStore(t)
+		john := domain.NewContact("John Doe", "1")
+		jane := domain.NewContact("Jane Doe", "2")
+		insertAll(t, store, john, jane)
+
+		jane.DeletedAt = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+		if err := store.Update(ctx, jane); err != nil {
+			t.Fatalf("Failed to move contact to the trash: %v", err)
+		}
+
+		if _, err := store.Get(ctx, jane.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound getting a contact in the trash, got %v", err)
+		}
+		matches, err := store.Query(ctx, Query{Name: "doe"})
+		if err != nil {
+			t.Fatalf("Failed to query contacts: %v", err)
+		}
+		if len(matches) != 1 || matches[0].ID != john.ID {
+			t.Errorf("Expected only John Doe, got %v", matches)
+		}
+		all, err := store.Query(ctx, Query{Name: "doe", IncludeDeleted: true})
+		if err != nil {
+			t.Fatalf("Failed to query contacts: %v", err)
+		}
+		if len(all) != 2 || !all[1].DeletedAt.Equal(jane.DeletedAt) {
+			t.Errorf("Expected both contacts, trash included, got %v", all)
+		}
+
+		jane.DeletedAt = time.Time{}
+		if err := store.Update(ctx, jane); err != nil {
+			t.Fatalf("Failed to restore contact: %v", err)
+		}
+		if _, err := store.Get(ctx, jane.ID); err != nil {
+			t.Errorf("Expected the restored contact, got %v", err)
+		}
+	})
+}
+
+func insertAll(t *testing.T, store RecordStore, contacts ...domain.Contact) {
+	t.Helper()
+
+	for _, contact := range contacts {
+		if err := store.Insert(context.Background(), contact); err != nil {
+			t.Fatalf("Failed to insert contact: %v", err)
+		}
+	}
+}
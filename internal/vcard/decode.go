@@ -0,0 +1,385 @@
+package vcard
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+// maxLineLength bounds a physical line, before unfolding.
+const maxLineLength = 1 << 20
+
+// property is one unfolded content line, e.g. "TEL;TYPE=work:+1 555".
+type property struct {
+	line int
+	name string
+	// params maps upper-cased parameter names to their values. Bare
+	// parameters of vCard 2.1, such as ";WORK", are read as TYPE values.
+	params map[string][]string
+	// value is the raw value, still escaped.
+	value string
+}
+
+// types returns the TYPE values of p in lower case. A quoted list, as in
+// TYPE="work,voice", is split too.
+func (p property) types() []string {
+	var types []string
+	for _, value := range p.params["TYPE"] {
+		for _, t := range strings.Split(value, ",") {
+			types = append(types, strings.ToLower(strings.TrimSpace(t)))
+		}
+	}
+	return types
+}
+
+// preference ranks p among the properties of its kind, lower first. vCard
+// 4.0 gives PREF=1..100, 3.0 a "pref" type.
+func (p property) preference() int {
+	if values := p.params["PREF"]; len(values) > 0 {
+		if n, err := strconv.Atoi(values[0]); err == nil {
+			return n
+		}
+	}
+	if slices.Contains(p.types(), "pref") {
+		return 1
+	}
+	return 101
+}
+
+// Decode reads every vCard in r. Properties the directory has no field for
+// are skipped, and so are birthdays without a year. Contacts keep the card's
+// UID as their ID, or no ID when the card has none.
+func Decode(r io.Reader) ([]domain.Contact, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		contacts []domain.Contact
+		card     []property
+		inCard   bool
+		begin    int
+	)
+	for _, line := range lines {
+		if strings.TrimSpace(line.text) == "" {
+			continue
+		}
+
+		prop, err := parseProperty(line.text, line.number)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VCARD"):
+			if inCard {
+				return nil, &ParseError{Line: prop.line, Message: "BEGIN:VCARD inside another vCard"}
+			}
+			inCard, begin, card = true, prop.line, nil
+		case prop.name == "END" && strings.EqualFold(prop.value, "VCARD"):
+			if !inCard {
+				return nil, &ParseError{Line: prop.line, Message: "END:VCARD without BEGIN:VCARD"}
+			}
+			contacts = append(contacts, decodeCard(card))
+			inCard = false
+		case !inCard:
+			return nil, &ParseError{Line: prop.line, Message: fmt.Sprintf("%s property outside a vCard", prop.name)}
+		default:
+			card = append(card, prop)
+		}
+	}
+
+	if inCard {
+		return nil, &ParseError{Line: begin, Message: "vCard is missing END:VCARD"}
+	}
+	return contacts, nil
+}
+
+type physicalLine struct {
+	number int
+	text   string
+}
+
+// unfold joins folded lines: a line starting with a space or a tab
+// continues the previous one, without that first character.
+func unfold(r io.Reader) ([]physicalLine, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineLength)
+
+	var lines []physicalLine
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if number == 1 {
+			text = strings.TrimPrefix(text, "\uFEFF")
+		}
+
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		lines = append(lines, physicalLine{number: number, text: text})
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, &ParseError{Line: len(lines) + 1, Message: "line is too long"}
+		}
+		return nil, fmt.Errorf("failed to read vCard: %w", err)
+	}
+	return lines, nil
+}
+
+// parseProperty splits a content line into its name, parameters and value.
+// Parameter values may be quoted to hold ':', ';' or ','.
+func parseProperty(text string, line int) (property, error) {
+	var (
+		fields  []string
+		start   int
+		quoted  bool
+		colonAt = -1
+	)
+	for i := 0; i < len(text) && colonAt < 0; i++ {
+		switch text[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				fields = append(fields, text[start:i])
+				start = i + 1
+			}
+		case ':':
+			if !quoted {
+				fields = append(fields, text[start:i])
+				colonAt = i
+			}
+		}
+	}
+	if colonAt < 0 {
+		return property{}, &ParseError{Line: line, Message: fmt.Sprintf("expected NAME:value, got '%s'", text)}
+	}
+
+	name := fields[0]
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		// Drop the group prefix, as in "item1.EMAIL".
+		name = name[i+1:]
+	}
+	if name == "" {
+		return property{}, &ParseError{Line: line, Message: "property name is empty"}
+	}
+
+	prop := property{
+		line:   line,
+		name:   strings.ToUpper(name),
+		params: make(map[string][]string),
+		value:  text[colonAt+1:],
+	}
+	for _, param := range fields[1:] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			key, value = "TYPE", param
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		for _, v := range splitParamValue(value) {
+			if v != "" {
+				prop.params[key] = append(prop.params[key], v)
+			}
+		}
+	}
+	return prop, nil
+}
+
+// splitParamValue splits a parameter value list on commas outside quotes
+// and removes the quotes.
+func splitParamValue(value string) []string {
+	var (
+		values []string
+		b      strings.Builder
+		quoted bool
+	)
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			values = append(values, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return append(values, strings.TrimSpace(b.String()))
+}
+
+// ranked pairs a repeated property with its preference, so that sorting by
+// preference keeps the order of the file otherwise.
+type ranked[T any] struct {
+	rank  int
+	value T
+}
+
+func byRank[T any](items []ranked[T]) []T {
+	if len(items) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(items, func(a, b ranked[T]) int { return cmp.Compare(a.rank, b.rank) })
+	values := make([]T, len(items))
+	for i, item := range items {
+		values[i] = item.value
+	}
+	return values
+}
+
+func decodeCard(props []property) domain.Contact {
+	var (
+		contact   domain.Contact
+		phones    []ranked[domain.Phone]
+		emails    []ranked[domain.Email]
+		addresses []ranked[domain.Address]
+	)
+
+	for _, prop := range props {
+		switch prop.name {
+		case "UID":
+			contact.ID = strings.TrimPrefix(unescape(prop.value), "urn:uuid:")
+		case "FN":
+			contact.Name = strings.TrimSpace(unescape(prop.value))
+		case "N":
+			parts := split(prop.value, ';')
+			contact.LastName = strings.TrimSpace(unescape(parts[0]))
+			if len(parts) > 1 {
+				contact.FirstName = strings.TrimSpace(unescape(parts[1]))
+			}
+		case "ORG":
+			contact.Organization = strings.TrimSpace(unescape(split(prop.value, ';')[0]))
+		case "TITLE":
+			contact.JobTitle = strings.TrimSpace(unescape(prop.value))
+		case "TEL":
+			number := unescape(prop.value)
+			if uri, ok := strings.CutPrefix(number, "tel:"); ok {
+				number, _, _ = strings.Cut(uri, ";")
+			}
+			if number = strings.TrimSpace(number); number != "" {
+				phones = append(phones, ranked[domain.Phone]{prop.preference(), domain.Phone{Type: phoneType(prop.types()), Number: number}})
+			}
+		case "EMAIL":
+			if address := strings.TrimSpace(unescape(prop.value)); address != "" {
+				emails = append(emails, ranked[domain.Email]{prop.preference(), domain.Email{Type: placeType(prop.types()), Address: address}})
+			}
+		case "ADR":
+			if address, ok := decodeAddress(prop); ok {
+				addresses = append(addresses, ranked[domain.Address]{prop.preference(), address})
+			}
+		case "BDAY":
+			contact.Birthday = decodeDate(unescape(prop.value))
+		case "NOTE":
+			contact.Notes = strings.TrimSpace(unescape(prop.value))
+		case "CATEGORIES":
+			for _, tag := range split(prop.value, ',') {
+				if tag = strings.TrimSpace(unescape(tag)); tag != "" {
+					contact.Tags = append(contact.Tags, tag)
+				}
+			}
+		}
+	}
+
+	contact.Phones = byRank(phones)
+	contact.Emails = byRank(emails)
+	contact.Addresses = byRank(addresses)
+
+	if contact.Name == "" {
+		contact.Name = domain.JoinName(contact.FirstName, contact.LastName)
+	}
+	if contact.Name == "" {
+		contact.Name = cmp.Or(contact.Organization, contact.PrimaryEmail())
+	}
+	if contact.FirstName == "" && contact.LastName == "" {
+		contact.FirstName, contact.LastName = domain.SplitName(contact.Name)
+	}
+	return contact
+}
+
+// decodeAddress reads the post office box; extended address; street;
+// locality; region; postal code; country components of an ADR property.
+func decodeAddress(prop property) (domain.Address, bool) {
+	parts := split(prop.value, ';')
+	parts = append(parts, make([]string, max(7-len(parts), 0))...)
+
+	// Components may hold a comma-separated list, joined back here.
+	join := func(components ...string) string {
+		var values []string
+		for _, component := range components {
+			for _, value := range split(component, ',') {
+				if value = strings.TrimSpace(unescape(value)); value != "" {
+					values = append(values, value)
+				}
+			}
+		}
+		return strings.Join(values, ", ")
+	}
+	component := func(i int) string { return join(parts[i]) }
+
+	address := domain.Address{
+		Type:       placeType(prop.types()),
+		Street:     join(parts[2], parts[1], parts[0]),
+		City:       component(3),
+		Region:     component(4),
+		PostalCode: component(5),
+		Country:    component(6),
+	}
+	empty := address.Street == "" && address.City == "" && address.Region == "" && address.PostalCode == "" && address.Country == ""
+	return address, !empty
+}
+
+// decodeDate returns value as YYYY-MM-DD, reading the basic (19900401) and
+// extended forms with an optional time. Dates without a year, such as
+// "--0401", or in other formats are dropped.
+func decodeDate(value string) string {
+	value, _, _ = strings.Cut(strings.TrimSpace(value), "T")
+	for _, layout := range []string{time.DateOnly, "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format(time.DateOnly)
+		}
+	}
+	return ""
+}
+
+// phoneType maps TEL types to the directory's. Faxes, pagers and other
+// devices are kept as "other". Untyped numbers get the directory's default
+// type.
+func phoneType(types []string) domain.PhoneType {
+	switch {
+	case len(types) == 0:
+		return ""
+	case slices.Contains(types, "fax"), slices.Contains(types, "pager"):
+		return domain.PhoneOther
+	case slices.Contains(types, "cell"), slices.Contains(types, "mobile"), slices.Contains(types, "iphone"):
+		return domain.PhoneMobile
+	case slices.Contains(types, "work"):
+		return domain.PhoneWork
+	case slices.Contains(types, "home"):
+		return domain.PhoneHome
+	default:
+		return domain.PhoneOther
+	}
+}
+
+// placeType keeps the work and home types of EMAIL and ADR properties.
+func placeType(types []string) string {
+	switch {
+	case slices.Contains(types, "work"):
+		return "work"
+	case slices.Contains(types, "home"):
+		return "home"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,175 @@
+package vcard
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/google/uuid"
+)
+
+// maxLineOctets is the longest line a writer should produce, line break
+// excluded. Longer lines are folded.
+const maxLineOctets = 75
+
+// Encode writes contacts to w as vCards of the given version. The first
+// phone and email of each contact are marked as preferred.
+func Encode(w io.Writer, contacts []domain.Contact, version Version) error {
+	if _, err := ParseVersion(string(version)); err != nil {
+		return err
+	}
+
+	e := &encoder{w: bufio.NewWriter(w), version: version}
+	for _, contact := range contacts {
+		e.card(contact)
+	}
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+type encoder struct {
+	w       *bufio.Writer
+	version Version
+	err     error
+}
+
+func (e *encoder) card(contact domain.Contact) {
+	e.line("BEGIN", "", "VCARD")
+	e.line("VERSION", "", string(e.version))
+	if contact.ID != "" {
+		uid := escape(contact.ID)
+		if e.version == Version4 && uuid.Validate(contact.ID) == nil {
+			uid = "urn:uuid:" + contact.ID
+		}
+		e.line("UID", "", uid)
+	}
+	e.line("FN", "", escape(contact.Name))
+	e.line("N", "", escape(contact.LastName)+";"+escape(contact.FirstName)+";;;")
+	if contact.Organization != "" {
+		e.line("ORG", "", escape(contact.Organization))
+	}
+	if contact.JobTitle != "" {
+		e.line("TITLE", "", escape(contact.JobTitle))
+	}
+
+	for i, phone := range contact.Phones {
+		if e.version == Version4 {
+			e.line("TEL", "VALUE=uri;"+e.types(i == 0, telType(phone.Type)), "tel:"+phone.Number)
+		} else {
+			e.line("TEL", e.types(i == 0, telType(phone.Type)), escape(phone.Number))
+		}
+	}
+	for i, email := range contact.Emails {
+		types := []string{email.Type}
+		if e.version == Version3 {
+			types = append([]string{"internet"}, types...)
+		}
+		e.line("EMAIL", e.types(i == 0, types...), escape(email.Address))
+	}
+	for _, address := range contact.Addresses {
+		value := strings.Join([]string{
+			"", "",
+			escape(address.Street),
+			escape(address.City),
+			escape(address.Region),
+			escape(address.PostalCode),
+			escape(address.Country),
+		}, ";")
+		e.line("ADR", e.types(false, address.Type), value)
+	}
+
+	if contact.Birthday != "" {
+		e.line("BDAY", "", contact.Birthday)
+	}
+	if contact.Notes != "" {
+		e.line("NOTE", "", escape(contact.Notes))
+	}
+	if len(contact.Tags) > 0 {
+		tags := make([]string, len(contact.Tags))
+		for i, tag := range contact.Tags {
+			tags[i] = escape(tag)
+		}
+		e.line("CATEGORIES", "", strings.Join(tags, ","))
+	}
+	e.line("END", "", "VCARD")
+}
+
+// types returns the TYPE parameter for the given types, empty ones left out,
+// and the preference marker. Version 3.0 uses upper-case types and a "pref"
+// type, 4.0 lower-case types and PREF=1.
+func (e *encoder) types(preferred bool, types ...string) string {
+	var values []string
+	for _, t := range types {
+		if t == "" {
+			continue
+		}
+		if e.version == Version3 {
+			t = strings.ToUpper(t)
+		}
+		values = append(values, t)
+	}
+	if preferred && e.version == Version3 {
+		values = append(values, "PREF")
+	}
+
+	var params []string
+	if len(values) > 0 {
+		params = append(params, "TYPE="+strings.Join(values, ","))
+	}
+	if preferred && e.version == Version4 {
+		params = append(params, "PREF=1")
+	}
+	return strings.Join(params, ";")
+}
+
+// line writes a content line, folded to maxLineOctets without splitting a
+// UTF-8 sequence, and ended with CRLF.
+func (e *encoder) line(name, params, value string) {
+	if e.err != nil {
+		return
+	}
+
+	text := name
+	if params != "" {
+		text += ";" + params
+	}
+	text += ":" + value
+
+	limit := maxLineOctets
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		e.write(text[:cut] + "\r\n ")
+		text = text[cut:]
+		// Continuation lines start with a space, which counts.
+		limit = maxLineOctets - 1
+	}
+	e.write(text + "\r\n")
+}
+
+func (e *encoder) write(s string) {
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
+
+// telType maps a phone type to the TEL type of both versions: "cell" for
+// mobiles and "voice" for other numbers.
+func telType(phoneType domain.PhoneType) string {
+	switch phoneType {
+	case domain.PhoneMobile:
+		return "cell"
+	case domain.PhoneWork:
+		return "work"
+	case domain.PhoneHome:
+		return "home"
+	default:
+		return "voice"
+	}
+}
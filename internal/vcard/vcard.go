@@ -0,0 +1,93 @@
+// Package vcard reads and writes contacts in the vCard format (RFC 2426 for
+// version 3.0, RFC 6350 for version 4.0).
+package vcard
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Version is a vCard format version.
+type Version string
+
+const (
+	Version3 Version = "3.0"
+	Version4 Version = "4.0"
+)
+
+// ParseVersion reads a version as given on a command line or in a URL. An
+// empty string selects version 3.0, which most address books still expect.
+func ParseVersion(value string) (Version, error) {
+	switch value {
+	case "", "3", "3.0":
+		return Version3, nil
+	case "4", "4.0":
+		return Version4, nil
+	default:
+		return "", fmt.Errorf("unsupported vCard version '%s', expected 3.0 or 4.0", value)
+	}
+}
+
+// ParseError reports a malformed vCard file. Line is the number of the first
+// physical line of the offending property, starting at 1.
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid vCard at line %d: %s", e.Line, e.Message)
+}
+
+// escape escapes a text value, so it can be written as is or as one
+// component of a structured value.
+func escape(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		",", `\,`,
+		";", `\;`,
+	).Replace(value)
+}
+
+// unescape resolves the backslash escapes of a text value. Unknown escapes
+// keep the escaped character, as some writers escape ':' too.
+func unescape(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// split splits value on every sep that is not escaped. The parts are left
+// escaped.
+func split(value string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
@@ -0,0 +1,246 @@
+package vcard
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+const sampleFile = "\uFEFFBEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"UID:1b4e28ba-2fa1-11d2-883f-0016d3cca427\r\n" +
+	"FN:Ada Lovelace\r\n" +
+	"N:Lovelace;Ada;;;\r\n" +
+	"ORG:Analytical Engines\\, Ltd;Research\r\n" +
+	"TITLE:Programmer\r\n" +
+	"TEL;TYPE=HOME,VOICE:+44 20 7946 0000\r\n" +
+	"TEL;TYPE=CELL,PREF:+44 7911 123456\r\n" +
+	"TEL;TYPE=WORK;TYPE=FAX:+44 20 7946 0001\r\n" +
+	"item1.EMAIL;TYPE=INTERNET,WORK:ada@engines.example\r\n" +
+	"ADR;TYPE=WORK:;Floor 2;12 St James's Square;London;;SW1Y 4JH;United Kingdom\r\n" +
+	"BDAY:18151210\r\n" +
+	"NOTE:First line\\nSecond line\\; with a semicolon and a long tail that has\r\n" +
+	"  to be folded\r\n" +
+	"CATEGORIES:vip,mathematics\r\n" +
+	"X-SOCIAL:ignored\r\n" +
+	"END:VCARD\r\n" +
+	"\r\n" +
+	"BEGIN:VCARD\n" +
+	"VERSION:4.0\n" +
+	"UID:urn:uuid:5b6f4c1c-9a8e-4a8e-b6a2-1f3b2a9f0c11\n" +
+	"N:Hopper;Grace;;;\n" +
+	"TEL;VALUE=uri;TYPE=\"work,voice\":tel:+1-202-555-0123;ext=12\n" +
+	"TEL;VALUE=uri;TYPE=cell;PREF=1:tel:+1-202-555-0199\n" +
+	"EMAIL;PREF=2:grace@navy.example\n" +
+	"EMAIL;TYPE=home;PREF=1:grace@home.example\n" +
+	"BDAY:--1209\n" +
+	"END:VCARD\n"
+
+func TestDecode(t *testing.T) {
+	contacts, err := Decode(strings.NewReader(sampleFile))
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if len(contacts) != 2 {
+		t.Fatalf("Expected 2 contacts, got %d", len(contacts))
+	}
+
+	expected := domain.Contact{
+		ID:           "1b4e28ba-2fa1-11d2-883f-0016d3cca427",
+		Name:         "Ada Lovelace",
+		FirstName:    "Ada",
+		LastName:     "Lovelace",
+		Organization: "Analytical Engines, Ltd",
+		JobTitle:     "Programmer",
+		Phones: []domain.Phone{
+			{Type: domain.PhoneMobile, Number: "+44 7911 123456"},
+			{Type: domain.PhoneHome, Number: "+44 20 7946 0000"},
+			{Type: domain.PhoneOther, Number: "+44 20 7946 0001"},
+		},
+		Emails: []domain.Email{{Type: "work", Address: "ada@engines.example"}},
+		Addresses: []domain.Address{{
+			Type:       "work",
+			Street:     "12 St James's Square, Floor 2",
+			City:       "London",
+			PostalCode: "SW1Y 4JH",
+			Country:    "United Kingdom",
+		}},
+		Birthday: "1815-12-10",
+		Notes:    "First line\nSecond line; with a semicolon and a long tail that has to be folded",
+		Tags:     []string{"vip", "mathematics"},
+	}
+	if !reflect.DeepEqual(contacts[0], expected) {
+		t.Errorf("Expected %+v, got %+v", expected, contacts[0])
+	}
+
+	grace := contacts[1]
+	if grace.ID != "5b6f4c1c-9a8e-4a8e-b6a2-1f3b2a9f0c11" || grace.Name != "Grace Hopper" {
+		t.Errorf("Expected the UID without its urn prefix and a name built from N, got %+v", grace)
+	}
+	expectedPhones := []domain.Phone{
+		{Type: domain.PhoneMobile, Number: "+1-202-555-0199"},
+		{Type: domain.PhoneWork, Number: "+1-202-555-0123"},
+	}
+	if !reflect.DeepEqual(grace.Phones, expectedPhones) {
+		t.Errorf("Expected tel: URIs sorted by preference %v, got %v", expectedPhones, grace.Phones)
+	}
+	if grace.PrimaryEmail() != "grace@home.example" || grace.Emails[0].Type != "home" {
+		t.Errorf("Expected the preferred email first, got %v", grace.Emails)
+	}
+	if grace.Birthday != "" {
+		t.Errorf("Expected a birthday without a year to be dropped, got '%s'", grace.Birthday)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+	}{
+		{"NoColon", "BEGIN:VCARD\nVERSION:3.0\nFN Ada\nEND:VCARD\n", 3},
+		{"MissingEnd", "BEGIN:VCARD\nFN:Ada\n", 1},
+		{"NestedBegin", "BEGIN:VCARD\nFN:Ada\nBEGIN:VCARD\n", 3},
+		{"EndWithoutBegin", "FN:Ada\n", 1},
+		{"StrayEnd", "BEGIN:VCARD\nEND:VCARD\nEND:VCARD\n", 3},
+		{"FoldedLineCounted", "BEGIN:VCARD\nNOTE:a\n b\nbroken\nEND:VCARD\n", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.input))
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a *ParseError, got %v", err)
+			}
+			if parseErr.Line != tt.line {
+				t.Errorf("Expected the error at line %d, got %d: %v", tt.line, parseErr.Line, err)
+			}
+		})
+	}
+}
+
+func TestDecode_Empty(t *testing.T) {
+	contacts, err := Decode(strings.NewReader("\r\n"))
+	if err != nil || len(contacts) != 0 {
+		t.Errorf("Expected no contacts and no error, got %v, %v", contacts, err)
+	}
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	contacts := []domain.Contact{
+		{
+			ID:           "1b4e28ba-2fa1-11d2-883f-0016d3cca427",
+			Name:         "Ada Lovelace",
+			FirstName:    "Ada",
+			LastName:     "Lovelace",
+			Organization: "Engines; Babbage, and Co",
+			JobTitle:     "Programmer",
+			Phones: []domain.Phone{
+				{Type: domain.PhoneMobile, Number: "+447911123456", Display: "+44 7911 123456"},
+				{Type: domain.PhoneWork, Number: "+442079460000"},
+				{Type: domain.PhoneOther, Number: "+442079460001"},
+			},
+			Emails: []domain.Email{
+				{Type: "work", Address: "ada@engines.example"},
+				{Address: "ada@mail.example"},
+			},
+			Addresses: []domain.Address{
+				{Type: "home", Street: "12 St James's Square", City: "London", Region: "Greater London", PostalCode: "SW1Y 4JH", Country: "United Kingdom"},
+			},
+			Birthday: "1815-12-10",
+			Notes:    "Line one\nLine two, with a comma; and a semicolon \\ and a backslash",
+			Tags:     []string{"vip", "a;b"},
+		},
+		{
+			ID:        "other-id",
+			Name:      "Zoë Ångström",
+			FirstName: "Zoë",
+			LastName:  "Ångström",
+			Phones:    []domain.Phone{{Type: domain.PhoneHome, Number: "+33612345678"}},
+			Notes:     strings.Repeat("é", 100),
+		},
+	}
+
+	for _, version := range []Version{Version3, Version4} {
+		t.Run(string(version), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, contacts, version); err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+
+			for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+				if len(line) > maxLineOctets || !utf8.ValidString(line) {
+					t.Errorf("Expected folded lines of valid UTF-8 up to %d octets, got %d: %q", maxLineOctets, len(line), line)
+				}
+			}
+			if !strings.Contains(buf.String(), "VERSION:"+string(version)+"\r\n") {
+				t.Errorf("Expected VERSION:%s, got\n%s", version, buf.String())
+			}
+
+			decoded, err := Decode(&buf)
+			if err != nil {
+				t.Fatalf("Failed to decode: %v", err)
+			}
+
+			expected := make([]domain.Contact, len(contacts))
+			for i, contact := range contacts {
+				expected[i] = contact.Clone()
+				for j := range expected[i].Phones {
+					// Only the canonical number is exported.
+					expected[i].Phones[j].Display = ""
+				}
+			}
+			if !reflect.DeepEqual(decoded, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, decoded)
+			}
+		})
+	}
+}
+
+func TestEncode_Properties(t *testing.T) {
+	contact := domain.Contact{
+		ID:     "1b4e28ba-2fa1-11d2-883f-0016d3cca427",
+		Name:   "Ada Lovelace",
+		Phones: []domain.Phone{{Type: domain.PhoneMobile, Number: "+447911123456"}},
+		Emails: []domain.Email{{Type: "work", Address: "ada@engines.example"}},
+	}
+
+	tests := []struct {
+		version  Version
+		expected []string
+	}{
+		{Version3, []string{
+			"UID:1b4e28ba-2fa1-11d2-883f-0016d3cca427\r\n",
+			"TEL;TYPE=CELL,PREF:+447911123456\r\n",
+			"EMAIL;TYPE=INTERNET,WORK,PREF:ada@engines.example\r\n",
+		}},
+		{Version4, []string{
+			"UID:urn:uuid:1b4e28ba-2fa1-11d2-883f-0016d3cca427\r\n",
+			"TEL;VALUE=uri;TYPE=cell;PREF=1:tel:+447911123456\r\n",
+			"EMAIL;TYPE=work;PREF=1:ada@engines.example\r\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Encode(&buf, []domain.Contact{contact}, tt.version); err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		for _, line := range tt.expected {
+			if !strings.Contains(buf.String(), line) {
+				t.Errorf("Expected version %s to contain %q, got\n%s", tt.version, line, buf.String())
+			}
+		}
+	}
+
+	if err := Encode(&bytes.Buffer{}, nil, "2.1"); err == nil {
+		t.Error("Expected an error for an unsupported version")
+	}
+}
@@ -0,0 +1,278 @@
+// Package webhook delivers contact events to other systems as signed JSON
+// POST requests. Failed deliveries are retried with exponential backoff and,
+// once every attempt failed, recorded in a dead-letter log.
+package webhook
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+)
+
+// Headers sent with every delivery. SignatureHeader holds "sha256=" and the
+// hex HMAC-SHA256 of the body keyed with the secret; DeliveryHeader holds the
+// event ID, the same across retries.
+const (
+	SignatureHeader = "X-Directory-Signature"
+	EventHeader     = "X-Directory-Event"
+	DeliveryHeader  = "X-Directory-Delivery"
+)
+
+// Defaults for the zero Options fields.
+const (
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = time.Minute
+	DefaultQueueSize   = 1000
+	DefaultTimeout     = 10 * time.Second
+)
+
+type Options struct {
+	// Secret signs the payloads. Without one, deliveries are not signed.
+	Secret string
+	// MaxAttempts is how many times a delivery is tried before it goes to
+	// the dead-letter log.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled after each
+	// failure up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// QueueSize is how many events may wait per URL. Events arriving when
+	// the queue is full go to the dead-letter log.
+	QueueSize int
+	// DeadLetter is the file failed deliveries are appended to, one JSON
+	// object per line. Without one, they are only logged.
+	DeadLetter string
+	Client     *http.Client
+}
+
+// DeadLetter is a delivery that failed for good.
+type DeadLetter struct {
+	Time     time.Time    `json:"time"`
+	URL      string       `json:"url"`
+	Attempts int          `json:"attempts"`
+	Error    string       `json:"error"`
+	Event    domain.Event `json:"event"`
+}
+
+// Dispatcher delivers events to a set of URLs. Each URL has its own queue
+// and worker, so a slow or failing receiver delays no other, and gets the
+// events in order.
+type Dispatcher struct {
+	opts   Options
+	queues map[string]chan domain.Event
+	wg     sync.WaitGroup
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// New starts delivering to urls. Handle queues the events to deliver and
+// Close stops the dispatcher.
+func New(urls []string, opts Options) *Dispatcher {
+	opts.MaxAttempts = cmp.Or(opts.MaxAttempts, DefaultMaxAttempts)
+	opts.Backoff = cmp.Or(opts.Backoff, DefaultBackoff)
+	opts.MaxBackoff = max(cmp.Or(opts.MaxBackoff, DefaultMaxBackoff), opts.Backoff)
+	opts.QueueSize = cmp.Or(opts.QueueSize, DefaultQueueSize)
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	d := &Dispatcher{opts: opts, queues: make(map[string]chan domain.Event)}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	for _, url := range urls {
+		if _, ok := d.queues[url]; ok {
+			continue
+		}
+		queue := make(chan domain.Event, opts.QueueSize)
+		d.queues[url] = queue
+		d.wg.Add(1)
+		go d.work(url, queue)
+	}
+	return d
+}
+
+// Handle queues event for delivery to every URL without waiting for it, so
+// it can subscribe to a service.Directory.
+func (d *Dispatcher) Handle(event domain.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for url, queue := range d.queues {
+		if d.closed {
+			d.deadLetter(url, 0, errors.New("dispatcher closed"), event)
+			continue
+		}
+		select {
+		case queue <- event:
+		default:
+			d.deadLetter(url, 0, errors.New("delivery queue full"), event)
+		}
+	}
+}
+
+// Close waits for the queued events to be delivered. When ctx is done first,
+// the deliveries left are abandoned and go to the dead-letter log.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) work(url string, queue <-chan domain.Event) {
+	defer d.wg.Done()
+	for event := range queue {
+		attempts, err := d.deliver(url, event)
+		if err != nil {
+			d.deadLetter(url, attempts, err, event)
+		}
+	}
+}
+
+// deliver sends event to url until it is accepted or every attempt failed,
+// and returns how many attempts were made.
+func (d *Dispatcher) deliver(url string, event domain.Event) (int, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	backoff := d.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(url, event, body)
+		if err == nil {
+			return attempt, nil
+		}
+		if !retry || attempt == d.opts.MaxAttempts {
+			return attempt, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-d.ctx.Done():
+			timer.Stop()
+			return attempt, fmt.Errorf("%w (gave up: %v)", err, d.ctx.Err())
+		}
+		backoff = min(backoff*2, d.opts.MaxBackoff)
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying: receivers rejecting the request itself are not retried.
+func (d *Dispatcher) post(url string, event domain.Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-directory-webhook")
+	req.Header.Set(EventHeader, string(event.Type))
+	req.Header.Set(DeliveryHeader, event.ID)
+	if d.opts.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(d.opts.Secret, body))
+	}
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post event: %w", err)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("receiver answered %s", resp.Status)
+	default:
+		return false, fmt.Errorf("receiver rejected the event: %s", resp.Status)
+	}
+}
+
+// deadLetter records a delivery that failed for good. When even that fails,
+// the event is logged in full rather than lost.
+func (d *Dispatcher) deadLetter(url string, attempts int, cause error, event domain.Event) {
+	entry := DeadLetter{Time: time.Now().UTC(), URL: url, Attempts: attempts, Error: cause.Error(), Event: event}
+	data, err := json.Marshal(entry)
+	if err == nil && d.opts.DeadLetter != "" {
+		if err = appendLine(d.opts.DeadLetter, data); err == nil {
+			return
+		}
+	}
+
+	log.Printf("warning: webhook %s for contact %s not delivered to %s: %v: %s", event.Type, event.Contact.ID, url, cause, data)
+	if err != nil {
+		log.Printf("warning: failed to record it in the dead-letter log: %v", err)
+	}
+}
+
+// deadLetterMu serializes the writes to dead-letter logs, which dispatchers
+// may share.
+var deadLetterMu sync.Mutex
+
+func appendLine(path string, data []byte) error {
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open dead-letter log: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write dead-letter log: %w", err)
+	}
+	return f.Close()
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature, a SignatureHeader value, matches body.
+// Receivers use it to check a delivery comes from the directory.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, body)))
+}
@@ -0,0 +1,246 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LaulauChau/go-directory/internal/domain"
+	"github.com/LaulauChau/go-directory/internal/service"
+	"github.com/LaulauChau/go-directory/internal/storage"
+)
+
+// receiver records the deliveries it gets and answers each with the next
+// status in statuses, then 200.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	times    []time.Time
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	rc.times = append(rc.times, time.Now())
+
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, string) {
+	rc := &receiver{statuses: statuses}
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+	return rc, server.URL
+}
+
+func testEvent(eventType domain.EventType, name string) domain.Event {
+	return domain.Event{
+		ID:      domain.NewID(),
+		Type:    eventType,
+		Time:    time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		Actor:   "alice",
+		Contact: domain.NewContact(name, "+12025550100"),
+	}
+}
+
+func readDeadLetters(t *testing.T, path string) []DeadLetter {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Failed to read the dead-letter log: %v", err)
+	}
+
+	var letters []DeadLetter
+	for line := range bytes.Lines(data) {
+		var letter DeadLetter
+		if err := json.Unmarshal(line, &letter); err != nil {
+			t.Fatalf("Failed to parse dead letter %q: %v", line, err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters
+}
+
+func TestDispatcher_Delivers(t *testing.T) {
+	first, firstURL := newReceiver(t)
+	second, secondURL := newReceiver(t)
+	dispatcher := New([]string{firstURL, secondURL}, Options{Secret: "s3cret"})
+
+	created := testEvent(domain.ContactCreated, "John Doe")
+	updated := testEvent(domain.ContactUpdated, "Johnny Doe")
+	updated.Previous = &created.Contact
+	dispatcher.Handle(created)
+	dispatcher.Handle(updated)
+	if err := dispatcher.Close(context.Background()); err != nil {
+		t.Fatalf("Failed to close the dispatcher: %v", err)
+	}
+
+	for _, rc := range []*receiver{first, second} {
+		if len(rc.requests) != 2 {
+			t.Fatalf("Expected 2 deliveries, got %d", len(rc.requests))
+		}
+		for i, want := range []domain.Event{created, updated} {
+			req, body := rc.requests[i], rc.bodies[i]
+			if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("Expected a JSON POST, got %s %s", req.Method, req.Header.Get("Content-Type"))
+			}
+			if req.Header.Get(EventHeader) != string(want.Type) || req.Header.Get(DeliveryHeader) != want.ID {
+				t.Errorf("Expected the event type and ID in the headers, got %v", req.Header)
+			}
+			if !Verify("s3cret", body, req.Header.Get(SignatureHeader)) {
+				t.Errorf("Expected a valid signature, got %s", req.Header.Get(SignatureHeader))
+			}
+			if Verify("other", body, req.Header.Get(SignatureHeader)) {
+				t.Error("Expected the signature to depend on the secret")
+			}
+
+			var got domain.Event
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatalf("Failed to parse the payload: %v", err)
+			}
+			if got.ID != want.ID || got.Type != want.Type || got.Contact.Name != want.Contact.Name || got.Actor != "alice" {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+		}
+	}
+}
+
+func TestDispatcher_DirectoryEvents(t *testing.T) {
+	rc, url := newReceiver(t)
+	dir, err := service.NewDirectory(storage.NewJSONStorage(filepath.Join(t.TempDir(), "contacts.json")))
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	dispatcher := New([]string{url}, Options{Secret: "s3cret"})
+	dir.Subscribe(dispatcher.Handle)
+
+	john, _ := dir.CreateContact(domain.NewContact("John Doe", "+12025550100"))
+	john.Organization = "Acme"
+	dir.UpdateContact(john)
+	dir.DeleteContact(john.ID)
+	dispatcher.Close(context.Background())
+
+	want := []domain.EventType{domain.ContactCreated, domain.ContactUpdated, domain.ContactDeleted}
+	if len(rc.requests) != len(want) {
+		t.Fatalf("Expected %d deliveries, got %d", len(want), len(rc.requests))
+	}
+	for i, eventType := range want {
+		var event domain.Event
+		if err := json.Unmarshal(rc.bodies[i], &event); err != nil {
+			t.Fatalf("Failed to parse the payload: %v", err)
+		}
+		if event.Type != eventType || event.Contact.ID != john.ID {
+			t.Errorf("Expected %s for John Doe, got %+v", eventType, event)
+		}
+		if !Verify("s3cret", rc.bodies[i], rc.requests[i].Header.Get(SignatureHeader)) {
+			t.Errorf("Expected a valid signature on delivery %d", i)
+		}
+	}
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	rc, url := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusInternalServerError)
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	dispatcher := New([]string{url}, Options{
+		Backoff:    20 * time.Millisecond,
+		MaxBackoff: 30 * time.Millisecond,
+		DeadLetter: deadLetter,
+	})
+
+	dispatcher.Handle(testEvent(domain.ContactCreated, "John Doe"))
+	dispatcher.Close(context.Background())
+
+	if len(rc.requests) != 4 {
+		t.Fatalf("Expected 3 failures and a success, got %d attempts", len(rc.requests))
+	}
+	for i, want := range []time.Duration{20, 30, 30} {
+		if gap := rc.times[i+1].Sub(rc.times[i]); gap < want*time.Millisecond {
+			t.Errorf("Expected retry %d after at least %dms, got %v", i+1, want, gap)
+		}
+	}
+	if letters := readDeadLetters(t, deadLetter); len(letters) != 0 {
+		t.Errorf("Expected no dead letter, got %+v", letters)
+	}
+}
+
+func TestDispatcher_DeadLetter(t *testing.T) {
+	failing, failingURL := newReceiver(t, 500, 500, 500, 500)
+	rejecting, rejectingURL := newReceiver(t, http.StatusBadRequest)
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	dispatcher := New([]string{failingURL, rejectingURL}, Options{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		DeadLetter:  deadLetter,
+	})
+
+	event := testEvent(domain.ContactDeleted, "John Doe")
+	dispatcher.Handle(event)
+	dispatcher.Close(context.Background())
+
+	if len(failing.requests) != 3 {
+		t.Errorf("Expected 3 attempts, got %d", len(failing.requests))
+	}
+	if len(rejecting.requests) != 1 {
+		t.Errorf("Expected a rejected delivery not to be retried, got %d attempts", len(rejecting.requests))
+	}
+
+	letters := readDeadLetters(t, deadLetter)
+	if len(letters) != 2 {
+		t.Fatalf("Expected 2 dead letters, got %+v", letters)
+	}
+	attempts := map[string]int{}
+	for _, letter := range letters {
+		attempts[letter.URL] = letter.Attempts
+		if letter.Event.ID != event.ID || letter.Error == "" || letter.Time.IsZero() {
+			t.Errorf("Expected the event and why it failed, got %+v", letter)
+		}
+	}
+	if attempts[failingURL] != 3 || attempts[rejectingURL] != 1 {
+		t.Errorf("Expected 3 and 1 attempts, got %v", attempts)
+	}
+
+	dispatcher.Handle(testEvent(domain.ContactCreated, "Jane Doe"))
+	if letters := readDeadLetters(t, deadLetter); len(letters) != 4 {
+		t.Errorf("Expected events handled once closed to be dead letters, got %d", len(letters))
+	}
+}
+
+func TestDispatcher_CloseTimeout(t *testing.T) {
+	rc, url := newReceiver(t, 500, 500)
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	dispatcher := New([]string{url}, Options{Backoff: time.Hour, DeadLetter: deadLetter})
+
+	dispatcher.Handle(testEvent(domain.ContactCreated, "John Doe"))
+	dispatcher.Handle(testEvent(domain.ContactCreated, "Jane Doe"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := dispatcher.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+	if len(rc.requests) != 1 {
+		t.Errorf("Expected the retry to be abandoned, got %d attempts", len(rc.requests))
+	}
+	if letters := readDeadLetters(t, deadLetter); len(letters) != 2 {
+		t.Errorf("Expected both events to be dead letters, got %+v", letters)
+	}
+}